@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"time"
 
 	"github.com/slimcdk/go-eloverblik/v1"
 	"github.com/spf13/cobra"
@@ -19,16 +23,68 @@ var authorizationsCmd = &cobra.Command{
 			cobra.CheckErr(fmt.Errorf("the 'authorizations' command can only be used with the 'thirdparty' subcommand"))
 		}
 
+		if snapshotFile, _ := cmd.Flags().GetString("changes-since"); snapshotFile != "" {
+			expiringWithin, _ := cmd.Flags().GetDuration("expiring-within")
+			changes, err := authorizationChanges(thirdpartyAPI, snapshotFile, expiringWithin)
+			cobra.CheckErr(err)
+
+			cobra.CheckErr(writeResult(cmd, result{
+				value: changes,
+				table: authorizationChangesTable(changes),
+			}))
+			return
+		}
+
 		authorizations, err := thirdpartyAPI.GetAuthorizations()
 		cobra.CheckErr(err)
 
-		bytes, err := json.Marshal(authorizations)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeResult(cmd, result{
+			value: authorizations,
+			table: authorizationsTable(authorizations),
+		}))
 	},
 }
 
+// authorizationChanges compares the authorizations with the snapshot in snapshotFile, and
+// replaces the snapshot with the current state once they are compared. A missing file is
+// an empty snapshot, against which every authorization is new.
+func authorizationChanges(client eloverblik.ThirdParty, snapshotFile string, expiringWithin time.Duration) ([]eloverblik.AuthorizationChange, error) {
+	var before eloverblik.AuthorizationSnapshot
+	data, err := os.ReadFile(snapshotFile) // #nosec G304 -- the user names the snapshot file
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &before); err != nil {
+			return nil, fmt.Errorf("reading snapshot %s: %w", snapshotFile, err)
+		}
+	}
+
+	after, err := eloverblik.TakeAuthorizationSnapshot(client)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = json.MarshalIndent(after, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(snapshotFile, append(data, '\n'), 0o600); err != nil {
+		return nil, err
+	}
+
+	changes := eloverblik.DiffAuthorizations(before, after, expiringWithin)
+	if changes == nil {
+		// No changes are an empty list, not null
+		changes = []eloverblik.AuthorizationChange{}
+	}
+	return changes, nil
+}
+
 func init() {
+	authorizationsCmd.Flags().String("changes-since", "", "Report the changes since the snapshot in this file instead of the authorizations, then update the file (a missing file makes every authorization new)")
+	authorizationsCmd.Flags().Duration("expiring-within", 30*24*time.Hour, "With --changes-since, report authorizations expiring within this duration")
+	addOutputFormatFlag(authorizationsCmd)
 	thirdpartyCmd.AddCommand(authorizationsCmd)
 }
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockThirdPartyClient serves authorizations and their metering points from memory.
+type mockThirdPartyClient struct {
+	eloverblik.ThirdParty
+	authorizations []eloverblik.Authorization
+	meteringPoints map[string][]string
+}
+
+func (m *mockThirdPartyClient) GetAuthorizations() ([]eloverblik.Authorization, error) {
+	return m.authorizations, nil
+}
+
+func (m *mockThirdPartyClient) GetMeteringPointIDsForScope(scope eloverblik.AuthorizationScope, identifier string) ([]string, error) {
+	return m.meteringPoints[identifier], nil
+}
+
+func TestAuthorizationsChangesSince(t *testing.T) {
+	mock := &mockThirdPartyClient{
+		authorizations: []eloverblik.Authorization{{ID: "auth-1", CustomerName: "Customer", ValidFrom: "2024-01-01", ValidTo: "2099-12-31"}},
+		meteringPoints: map[string][]string{"auth-1": {"571313180100000001"}},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	snapshotFile := filepath.Join(t.TempDir(), "authorizations.json")
+	changes := func(t *testing.T) []eloverblik.AuthorizationChange {
+		t.Helper()
+		buf.Reset()
+		_, err := execute(t, "thirdparty", "authorizations", "--changes-since", snapshotFile, "--token", "dummy")
+		assert.NoError(t, err)
+
+		var changes []eloverblik.AuthorizationChange
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &changes))
+		return changes
+	}
+
+	t.Run("the first run finds everything new and saves a snapshot", func(t *testing.T) {
+		got := changes(t)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, eloverblik.AuthorizationNew, got[0].Kind)
+			assert.Equal(t, "auth-1", got[0].AuthorizationID)
+		}
+		_, err := os.Stat(snapshotFile)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		assert.Empty(t, changes(t))
+		assert.Equal(t, "[]\n", buf.String(), "no changes are an empty list")
+	})
+
+	t.Run("a metering point was added", func(t *testing.T) {
+		mock.meteringPoints["auth-1"] = append(mock.meteringPoints["auth-1"], "571313180100000002")
+
+		got := changes(t)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, eloverblik.MeteringPointAdded, got[0].Kind)
+			assert.Equal(t, "571313180100000002", got[0].MeteringPointID)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		mock.authorizations = nil
+
+		got := changes(t)
+		if assert.Len(t, got, 1) {
+			assert.Equal(t, eloverblik.AuthorizationRevoked, got[0].Kind)
+		}
+	})
+
+	t.Run("a corrupt snapshot is an error", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(snapshotFile, []byte("{"), 0o600))
+		_, err := authorizationChanges(mock, snapshotFile, 0)
+		assert.ErrorContains(t, err, "reading snapshot")
+
+		data, _ := os.ReadFile(snapshotFile)
+		assert.Equal(t, "{", string(data), "the snapshot is left alone")
+	})
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
 	"time"
 
@@ -67,10 +66,7 @@ func newChargeLinksCmd() *cobra.Command {
 			chargeLinks, err := clientInstance.GetChargeLinksWithCharges(args, from, to)
 			cobra.CheckErr(err)
 
-			bytes, err := json.Marshal(chargeLinks)
-			cobra.CheckErr(err)
-			_, err = output.Write(bytes)
-			cobra.CheckErr(err)
+			cobra.CheckErr(writeJSON(output, chargeLinks))
 		},
 	}
 	cmd.Flags().String("from", "", "start date (YYYY-MM-DD, now, now-30d/w/m/y)")
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/slimcdk/go-eloverblik/v1"
@@ -19,10 +18,7 @@ var customerChargesCmd = &cobra.Command{
 		}
 		charges, err := customerAPI.GetCustomerCharges(args)
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(charges)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeResult(cmd, result{value: charges, table: customerChargesTable(charges)}))
 	},
 }
 
@@ -37,10 +33,7 @@ var thirdpartyChargesCmd = &cobra.Command{
 		}
 		charges, err := thirdpartyAPI.GetThirdPartyCharges(args)
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(charges)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeResult(cmd, result{value: charges, table: thirdPartyChargesTable(charges)}))
 	},
 }
 
@@ -65,8 +58,10 @@ var exportChargesCmd = &cobra.Command{
 }
 
 func init() {
+	addOutputFormatFlag(customerChargesCmd)
 	customerCmd.AddCommand(customerChargesCmd)
 	exportChargesCmd.Flags().String("format", "csv", "output format (csv, json)")
 	customerCmd.AddCommand(exportChargesCmd)
+	addOutputFormatFlag(thirdpartyChargesCmd)
 	thirdpartyCmd.AddCommand(thirdpartyChargesCmd)
 }
@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Environment variables the CLI reads. A flag always wins over the environment, and the
+// environment over the config file.
+const (
+	envToken   = "ELOVERBLIK_TOKEN"
+	envProfile = "ELOVERBLIK_PROFILE"
+	envConfig  = "ELOVERBLIK_CONFIG"
+
+	envMQTTPassword = "ELOVERBLIK_MQTT_PASSWORD"
+)
+
+// Values of a profile's api and environment.
+const (
+	apiCustomer    = "customer"
+	apiThirdParty  = "thirdparty"
+	environProd    = "prod"
+	environPreprod = "preprod"
+)
+
+// stdin is where --token-file - reads the token from (configurable for testing).
+var stdin io.Reader = os.Stdin
+
+// config is the CLI configuration file: named profiles, and the one used when no other
+// is asked for.
+type config struct {
+	Current  string             `json:"current,omitempty"`
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// profile holds what the CLI would otherwise need on every call: the refresh token, the
+// API and environment it belongs to, and defaults for command flags, keyed by flag name,
+// e.g. {"aggregation": "Day", "output": "table"}. A profile set up with 'login'
+// keeps its token in a secret store instead, named by TokenStore.
+type profile struct {
+	Token       string            `json:"token,omitempty"`
+	TokenStore  string            `json:"tokenStore,omitempty"`
+	API         string            `json:"api,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// configPath returns where the config file lives: $ELOVERBLIK_CONFIG when set, otherwise
+// go-eloverblik/config.json in the user's config directory, i.e. $XDG_CONFIG_HOME or
+// ~/.config on Linux.
+func configPath() (string, error) {
+	if path := os.Getenv(envConfig); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the config directory: %w", err)
+	}
+	return filepath.Join(dir, "go-eloverblik", "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file is an empty config, not an error.
+func loadConfig() (config, error) {
+	cfg := config{Profiles: map[string]profile{}}
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- the path is the user's own config file
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("cannot read config file %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]profile{}
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config file. It holds refresh tokens, so it is readable by its
+// owner only.
+func saveConfig(cfg config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// activeProfile returns the profile a command runs with: the one named with --profile,
+// else $ELOVERBLIK_PROFILE, else the config's current profile. An empty name and profile
+// are returned when none is configured; naming a profile that does not exist is an error.
+func activeProfile(cmd *cobra.Command) (string, profile, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", profile{}, err
+	}
+
+	name, _ := cmd.Root().PersistentFlags().GetString("profile")
+	if name == "" {
+		name = os.Getenv(envProfile)
+	}
+	if name == "" {
+		if cfg.Current == "" {
+			return "", profile{}, nil
+		}
+		name = cfg.Current
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return "", profile{}, fmt.Errorf("no profile named '%s' in the config file", name)
+	}
+	return name, p, nil
+}
+
+// resolveToken returns the refresh token a command runs with, from the first of --token,
+// --token-file, $ELOVERBLIK_TOKEN and the profile named name, from the config file or its
+// secret store. fromProfile reports whether it was the profile's.
+func resolveToken(cmd *cobra.Command, name string, p profile) (token string, fromProfile bool, err error) {
+	flags := cmd.Root().PersistentFlags()
+
+	if token, _ := flags.GetString("token"); token != "" {
+		return token, false, nil
+	}
+	if file, _ := flags.GetString("token-file"); file != "" {
+		token, err := readTokenFile(file)
+		return token, false, err
+	}
+	if token := strings.TrimSpace(os.Getenv(envToken)); token != "" {
+		return token, false, nil
+	}
+	if p.Token != "" {
+		return p.Token, true, nil
+	}
+	if p.TokenStore != "" {
+		store, err := newSecretStore(p.TokenStore)
+		if err != nil {
+			return "", false, err
+		}
+		token, err := store.get(name)
+		if errors.Is(err, errNoSecret) {
+			return "", false, fmt.Errorf("the %s holds no token for profile '%s', run 'go-eloverblik login %s'", p.TokenStore, name, name)
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("cannot read the token of profile '%s' from the %s: %w", name, p.TokenStore, err)
+		}
+		return token, true, nil
+	}
+
+	return "", false, fmt.Errorf("no refresh token: pass --token or --token-file, set %s, or store one with 'go-eloverblik login'", envToken)
+}
+
+// readTokenFile reads a refresh token from a file, or from stdin when the file is "-".
+// Surrounding whitespace, such as the newline an editor or echo adds, is dropped.
+func readTokenFile(file string) (string, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(file) // #nosec G304 -- the user names the file to read
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("cannot read token: %s is empty", file)
+	}
+	return token, nil
+}
+
+// setupClient resolves the profile and token of an API subcommand and applies the
+// profile's flag defaults. api is the subcommand's API, which the profile must agree with
+// when it is the profile's token that is used.
+func setupClient(cmd *cobra.Command, api string) (token string, p profile, err error) {
+	name, p, err := activeProfile(cmd)
+	if err != nil {
+		return "", p, err
+	}
+
+	token, fromProfile, err := resolveToken(cmd, name, p)
+	if err != nil {
+		return "", p, err
+	}
+	if fromProfile && p.API != "" && p.API != api {
+		return "", p, fmt.Errorf("profile '%s' holds a %s token, use the '%s' subcommand", name, p.API, p.API)
+	}
+	if err := checkTokenAPI(token, api); err != nil {
+		return "", p, err
+	}
+
+	return token, p, applyDefaults(cmd, p.Defaults)
+}
+
+// applyDefaults sets the flags a profile has defaults for, unless they were given on the
+// command line. A default for a flag the command does not have is ignored: a profile's
+// defaults are shared by all commands.
+func applyDefaults(cmd *cobra.Command, defaults map[string]string) error {
+	for name, value := range defaults {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid default for --%s in profile: %w", name, err)
+		}
+	}
+	return nil
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the profiles of the configuration file",
+	Long: `Manage named profiles in the configuration file, so the refresh token does not have to
+be passed on every call, or end up in shell history and process listings.
+
+A command finds its refresh token in the first of: --token, --token-file (a path, or -
+for stdin), $ELOVERBLIK_TOKEN, and the active profile. The active profile is the one
+named with --profile, else $ELOVERBLIK_PROFILE, else the one selected with 'config use'.
+
+'config set' keeps the token in the configuration file in plain text; use 'login' to keep
+it in the OS keyring instead.`,
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(output, path)
+		return err
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles, without their tokens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		type listedProfile struct {
+			Name        string            `json:"name"`
+			Current     bool              `json:"current"`
+			HasToken    bool              `json:"hasToken"`
+			TokenStore  string            `json:"tokenStore,omitempty"`
+			API         string            `json:"api,omitempty"`
+			Environment string            `json:"environment,omitempty"`
+			Defaults    map[string]string `json:"defaults,omitempty"`
+		}
+
+		names := make([]string, 0, len(cfg.Profiles))
+		for name := range cfg.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		listed := make([]listedProfile, 0, len(names))
+		for _, name := range names {
+			p := cfg.Profiles[name]
+			listed = append(listed, listedProfile{
+				Name:        name,
+				Current:     name == cfg.Current,
+				HasToken:    p.Token != "" || p.TokenStore != "",
+				TokenStore:  p.TokenStore,
+				API:         p.API,
+				Environment: p.Environment,
+				Defaults:    p.Defaults,
+			})
+		}
+
+		return writeJSON(output, listed)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <profile>",
+	Short: "Create or update a profile",
+	Long: `Create or update a profile. Only the settings given are changed. The token is read with
+--token-file, from a file or from stdin (-), and --token works as well; the first profile
+created becomes the current one.
+
+  go-eloverblik config set home --api customer --token-file - < token.txt
+  go-eloverblik config set home --default aggregation=Day --default output=table`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		p := cfg.Profiles[name]
+
+		// The token flags are the root's persistent ones; they name the token to store.
+		flags := cmd.Root().PersistentFlags()
+		if token, _ := flags.GetString("token"); token != "" {
+			p.Token = token
+		} else if file, _ := flags.GetString("token-file"); file != "" {
+			if p.Token, err = readTokenFile(file); err != nil {
+				return err
+			}
+		}
+
+		// A token given here replaces one the profile keeps in a secret store.
+		if p.Token != "" && p.TokenStore != "" {
+			if err := forgetToken(name, p); err != nil {
+				return err
+			}
+			p.TokenStore = ""
+		}
+
+		if cmd.Flags().Changed("api") {
+			api, _ := cmd.Flags().GetString("api")
+			if api != apiCustomer && api != apiThirdParty && api != "" {
+				return fmt.Errorf("invalid api '%s', use %s or %s", api, apiCustomer, apiThirdParty)
+			}
+			p.API = api
+		}
+
+		if cmd.Flags().Changed("environment") {
+			environment, _ := cmd.Flags().GetString("environment")
+			if environment != environProd && environment != environPreprod && environment != "" {
+				return fmt.Errorf("invalid environment '%s', use %s or %s", environment, environProd, environPreprod)
+			}
+			p.Environment = environment
+		}
+
+		defaults, _ := cmd.Flags().GetStringArray("default")
+		for _, d := range defaults {
+			flag, value, ok := strings.Cut(d, "=")
+			if !ok || flag == "" {
+				return fmt.Errorf("invalid default '%s', use flag=value", d)
+			}
+			if p.Defaults == nil {
+				p.Defaults = map[string]string{}
+			}
+			if value == "" {
+				delete(p.Defaults, flag)
+			} else {
+				p.Defaults[flag] = value
+			}
+		}
+
+		cfg.Profiles[name] = p
+		if cfg.Current == "" {
+			cfg.Current = name
+		}
+		return saveConfig(cfg)
+	},
+}
+
+var configUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Make a profile the current one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Profiles[args[0]]; !ok {
+			return fmt.Errorf("no profile named '%s' in the config file", args[0])
+		}
+		cfg.Current = args[0]
+		return saveConfig(cfg)
+	},
+}
+
+var configDeleteCmd = &cobra.Command{
+	Use:   "delete <profile>",
+	Short: "Delete a profile, and the token it keeps in a secret store",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		p, ok := cfg.Profiles[args[0]]
+		if !ok {
+			return fmt.Errorf("no profile named '%s' in the config file", args[0])
+		}
+		if err := forgetToken(args[0], p); err != nil {
+			return err
+		}
+		delete(cfg.Profiles, args[0])
+		if cfg.Current == args[0] {
+			cfg.Current = ""
+		}
+		return saveConfig(cfg)
+	},
+}
+
+func init() {
+	configSetCmd.Flags().String("api", "", "API the token belongs to (customer, thirdparty)")
+	configSetCmd.Flags().String("environment", "", "Eloverblik environment (prod, preprod)")
+	configSetCmd.Flags().StringArray("default", nil, "default for a command flag, as flag=value; an empty value removes it (repeatable)")
+
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUseCmd)
+	configCmd.AddCommand(configDeleteCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// useTempConfig points the CLI at a config file of its own for the duration of a test.
+func useTempConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv(envConfig, path)
+	t.Setenv(envToken, "")
+	t.Setenv(envProfile, "")
+	return path
+}
+
+func TestConfigCommands(t *testing.T) {
+	path := useTempConfig(t)
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	oldStdin := stdin
+	defer func() { stdin = oldStdin }()
+
+	t.Run("set reads the token from stdin and becomes current", func(t *testing.T) {
+		stdin = strings.NewReader("home-token\n")
+		_, err := execute(t, "config", "set", "home", "--api", "customer", "--token-file", "-", "--default", "aggregation=Day")
+		assert.NoError(t, err)
+
+		cfg, err := loadConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, "home", cfg.Current)
+		assert.Equal(t, profile{Token: "home-token", API: "customer", Defaults: map[string]string{"aggregation": "Day"}}, cfg.Profiles["home"])
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the file holds tokens")
+	})
+
+	t.Run("set only changes what it is given", func(t *testing.T) {
+		_, err := execute(t, "config", "set", "home", "--environment", "preprod")
+		assert.NoError(t, err)
+
+		cfg, _ := loadConfig()
+		assert.Equal(t, "home-token", cfg.Profiles["home"].Token)
+		assert.Equal(t, "preprod", cfg.Profiles["home"].Environment)
+	})
+
+	t.Run("set rejects an unknown api", func(t *testing.T) {
+		_, err := execute(t, "config", "set", "home", "--api", "business")
+		assert.ErrorContains(t, err, "invalid api 'business'")
+	})
+
+	t.Run("list leaves the tokens out", func(t *testing.T) {
+		_, err := execute(t, "config", "set", "work", "--api", "thirdparty", "--token", "work-token")
+		assert.NoError(t, err)
+
+		buf.Reset()
+		_, err = execute(t, "config", "list")
+		assert.NoError(t, err)
+		assert.NotContains(t, buf.String(), "home-token")
+
+		var listed []map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &listed))
+		assert.Len(t, listed, 2)
+		assert.Equal(t, "home", listed[0]["name"])
+		assert.Equal(t, true, listed[0]["current"])
+		assert.Equal(t, true, listed[1]["hasToken"])
+	})
+
+	t.Run("use switches the current profile", func(t *testing.T) {
+		_, err := execute(t, "config", "use", "work")
+		assert.NoError(t, err)
+		cfg, _ := loadConfig()
+		assert.Equal(t, "work", cfg.Current)
+
+		_, err = execute(t, "config", "use", "nope")
+		assert.ErrorContains(t, err, "no profile named 'nope'")
+	})
+
+	t.Run("delete removes the profile and clears current", func(t *testing.T) {
+		_, err := execute(t, "config", "delete", "work")
+		assert.NoError(t, err)
+		cfg, _ := loadConfig()
+		assert.NotContains(t, cfg.Profiles, "work")
+		assert.Empty(t, cfg.Current)
+	})
+}
+
+func TestResolveToken(t *testing.T) {
+	useTempConfig(t)
+	resetCommandFlags(rootCmd)
+	defer resetCommandFlags(rootCmd)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+
+	p := profile{Token: "profile-token"}
+
+	token, fromProfile, err := resolveToken(rootCmd, "work", p)
+	assert.NoError(t, err)
+	assert.Equal(t, "profile-token", token)
+	assert.True(t, fromProfile)
+
+	t.Setenv(envToken, "env-token")
+	token, _, _ = resolveToken(rootCmd, "work", p)
+	assert.Equal(t, "env-token", token, "the environment wins over the profile")
+
+	assert.NoError(t, rootCmd.PersistentFlags().Set("token-file", tokenFile))
+	token, _, _ = resolveToken(rootCmd, "work", p)
+	assert.Equal(t, "file-token", token, "--token-file wins over the environment, trimmed")
+
+	assert.NoError(t, rootCmd.PersistentFlags().Set("token", "flag-token"))
+	token, fromProfile, _ = resolveToken(rootCmd, "work", p)
+	assert.Equal(t, "flag-token", token, "--token wins over everything")
+	assert.False(t, fromProfile)
+
+	resetCommandFlags(rootCmd)
+	t.Setenv(envToken, "")
+	_, _, err = resolveToken(rootCmd, "", profile{})
+	assert.ErrorContains(t, err, "no refresh token")
+}
+
+func TestSetupClient(t *testing.T) {
+	useTempConfig(t)
+	assert.NoError(t, saveConfig(config{
+		Current: "work",
+		Profiles: map[string]profile{
+			"work": {Token: "work-token", API: apiThirdParty, Defaults: map[string]string{"aggregation": "Day", "include-all": "true"}},
+			"home": {Token: "home-token", API: apiCustomer},
+		},
+	}))
+
+	timeseries, _, err := rootCmd.Find([]string{"thirdparty", "timeseries"})
+	assert.NoError(t, err)
+	resetCommandFlags(rootCmd)
+	defer resetCommandFlags(rootCmd)
+
+	t.Run("uses the current profile and applies its defaults", func(t *testing.T) {
+		token, p, err := setupClient(timeseries, apiThirdParty)
+		assert.NoError(t, err)
+		assert.Equal(t, "work-token", token)
+		assert.Equal(t, apiThirdParty, p.API)
+
+		// include-all is not a timeseries flag, and is skipped rather than failing
+		aggregation, _ := timeseries.Flags().GetString("aggregation")
+		assert.Equal(t, "Day", aggregation)
+	})
+
+	t.Run("a flag on the command line wins over the profile default", func(t *testing.T) {
+		resetCommandFlags(rootCmd)
+		assert.NoError(t, timeseries.Flags().Set("aggregation", "Month"))
+		_, _, err := setupClient(timeseries, apiThirdParty)
+		assert.NoError(t, err)
+
+		aggregation, _ := timeseries.Flags().GetString("aggregation")
+		assert.Equal(t, "Month", aggregation)
+	})
+
+	t.Run("a profile for the other API fails early", func(t *testing.T) {
+		resetCommandFlags(rootCmd)
+		_, _, err := setupClient(timeseries, apiCustomer)
+		assert.ErrorContains(t, err, "profile 'work' holds a thirdparty token, use the 'thirdparty' subcommand")
+	})
+
+	t.Run("ELOVERBLIK_PROFILE selects another profile", func(t *testing.T) {
+		resetCommandFlags(rootCmd)
+		t.Setenv(envProfile, "home")
+		token, _, err := setupClient(timeseries, apiCustomer)
+		assert.NoError(t, err)
+		assert.Equal(t, "home-token", token)
+	})
+
+	t.Run("--profile naming a missing profile is an error", func(t *testing.T) {
+		resetCommandFlags(rootCmd)
+		assert.NoError(t, rootCmd.PersistentFlags().Set("profile", "gone"))
+		_, _, err := setupClient(timeseries, apiCustomer)
+		assert.ErrorContains(t, err, "no profile named 'gone'")
+	})
+}
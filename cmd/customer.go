@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/slimcdk/go-eloverblik/v1"
 	"github.com/spf13/cobra"
 )
@@ -14,14 +12,14 @@ var customerCmd = &cobra.Command{
 		if clientInstance != nil {
 			return nil
 		}
-		token, err := cmd.Root().PersistentFlags().GetString("token")
+		token, p, err := setupClient(cmd, apiCustomer)
 		if err != nil {
 			return err
 		}
-		if token == "" {
-			return fmt.Errorf("required flag \"token\" not set")
+		buildClient = func() eloverblik.Client {
+			return eloverblik.NewCustomer(token, clientOptions(cmd, p)...)
 		}
-		clientInstance = eloverblik.NewCustomer(token, clientOptions(cmd)...)
+		clientInstance = buildClient()
 		return nil
 	},
 }
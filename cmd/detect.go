@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+// claimsAPI returns the subcommand of the API a token was issued for.
+func claimsAPI(claims eloverblik.TokenClaims) (string, error) {
+	apiType, err := claims.APIType()
+	if err != nil {
+		return "", err
+	}
+	if apiType == eloverblik.ThirdPartyApi {
+		return apiThirdParty, nil
+	}
+	return apiCustomer, nil
+}
+
+// checkTokenAPI fails when the token was issued for another API than the subcommand it
+// is used with, which the API would otherwise answer with a bare 401. A token whose claims
+// cannot be read is left for the API to judge.
+func checkTokenAPI(token, api string) error {
+	claims, err := eloverblik.ParseToken(token)
+	if err != nil {
+		return nil
+	}
+	tokenAPI, err := claimsAPI(claims)
+	if err != nil || tokenAPI == api {
+		return nil
+	}
+	return fmt.Errorf("the refresh token is a %s token, but the command is a %s command: use the '%s' subcommand, or leave it out", tokenAPI, api, tokenAPI)
+}
+
+// apiPrefixedArgs lets the commands of the customer and thirdparty APIs run without naming
+// the API: "timeseries <id>" becomes "customer timeseries <id>" or "thirdparty timeseries
+// <id>". ok is false when the arguments already name a command and are left alone.
+//
+// A command only one API has gets that API's prefix, and a command both have gets the
+// prefix of the API the refresh token was issued for: from the profile, or the token's
+// claims.
+func apiPrefixedArgs(args []string) (prefixed []string, ok bool, err error) {
+	if _, _, err := rootCmd.Find(args); err == nil {
+		return args, false, nil
+	}
+
+	var apis []string
+	var found *cobra.Command
+	for _, parent := range []*cobra.Command{customerCmd, thirdpartyCmd} {
+		if sub, _, err := parent.Find(args); err == nil && sub != parent {
+			apis = append(apis, parent.Name())
+			found = sub
+		}
+	}
+
+	switch len(apis) {
+	case 0:
+		return args, false, nil
+	case 1:
+		return append([]string{apis[0]}, args...), true, nil
+	}
+
+	api, err := detectAPI(found, args)
+	if err != nil {
+		return nil, false, err
+	}
+	return append([]string{api}, args...), true, nil
+}
+
+// detectAPI tells the API of the refresh token a command would run with. The flags are
+// parsed on cmd, which can be either API's instance of the command: they share the global
+// flags.
+func detectAPI(cmd *cobra.Command, args []string) (string, error) {
+	cmd.InitDefaultHelpFlag()
+	if err := cmd.ParseFlags(args); err != nil {
+		return "", err
+	}
+
+	// Help is the same for both APIs, and needs no token
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		return apiCustomer, nil
+	}
+
+	fail := func(reason error) (string, error) {
+		return "", fmt.Errorf("cannot tell whether '%s' is a customer or a thirdparty command: %w; name the API, e.g. 'go-eloverblik customer %s'", cmd.Name(), reason, cmd.Name())
+	}
+
+	// The token can be read from stdin only once, and the command needs it
+	flags := rootCmd.PersistentFlags()
+	if token, _ := flags.GetString("token"); token == "" {
+		if file, _ := flags.GetString("token-file"); file == "-" {
+			return fail(errors.New("the token is read from stdin"))
+		}
+	}
+
+	name, p, err := activeProfile(cmd)
+	if err != nil {
+		return fail(err)
+	}
+	token, fromProfile, err := resolveToken(cmd, name, p)
+	if err != nil {
+		return fail(err)
+	}
+	if fromProfile && slices.Contains([]string{apiCustomer, apiThirdParty}, p.API) {
+		return p.API, nil
+	}
+
+	claims, err := eloverblik.ParseToken(token)
+	if err != nil {
+		return fail(err)
+	}
+	api, err := claimsAPI(claims)
+	if err != nil {
+		return fail(err)
+	}
+	return api, nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiPrefixedArgs(t *testing.T) {
+	useTempConfig(t)
+	defer resetCommandFlags(rootCmd)
+
+	id := "571313174002485069"
+	customerToken := loginTestToken(t, "CUSTOMERAPI_Refresh", 365*24*time.Hour)
+	thirdPartyToken := loginTestToken(t, "THIRDPARTYAPI_Refresh", 365*24*time.Hour)
+
+	prefixed := func(args ...string) ([]string, bool, error) {
+		resetCommandFlags(rootCmd)
+		return apiPrefixedArgs(args)
+	}
+
+	t.Run("commands naming the API are left alone", func(t *testing.T) {
+		for _, args := range [][]string{{"customer", "details", id}, {"token"}, {"--help"}, {}} {
+			_, ok, err := prefixed(args...)
+			assert.NoError(t, err)
+			assert.False(t, ok, args)
+		}
+	})
+
+	t.Run("a command of one API gets its prefix", func(t *testing.T) {
+		args, ok, err := prefixed("installations")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"customer", "installations"}, args)
+
+		args, _, err = prefixed("authorizations", "--token", customerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"thirdparty", "authorizations", "--token", customerToken}, args, "the mismatch is reported when it runs")
+	})
+
+	t.Run("a command of both APIs gets the token's", func(t *testing.T) {
+		args, _, err := prefixed("timeseries", id, "--period", "yesterday", "--token", thirdPartyToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "thirdparty", args[0])
+
+		args, _, err = prefixed("details", "--token", customerToken, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "customer", args[0])
+	})
+
+	t.Run("the profile's API comes first", func(t *testing.T) {
+		assert.NoError(t, saveConfig(config{Current: "work", Profiles: map[string]profile{"work": {Token: "opaque", API: apiThirdParty}}}))
+		defer func() { assert.NoError(t, saveConfig(config{})) }()
+
+		args, _, err := prefixed("details", id)
+		assert.NoError(t, err)
+		assert.Equal(t, "thirdparty", args[0])
+	})
+
+	t.Run("an unreadable token names no API", func(t *testing.T) {
+		_, _, err := prefixed("details", id, "--token", "dummy")
+		assert.ErrorContains(t, err, "cannot tell whether 'details' is a customer or a thirdparty command")
+
+		_, _, err = prefixed("details", id, "--token-file", "-")
+		assert.ErrorContains(t, err, "read from stdin")
+	})
+
+	t.Run("help needs no token", func(t *testing.T) {
+		args, _, err := prefixed("timeseries", "--help")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"customer", "timeseries", "--help"}, args)
+	})
+
+	t.Run("unknown commands are left for cobra to report", func(t *testing.T) {
+		_, ok, err := prefixed("invalid-command")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("the prefixed command runs", func(t *testing.T) {
+		clientInstance = &MockClient{
+			GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+				return []eloverblik.MeteringPointDetailsResponse{{Result: eloverblik.MeteringPointDetail{MeteringPointID: meteringPointIDs[0]}}}, nil
+			},
+		}
+		defer func() { clientInstance = nil }()
+
+		oldOutput := output
+		var buf bytes.Buffer
+		output = &buf
+		defer func() { output = oldOutput }()
+
+		args, _, err := prefixed("details", id, "--token", thirdPartyToken)
+		assert.NoError(t, err)
+		_, err = execute(t, args...)
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), id)
+	})
+}
+
+func TestTokenAPIMismatch(t *testing.T) {
+	useTempConfig(t)
+	clientInstance = nil
+
+	_, err := execute(t, "thirdparty", "details", "571313174002485069", "--token", loginTestToken(t, "CUSTOMERAPI_Refresh", 365*24*time.Hour))
+	assert.ErrorContains(t, err, "the refresh token is a customer token, but the command is a thirdparty command: use the 'customer' subcommand")
+	assert.Nil(t, clientInstance, "no client is created")
+
+	assert.NoError(t, checkTokenAPI("dummy", apiCustomer), "an unreadable token is left for the API")
+}
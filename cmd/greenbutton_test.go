@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportGreenButtonCmd(t *testing.T) {
+	const id = "571313174002485069"
+	mock := &MockClient{
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			assert.Equal(t, []string{id}, meteringPointIDs)
+			return []eloverblik.TimeSeries{hourlySeries(id, time.Date(2026, 1, 1, 0, 0, 0, 0, eloverblik.Copenhagen), 24, 1)}, nil
+		},
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			return []eloverblik.MeteringPointDetailsResponse{{
+				Result:         eloverblik.MeteringPointDetail{MeteringPointID: id, MeteringPointAlias: "Home"},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	_, err := execute(t, "thirdparty", "export-greenbutton", id, "--from", "2026-01-01", "--token", "dummy")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, buf.String(), "<title>Home</title>")
+	assert.Equal(t, 24, bytes.Count(buf.Bytes(), []byte("<IntervalReading>")))
+
+	_, err = execute(t, "customer", "export-greenbutton", id, "--token", "dummy")
+	assert.ErrorContains(t, err, "either --period or --from is required")
+}
@@ -5,9 +5,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// clientOptions builds the library options from the persistent flags on the root command.
-func clientOptions(cmd *cobra.Command) []eloverblik.Option {
-	opts := make([]eloverblik.Option, 0, 1)
+// clientOptions builds the library options from the persistent flags on the root command
+// and the active profile.
+func clientOptions(cmd *cobra.Command, p profile) []eloverblik.Option {
+	opts := make([]eloverblik.Option, 0, 2)
+
+	if p.Environment == environPreprod {
+		opts = append(opts, eloverblik.WithPreprod())
+	}
 
 	if printHeaders, err := cmd.Root().PersistentFlags().GetBool("print-response-headers"); err == nil && printHeaders {
 		opts = append(opts, eloverblik.WithResponseHeaderOutput(headerOutput))
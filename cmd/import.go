@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+const (
+	importAuto       = "auto"
+	importTimeSeries = "timeseries"
+	importMasterdata = "masterdata"
+)
+
+// importedFile is a portal download read as either kind.
+type importedFile struct {
+	kind    string
+	series  []eloverblik.TimeSeries
+	details []eloverblik.MeteringPointDetail
+}
+
+// readImport reads a portal download of the given kind from a file, or from stdin when
+// the name is "-". auto reads it as time series, and as masterdata when it lacks their
+// columns.
+func readImport(name, kind string) (importedFile, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(name) // #nosec G304 -- the user names the file to import
+	}
+	if err != nil {
+		return importedFile{}, err
+	}
+
+	if kind != importMasterdata {
+		series, err := eloverblik.ReadPortalTimeSeries(bytes.NewReader(data))
+		if err == nil {
+			return importedFile{kind: importTimeSeries, series: series}, nil
+		}
+		if kind == importTimeSeries || !errors.Is(err, eloverblik.ErrPortalColumns) {
+			return importedFile{}, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	details, err := eloverblik.ReadPortalMasterdata(bytes.NewReader(data))
+	if err != nil {
+		if kind == importAuto {
+			return importedFile{}, fmt.Errorf("%s: neither a time series nor a masterdata download: %w", name, err)
+		}
+		return importedFile{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return importedFile{kind: importMasterdata, details: details}, nil
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file> [file ...]",
+	Short: "Read time series or masterdata downloaded from the Eloverblik portal",
+	Long: "Read files downloaded from eloverblik.dk, as CSV or Excel (xlsx), into the same shape the\n" +
+		"API returns, so they can be written in any output format: time series as with\n" +
+		"'timeseries', masterdata as with 'details'. Read stdin with -.\n\n" +
+		"Columns are matched by name. A time series download needs MålepunktsID, Fra_dato,\n" +
+		"Til_dato and Mængde, with times in Danish local time and a decimal comma; a masterdata\n" +
+		"download needs the metering point ID. Several files must be of the same kind.",
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		kind, _ := cmd.Flags().GetString("kind")
+		switch kind {
+		case importAuto, importTimeSeries, importMasterdata:
+			return nil
+		}
+		return fmt.Errorf("--kind must be %s, %s or %s", importAuto, importTimeSeries, importMasterdata)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind, _ := cmd.Flags().GetString("kind")
+		flatten, _ := cmd.Flags().GetBool("flatten")
+
+		var tss []eloverblik.TimeSeries
+		var details []eloverblik.MeteringPointDetailsResponse
+		for _, name := range args {
+			file, err := readImport(name, kind)
+			if err != nil {
+				return err
+			}
+			// The first file settles the kind of the rest.
+			if kind == importAuto {
+				kind = file.kind
+			}
+			tss = append(tss, file.series...)
+			for _, d := range file.details {
+				details = append(details, eloverblik.MeteringPointDetailsResponse{
+					Result:         d,
+					StatusResponse: eloverblik.StatusResponse{Success: true, ID: d.MeteringPointID},
+				})
+			}
+		}
+
+		if kind == importMasterdata {
+			return writeResult(cmd, result{value: details, table: detailsTable(details)})
+		}
+
+		series := func() []flatSeries { return flattenTimeSeries(tss) }
+		value := any(tss)
+		if flatten {
+			flattened := make(map[string][]eloverblik.FlatTimeSeriesPoint, len(tss))
+			for _, s := range series() {
+				flattened[s.meteringPointID] = append(flattened[s.meteringPointID], s.points...)
+			}
+			value = flattened
+		}
+		return writeResult(cmd, result{value: value, series: series})
+	},
+}
+
+func init() {
+	importCmd.Flags().String("kind", importAuto, "kind of download ("+importAuto+", "+importTimeSeries+", "+importMasterdata+")")
+	importCmd.Flags().Bool("flatten", false, "simplify the data series")
+	addOutputFormatFlag(importCmd)
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportCmd(t *testing.T) {
+	dir := t.TempDir()
+	timeSeriesFile := filepath.Join(dir, "timeseries.csv")
+	require.NoError(t, os.WriteFile(timeSeriesFile, []byte("\uFEFFMålepunktsID;Fra_dato;Til_dato;Mængde\n"+
+		"571313155411053087;01-02-2026 00:00:00;01-02-2026 01:00:00;0,198\n"+
+		"571313155411053087;01-02-2026 01:00:00;01-02-2026 02:00:00;0,196\n"), 0o600))
+	masterdataFile := filepath.Join(dir, "masterdata.csv")
+	require.NoError(t, os.WriteFile(masterdataFile, []byte("MålepunktsID;Type;Netselskab\n571313155411053087;Forbrug;Konstant Net A/S\n"), 0o600))
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	t.Run("time series", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "import", timeSeriesFile)
+		require.NoError(t, err)
+
+		var tss []eloverblik.TimeSeries
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &tss))
+		require.Len(t, tss, 1)
+		assert.Equal(t, "571313155411053087", tss[0].MeteringPointID())
+		assert.Len(t, tss[0].Flatten(), 2)
+	})
+
+	t.Run("time series as csv", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "import", timeSeriesFile, "-o", "csv")
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, "571313155411053087,2026-02-01T00:00:00+01:00,2026-02-01T01:00:00+01:00,0.198,,KWH,,,PT1H", lines[1])
+	})
+
+	t.Run("masterdata from stdin", func(t *testing.T) {
+		data, err := os.ReadFile(masterdataFile)
+		require.NoError(t, err)
+		oldStdin := stdin
+		stdin = bytes.NewReader(data)
+		defer func() { stdin = oldStdin }()
+
+		buf.Reset()
+		_, err = execute(t, "import", "-")
+		require.NoError(t, err)
+
+		var details []eloverblik.MeteringPointDetailsResponse
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &details))
+		require.Len(t, details, 1)
+		assert.True(t, details[0].Success)
+		assert.Equal(t, "E17", details[0].Result.TypeOfMP)
+		assert.Equal(t, "Konstant Net A/S", details[0].Result.GridOperatorName)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := execute(t, "import", timeSeriesFile, masterdataFile)
+		assert.ErrorContains(t, err, "masterdata.csv: not a time series download: missing columns fradato, tildato, mængde")
+
+		_, err = execute(t, "import", masterdataFile, "--kind", "timeseries")
+		assert.ErrorIs(t, err, eloverblik.ErrPortalColumns)
+
+		_, err = execute(t, "import", timeSeriesFile, "--kind", "readings")
+		assert.ErrorContains(t, err, "--kind must be auto, timeseries or masterdata")
+
+		_, err = execute(t, "import", filepath.Join(dir, "missing.csv"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
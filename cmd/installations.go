@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/slimcdk/go-eloverblik/v1"
@@ -27,14 +26,15 @@ If 'include-all' is 'true', the list is merged with additional non-linked meteri
 		meters, err := customerAPI.GetMeteringPoints(includeAll)
 		cobra.CheckErr(err)
 
-		bytes, err := json.Marshal(meters)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeResult(cmd, result{
+			value: meters,
+			table: installationsTable(meters),
+		}))
 	},
 }
 
 func init() {
 	installationsCmd.Flags().Bool("include-all", false, "Include metering points not actively linked to the user")
+	addOutputFormatFlag(installationsCmd)
 	customerCmd.AddCommand(installationsCmd)
 }
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	eloverblik "github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// messageOutput is the destination for notices and warnings meant for the user rather
+// than for a parser (configurable for testing). It defaults to stderr so stdout stays
+// clean, parseable JSON.
+var messageOutput io.Writer = os.Stderr
+
+// expiryWarning is how long before a refresh token expires the CLI starts warning about it.
+const expiryWarning = 30 * 24 * time.Hour
+
+// defaultProfile is the profile 'login' stores the token under when none is named or current.
+const defaultProfile = "default"
+
+// warnTokenExpiry warns when a refresh token has expired or will within expiryWarning.
+func warnTokenExpiry(w io.Writer, claims eloverblik.TokenClaims) {
+	const renew = "create a new one in the Eloverblik portal and run 'go-eloverblik login'"
+
+	switch {
+	case claims.ExpiresAt.IsZero():
+	case claims.IsExpired():
+		_, _ = fmt.Fprintf(w, "warning: the refresh token expired on %s; %s\n", claims.ExpiresAt.Format(time.DateOnly), renew)
+	case claims.ExpiresIn() < expiryWarning:
+		days := int(claims.ExpiresIn().Hours() / 24)
+		_, _ = fmt.Fprintf(w, "warning: the refresh token expires in %d days, on %s; %s\n", days, claims.ExpiresAt.Format(time.DateOnly), renew)
+	}
+}
+
+// storeDescription names a token store in messages to the user.
+func storeDescription(kind string) string {
+	if kind == storeFile {
+		if path, err := tokenFilePath(); err == nil {
+			return "encrypted token file " + path
+		}
+		return "encrypted token file"
+	}
+	return "OS keyring"
+}
+
+// storeToken puts a profile's token in the store kind names, and returns the store used.
+// auto picks the OS keyring, and on Linux falls back to the encrypted file when there is no
+// secret service to talk to, as on a headless server. Any other error of the keyring, such
+// as a locked keyring or a declined prompt, is returned.
+func storeToken(name, token, kind string) (string, error) {
+	if kind != "auto" {
+		store, err := newSecretStore(kind)
+		if err != nil {
+			return "", err
+		}
+		return kind, store.set(name, token)
+	}
+
+	err := keyringStore{}.set(name, token)
+	if err == nil {
+		return storeKeyring, nil
+	}
+	if runtime.GOOS != "linux" || !noSecretService(err) {
+		return "", fmt.Errorf("cannot store the token in the OS keyring: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(messageOutput, "The OS keyring is not available (%v), using the encrypted token file instead.\n", err)
+	return storeFile, fileStore{}.set(name, token)
+}
+
+// forgetToken removes the token a profile keeps in a secret store, if any.
+func forgetToken(name string, p profile) error {
+	if p.TokenStore == "" {
+		return nil
+	}
+	store, err := newSecretStore(p.TokenStore)
+	if err != nil {
+		return err
+	}
+	if err := store.remove(name); err != nil && !errors.Is(err, errNoSecret) {
+		return fmt.Errorf("cannot remove the token of profile '%s' from the %s: %w", name, storeDescription(p.TokenStore), err)
+	}
+	return nil
+}
+
+// loginToken returns the token to log in with: the one given with --token, --token-file or
+// $ELOVERBLIK_TOKEN, or else one typed at the terminal without echo, or piped to stdin.
+func loginToken(cmd *cobra.Command) (string, error) {
+	if token, _, err := resolveToken(cmd, "", profile{}); err == nil {
+		return token, nil
+	} else if file, _ := cmd.Root().PersistentFlags().GetString("token-file"); file != "" {
+		return "", err
+	}
+
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) { // #nosec G115 -- a file descriptor fits an int
+		_, _ = fmt.Fprint(messageOutput, "Refresh token: ")
+		token, err := term.ReadPassword(int(f.Fd())) // #nosec G115
+		_, _ = fmt.Fprintln(messageOutput)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	return readTokenFile("-")
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login [profile]",
+	Short: "Store a refresh token in the OS keyring",
+	Long: `Store a refresh token in the OS keyring under a profile, so every other command can use
+it without the token being kept in plain text: in the configuration file, the environment,
+shell history or process listings.
+
+The token is read with --token-file, from a file or stdin (-), or typed at the prompt.
+It is checked to be an unexpired refresh token, and the profile's api is taken from it.
+The profile is the one named, else the active one, else "default"; the first profile
+created becomes the current one.
+
+The keyring is the Keychain on macOS, the Credential Manager on Windows and the Secret
+Service (GNOME Keyring, KWallet) on Linux. A Linux machine without one, such as a
+headless server, gets an encrypted token file instead, sealed with a passphrase that is
+asked for, or read from $` + envPassphrase + `, whenever the token is used.
+
+  go-eloverblik login home
+  go-eloverblik login home --token-file token.txt --store file`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		name := cfg.Current
+		if len(args) == 1 {
+			name = args[0]
+		} else if flagged, _ := cmd.Root().PersistentFlags().GetString("profile"); flagged != "" {
+			name = flagged
+		} else if env := os.Getenv(envProfile); env != "" {
+			name = env
+		}
+		if name == "" {
+			name = defaultProfile
+		}
+
+		token, err := loginToken(cmd)
+		if err != nil {
+			return err
+		}
+
+		claims, err := eloverblik.ParseToken(token)
+		if err != nil {
+			return fmt.Errorf("not an Eloverblik token: %w", err)
+		}
+		if claims.IsDataAccessToken() {
+			return fmt.Errorf("this is a data access token, log in with a refresh token from the Eloverblik portal")
+		}
+		if claims.IsExpired() {
+			return fmt.Errorf("the token expired on %s", claims.ExpiresAt.Format(time.DateOnly))
+		}
+
+		kind, _ := cmd.Flags().GetString("store")
+		kind, err = storeToken(name, token, kind)
+		if err != nil {
+			return err
+		}
+
+		p := cfg.Profiles[name]
+		if p.TokenStore != "" && p.TokenStore != kind {
+			if err := forgetToken(name, p); err != nil {
+				return err
+			}
+		}
+		p.Token = ""
+		p.TokenStore = kind
+		if api, err := claimsAPI(claims); err == nil {
+			p.API = api
+		}
+
+		cfg.Profiles[name] = p
+		if cfg.Current == "" {
+			cfg.Current = name
+		}
+		if err := saveConfig(cfg); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(messageOutput, "Stored the refresh token of profile '%s' in the %s.\n", name, storeDescription(kind))
+		warnTokenExpiry(messageOutput, claims)
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout [profile]",
+	Short: "Remove a profile's refresh token",
+	Long: `Remove the refresh token of a profile, the one named or else the active one, from the OS
+keyring or encrypted token file, and from the configuration file. The rest of the profile
+is kept.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		} else if name, _, err = activeProfile(cmd); err != nil {
+			return err
+		}
+
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("no profile named '%s' in the config file", name)
+		}
+		if err := forgetToken(name, p); err != nil {
+			return err
+		}
+
+		p.Token = ""
+		p.TokenStore = ""
+		cfg.Profiles[name] = p
+		return saveConfig(cfg)
+	},
+}
+
+func init() {
+	loginCmd.Flags().String("store", "auto", "where to keep the token (auto, keyring, file)")
+
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	eloverblik "github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando/go-keyring"
+)
+
+// loginTestToken builds an unsigned refresh token that expires after the given duration.
+func loginTestToken(t *testing.T, tokenType string, expiresIn time.Duration) string {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"tokenType": tokenType,
+		"exp":       time.Now().Add(expiresIn).Unix(),
+	})
+	assert.NoError(t, err)
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+// usePassphrase makes the encrypted token file use a fixed passphrase.
+func usePassphrase(t *testing.T, passphrase string) {
+	t.Helper()
+	old := readPassphrase
+	readPassphrase = func() (string, error) { return passphrase, nil }
+	t.Cleanup(func() { readPassphrase = old })
+}
+
+func TestLogin(t *testing.T) {
+	useTempConfig(t)
+	keyring.MockInit()
+
+	var messages bytes.Buffer
+	oldMessages := messageOutput
+	messageOutput = &messages
+	defer func() { messageOutput = oldMessages }()
+
+	oldStdin := stdin
+	defer func() { stdin = oldStdin }()
+
+	token := loginTestToken(t, "CUSTOMERAPI_Refresh", 365*24*time.Hour)
+
+	t.Run("stores the token in the keyring, not the config file", func(t *testing.T) {
+		stdin = strings.NewReader(token + "\n")
+		_, err := execute(t, "login", "home")
+		assert.NoError(t, err)
+		assert.Contains(t, messages.String(), "in the OS keyring")
+
+		stored, err := keyring.Get(keyringService, "home")
+		assert.NoError(t, err)
+		assert.Equal(t, token, stored)
+
+		path, _ := configPath()
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), token)
+
+		cfg, _ := loadConfig()
+		assert.Equal(t, "home", cfg.Current)
+		assert.Equal(t, profile{TokenStore: storeKeyring, API: apiCustomer}, cfg.Profiles["home"])
+	})
+
+	t.Run("other commands resolve the token from the keyring", func(t *testing.T) {
+		resetCommandFlags(rootCmd)
+		resolved, p, err := setupClient(rootCmd, apiCustomer)
+		assert.NoError(t, err)
+		assert.Equal(t, token, resolved)
+		assert.Equal(t, storeKeyring, p.TokenStore)
+	})
+
+	t.Run("rejects a data access token", func(t *testing.T) {
+		stdin = strings.NewReader(loginTestToken(t, "CustomerApiDataAccess", time.Hour))
+		_, err := execute(t, "login", "home")
+		assert.ErrorContains(t, err, "data access token")
+	})
+
+	t.Run("rejects an expired token", func(t *testing.T) {
+		stdin = strings.NewReader(loginTestToken(t, "CUSTOMERAPI_Refresh", -time.Hour))
+		_, err := execute(t, "login", "home")
+		assert.ErrorContains(t, err, "the token expired")
+	})
+
+	t.Run("moving to the file store clears the keyring", func(t *testing.T) {
+		usePassphrase(t, "correct horse")
+		stdin = strings.NewReader(token)
+		_, err := execute(t, "login", "home", "--store", "file")
+		assert.NoError(t, err)
+
+		_, err = keyring.Get(keyringService, "home")
+		assert.ErrorIs(t, err, keyring.ErrNotFound)
+
+		resolved, _, err := resolveToken(rootCmd, "home", profile{TokenStore: storeFile})
+		assert.NoError(t, err)
+		assert.Equal(t, token, resolved)
+	})
+
+	t.Run("logout removes the token but keeps the profile", func(t *testing.T) {
+		_, err := execute(t, "logout", "home")
+		assert.NoError(t, err)
+
+		cfg, _ := loadConfig()
+		assert.Equal(t, profile{API: apiCustomer}, cfg.Profiles["home"])
+
+		sealed, err := fileStore{}.load()
+		assert.NoError(t, err)
+		assert.Empty(t, sealed)
+	})
+}
+
+func TestStoreTokenAuto(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only Linux falls back to the encrypted token file")
+	}
+	useTempConfig(t)
+	usePassphrase(t, "correct horse")
+	defer keyring.MockInit()
+
+	var messages bytes.Buffer
+	oldMessages := messageOutput
+	messageOutput = &messages
+	defer func() { messageOutput = oldMessages }()
+
+	t.Run("no secret service falls back to the file", func(t *testing.T) {
+		keyring.MockInitWithError(dbus.NewError("org.freedesktop.DBus.Error.ServiceUnknown", []any{"The name org.freedesktop.secrets was not provided"}))
+		kind, err := storeToken("home", "secret-token", "auto")
+		assert.NoError(t, err)
+		assert.Equal(t, storeFile, kind)
+
+		token, err := fileStore{}.get("home")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-token", token)
+	})
+
+	t.Run("a locked keyring is an error", func(t *testing.T) {
+		keyring.MockInitWithError(dbus.NewError("org.freedesktop.Secret.Error.IsLocked", []any{"Cannot unlock the collection"}))
+		_, err := storeToken("work", "secret-token", "auto")
+		assert.ErrorContains(t, err, "cannot store the token in the OS keyring")
+
+		_, err = fileStore{}.get("work")
+		assert.ErrorIs(t, err, errNoSecret)
+	})
+}
+
+func TestNoSecretService(t *testing.T) {
+	for err, want := range map[error]bool{
+		errors.New("dbus: couldn't determine address of session bus"):                  true,
+		&exec.Error{Name: "dbus-launch", Err: exec.ErrNotFound}:                        true,
+		&net.OpError{Op: "dial", Net: "unix", Err: os.ErrNotExist}:                     true,
+		dbus.NewError("org.freedesktop.DBus.Error.ServiceUnknown", nil):                true,
+		dbus.NewError("org.freedesktop.DBus.Error.Spawn.ServiceNotFound", nil):         true,
+		dbus.NewError("org.freedesktop.Secret.Error.IsLocked", nil):                    false,
+		errors.New("failed to unlock correct collection '/org/freedesktop/secrets/x'"): false,
+	} {
+		assert.Equal(t, want, noSecretService(err), "%v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	useTempConfig(t)
+	usePassphrase(t, "correct horse")
+
+	store := fileStore{}
+	assert.NoError(t, store.set("home", "secret-token"))
+
+	path, err := tokenFilePath()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	token, err := store.get("home")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-token", token)
+
+	_, err = store.get("work")
+	assert.ErrorIs(t, err, errNoSecret)
+
+	t.Run("a wrong passphrase does not decrypt", func(t *testing.T) {
+		usePassphrase(t, "battery staple")
+		_, err := store.get("home")
+		assert.ErrorContains(t, err, "wrong passphrase")
+	})
+
+	t.Run("a token moved to another profile does not decrypt", func(t *testing.T) {
+		sealed, _ := store.load()
+		sealed["work"] = sealed["home"]
+		assert.NoError(t, store.save(sealed))
+
+		_, err := store.get("work")
+		assert.Error(t, err)
+	})
+}
+
+func TestWarnTokenExpiry(t *testing.T) {
+	var buf bytes.Buffer
+
+	for name, tc := range map[string]struct {
+		expiresIn time.Duration
+		want      string
+	}{
+		"far from expiry": {expiresIn: 200 * 24 * time.Hour, want: ""},
+		"close to expiry": {expiresIn: 10*24*time.Hour + time.Hour, want: "expires in 10 days"},
+		"expired":         {expiresIn: -time.Hour, want: "expired on"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+			claims, err := eloverblik.ParseToken(loginTestToken(t, "CUSTOMERAPI_Refresh", tc.expiresIn))
+			assert.NoError(t, err)
+			warnTokenExpiry(&buf, claims)
+			if tc.want == "" {
+				assert.Empty(t, buf.String())
+			} else {
+				assert.Contains(t, buf.String(), tc.want)
+			}
+		})
+	}
+}
@@ -23,9 +23,15 @@ func meteringPointArgs(cmd *cobra.Command, args []string) error {
 	if err := cobra.MinimumNArgs(1)(cmd, args); err != nil {
 		return err
 	}
-	if err := cobra.MaximumNArgs(10)(cmd, args); err != nil {
+	if err := cobra.MaximumNArgs(eloverblik.MaxMeteringPointsPerRequest)(cmd, args); err != nil {
 		return err
 	}
+	return meteringPointIDArgs(args)
+}
+
+// meteringPointIDArgs checks that every argument looks like a metering point ID, however
+// many there are.
+func meteringPointIDArgs(args []string) error {
 	for i, id := range args {
 		if _, err := strconv.Atoi(id); len(id) != 18 || err != nil {
 			return fmt.Errorf("provided metering id (number %d) looks like an invalid id: %s", i, id)
@@ -130,19 +136,18 @@ func outputStream(stream io.ReadCloser, format string) error {
 }
 
 func newDetailsCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "details <metering-id> [metering-id ...]",
 		Short: "Get metering point details",
 		Args:  meteringPointArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			details, err := clientInstance.GetMeteringPointDetails(args)
 			cobra.CheckErr(err)
-			bytes, err := json.Marshal(details)
-			cobra.CheckErr(err)
-			_, err = output.Write(bytes)
-			cobra.CheckErr(err)
+			cobra.CheckErr(writeResult(cmd, result{value: details, table: detailsTable(details)}))
 		},
 	}
+	addOutputFormatFlag(cmd)
+	return cmd
 }
 
 func newTimeseriesCmd() *cobra.Command {
@@ -188,22 +193,18 @@ func newTimeseriesCmd() *cobra.Command {
 			tss, err := clientInstance.GetTimeSeries(args, from, to, eloverblik.Aggregation(aggregation))
 			cobra.CheckErr(err)
 
-			if !flatten {
-				bytes, err := json.Marshal(tss)
-				cobra.CheckErr(err)
-				_, err = output.Write(bytes)
-				cobra.CheckErr(err)
-			} else {
-				flattened := make(map[string][]eloverblik.FlatTimeSeriesPoint, len(args))
-				for _, ts := range tss {
-					id := ts.MyEnergyDataMarketDocument.TimeSeries[0].MRID
-					flattened[id] = ts.Flatten()
+			// Every format but json is a stream of flat points, which is what --flatten
+			// asks for; json keeps the raw response unless --flatten is given.
+			series := func() []flatSeries { return flattenTimeSeries(tss) }
+			value := any(tss)
+			if flatten {
+				flattened := make(map[string][]eloverblik.FlatTimeSeriesPoint, len(tss))
+				for _, s := range series() {
+					flattened[s.meteringPointID] = s.points
 				}
-				bytes, err := json.Marshal(flattened)
-				cobra.CheckErr(err)
-				_, err = output.Write(bytes)
-				cobra.CheckErr(err)
+				value = flattened
 			}
+			cobra.CheckErr(writeResult(cmd, result{value: value, series: series}))
 		},
 	}
 	cmd.Flags().String("from", "", "start date (YYYY-MM-DD, now, now-30d/w/m/y)")
@@ -211,6 +212,7 @@ func newTimeseriesCmd() *cobra.Command {
 	cmd.Flags().String("period", "", "predefined period (yesterday, last_week, etc.)")
 	cmd.Flags().String("aggregation", string(eloverblik.Hour), "aggregation level (Actual, Quarter, Hour, Day, Month, Year)")
 	cmd.Flags().Bool("flatten", false, "simplify the data series")
+	addOutputFormatFlag(cmd)
 	return cmd
 }
 
@@ -282,6 +282,7 @@ type MockClient struct {
 	eloverblik.Client
 	GetMeteringPointDetailsFunc func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error)
 	GetTimeSeriesFunc           func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error)
+	GetMeterReadingsFunc        func(meteringPointIDs []string, from, to time.Time) ([]eloverblik.MeterReadingsResponse, error)
 	ExportTimeSeriesFunc        func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) (io.ReadCloser, error)
 	ExportMasterdataFunc        func(meteringPointIDs []string) (io.ReadCloser, error)
 }
@@ -323,6 +324,13 @@ func (m *MockClient) GetTimeSeries(meteringPointIDs []string, from, to time.Time
 	return nil, nil
 }
 
+func (m *MockClient) GetMeterReadings(meteringPointIDs []string, from, to time.Time) ([]eloverblik.MeterReadingsResponse, error) {
+	if m.GetMeterReadingsFunc != nil {
+		return m.GetMeterReadingsFunc(meteringPointIDs, from, to)
+	}
+	return nil, nil
+}
+
 func (m *MockClient) ExportTimeSeries(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) (io.ReadCloser, error) {
 	if m.ExportTimeSeriesFunc != nil {
 		return m.ExportTimeSeriesFunc(meteringPointIDs, from, to, aggregation)
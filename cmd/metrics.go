@@ -0,0 +1,456 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultMetricsAddress is the address serve-metrics listens on by default.
+	defaultMetricsAddress = ":9756"
+	// defaultMetricsInterval is how often serve-metrics fetches by default. DataHub
+	// receives the time series of most meters once a day, so more often finds nothing new.
+	defaultMetricsInterval = time.Hour
+	// minPollInterval keeps the commands that poll the API, serve-metrics and
+	// publish-mqtt, well within the 120 calls per minute it allows.
+	minPollInterval = 5 * time.Minute
+	// defaultPollDays is how many days of time series the commands that poll fetch. The
+	// data arrives a day or two late, so the latest day alone is often empty.
+	defaultPollDays = 3
+)
+
+// meteringPointMetrics is what the exporter knows about a metering point.
+type meteringPointMetrics struct {
+	details *eloverblik.MeteringPointDetail
+
+	// latest is the end of the latest hour with data, zero when none was found. unit is
+	// the unit of its quantity.
+	latest   time.Time
+	unit     string
+	lastHour float64
+	// lastDay is the total of the latest Danish day with data for all of its hours.
+	lastDay      float64
+	lastDayFound bool
+}
+
+// metricsExporter fetches the time series and details of metering points on an interval,
+// and serves the latest of them to Prometheus. A scrape never calls the API: it reads what
+// the last refresh left, so the API is called as often as the interval says, however often
+// Prometheus scrapes. The client is replaced when its data access token expires.
+type metricsExporter struct {
+	clients *clientSource
+	// meteringPointIDs lists the metering points to fetch.
+	meteringPointIDs func() ([]string, error)
+	days             int
+	// tokenExpiry is when the refresh token expires, zero when unknown.
+	tokenExpiry time.Time
+	now         func() time.Time
+
+	mu          sync.Mutex
+	points      map[string]*meteringPointMetrics
+	apiErrors   map[string]int
+	refreshes   int
+	lastRefresh time.Time
+	lastOK      bool
+}
+
+func newMetricsExporter(clients *clientSource, meteringPointIDs func() ([]string, error), days int) *metricsExporter {
+	return &metricsExporter{
+		clients:          clients,
+		meteringPointIDs: meteringPointIDs,
+		days:             days,
+		now:              time.Now,
+		points:           make(map[string]*meteringPointMetrics),
+		apiErrors:        make(map[string]int),
+	}
+}
+
+// refresh fetches the metering points' details and time series. A failed call, or an item
+// the API failed for, is counted under its operation and leaves the values of the
+// metering points it was for as they were, so that the data lag shows how stale they are.
+func (e *metricsExporter) refresh() error {
+	var errs []error
+	fail := func(operation string, err error) {
+		e.mu.Lock()
+		e.apiErrors[operation]++
+		e.mu.Unlock()
+		errs = append(errs, fmt.Errorf("%s: %w", operation, err))
+	}
+
+	ids, err := e.meteringPointIDs()
+	if err != nil {
+		fail("meteringpoints", err)
+	}
+
+	from, to := pollWindow(e.now(), e.days)
+
+	for batch := range slices.Chunk(ids, eloverblik.MaxMeteringPointsPerRequest) {
+		client := e.clients.get()
+		details, err := client.GetMeteringPointDetails(batch)
+		if err != nil {
+			fail("details", err)
+		}
+		series, err := client.GetTimeSeries(batch, from, to, eloverblik.Hour)
+		if err != nil {
+			fail("timeseries", err)
+		}
+
+		for _, d := range details {
+			if err := d.Err(); err != nil {
+				fail("details", fmt.Errorf("metering point %s: %w", cmp.Or(d.ID, d.Result.MeteringPointID), err))
+				continue
+			}
+			e.mu.Lock()
+			e.point(d.Result.MeteringPointID).details = &d.Result
+			e.mu.Unlock()
+		}
+		for _, ts := range series {
+			if err := ts.Err(); err != nil {
+				fail("timeseries", fmt.Errorf("metering point %s: %w", cmp.Or(ts.ID, ts.MeteringPointID()), err))
+				continue
+			}
+			e.mu.Lock()
+			e.point(ts.MeteringPointID()).observe(ts.Flatten())
+			e.mu.Unlock()
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshes++
+	e.lastRefresh = e.now()
+	e.lastOK = len(errs) == 0
+	return errors.Join(errs...)
+}
+
+// pollWindow returns the days of time series to fetch: the given number of whole Danish
+// days before today, which the API has no data for yet.
+func pollWindow(now time.Time, days int) (from, to time.Time) {
+	today := now.In(eloverblik.Copenhagen)
+	to = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, eloverblik.Copenhagen)
+	return to.AddDate(0, 0, -days), to
+}
+
+// meteringPointLister returns the lister of the metering points a long-running command
+// fetches: those given, or else every one the token of the API has access to, listed anew
+// on every refresh. A third party may get part of them along with an error.
+func meteringPointLister(api string, clients *clientSource, ids []string) func() ([]string, error) {
+	if len(ids) > 0 {
+		return func() ([]string, error) { return ids, nil }
+	}
+
+	if api == apiThirdParty {
+		return func() ([]string, error) {
+			thirdpartyAPI, ok := clients.get().(eloverblik.ThirdParty)
+			if !ok {
+				return nil, errors.New("the client cannot list the metering points of authorizations")
+			}
+			portfolio, err := eloverblik.LoadPortfolio(thirdpartyAPI, 0)
+			if portfolio == nil {
+				return nil, err
+			}
+			return portfolio.IDs(), err
+		}
+	}
+
+	return func() ([]string, error) {
+		customerAPI, ok := clients.get().(eloverblik.Customer)
+		if !ok {
+			return nil, errors.New("the client cannot list installations")
+		}
+		points, err := customerAPI.GetMeteringPoints(false)
+		ids := make([]string, 0, len(points))
+		for _, point := range points {
+			ids = append(ids, point.MeteringPointID)
+		}
+		return ids, err
+	}
+}
+
+// point returns the metrics of a metering point, adding it when it is new. The caller
+// holds the lock.
+func (e *metricsExporter) point(id string) *meteringPointMetrics {
+	point, ok := e.points[id]
+	if !ok {
+		point = &meteringPointMetrics{}
+		e.points[id] = point
+	}
+	return point
+}
+
+// observe takes the latest hour and the latest whole day out of hourly points. A day
+// missing an hour is not whole. Nothing changes when there are no points at all.
+func (m *meteringPointMetrics) observe(points []eloverblik.FlatTimeSeriesPoint) {
+	if len(points) == 0 {
+		return
+	}
+	slices.SortFunc(points, func(a, b eloverblik.FlatTimeSeriesPoint) int {
+		return a.From.Compare(b.From)
+	})
+
+	latest := points[len(points)-1]
+	m.latest = latest.To
+	m.unit = latest.Unit
+	m.lastHour = latest.Measurement
+
+	// The latest whole day is the latest Danish day with points for all of its 23, 24 or
+	// 25 hours, searching back from the day the latest point ends in
+	m.lastDay, m.lastDayFound = 0, false
+	last := latest.To.In(eloverblik.Copenhagen)
+	end := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, eloverblik.Copenhagen)
+	for ; end.After(points[0].From); end = end.AddDate(0, 0, -1) {
+		start := end.AddDate(0, 0, -1)
+		var total float64
+		var covered time.Duration
+		for _, p := range points {
+			if !p.From.Before(start) && !p.To.After(end) {
+				total += p.Measurement
+				covered += p.To.Sub(p.From)
+			}
+		}
+		if covered == end.Sub(start) {
+			m.lastDay, m.lastDayFound = total, true
+			return
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = e.write(w)
+}
+
+func (e *metricsExporter) write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	m := &metricsWriter{w: w}
+
+	var info, lastHour, lastDay, latest, lag []metricSample
+	for _, id := range slices.Sorted(maps.Keys(e.points)) {
+		point := e.points[id]
+		labels := labelPairs("metering_point_id", id)
+
+		if d := point.details; d != nil {
+			info = append(info, metricSample{labelPairs(
+				"metering_point_id", id,
+				"type", d.TypeOfMP,
+				"settlement_method", d.SettlementMethod,
+				"grid_area", d.MeteringGridAreaIdentification,
+				"grid_operator", d.GridOperatorName,
+			), 1})
+		}
+		if point.latest.IsZero() {
+			continue
+		}
+
+		// Production and exchange points report quantities too, and not all in kWh, so
+		// the quantities carry the type and unit of the metering point
+		var typeOfMP string
+		if point.details != nil {
+			typeOfMP = point.details.TypeOfMP
+		}
+		quantity := labelPairs("metering_point_id", id, "type", typeOfMP, "unit", point.unit)
+		lastHour = append(lastHour, metricSample{quantity, point.lastHour})
+		if point.lastDayFound {
+			lastDay = append(lastDay, metricSample{quantity, point.lastDay})
+		}
+		latest = append(latest, metricSample{labels, float64(point.latest.Unix())})
+		lag = append(lag, metricSample{labels, now.Sub(point.latest).Seconds()})
+	}
+
+	m.family("eloverblik_metering_point_info", "gauge", "Master data of the metering point, in its labels.", info)
+	m.family("eloverblik_last_hour_quantity", "gauge", "Quantity of the latest hour with data, in its unit.", lastHour)
+	m.family("eloverblik_last_day_quantity", "gauge", "Total quantity of the latest Danish day with data for every hour, in its unit.", lastDay)
+	m.family("eloverblik_latest_data_timestamp_seconds", "gauge", "End of the latest hour with data, as a Unix time.", latest)
+	m.family("eloverblik_data_lag_seconds", "gauge", "Time since the end of the latest hour with data.", lag)
+
+	if !e.tokenExpiry.IsZero() {
+		m.family("eloverblik_refresh_token_expiry_timestamp_seconds", "gauge", "Expiry of the refresh token, as a Unix time.",
+			[]metricSample{{"", float64(e.tokenExpiry.Unix())}})
+	}
+
+	var apiErrors []metricSample
+	for _, operation := range slices.Sorted(maps.Keys(e.apiErrors)) {
+		apiErrors = append(apiErrors, metricSample{labelPairs("operation", operation), float64(e.apiErrors[operation])})
+	}
+	m.family("eloverblik_api_errors_total", "counter", "Failed calls to the API, after retries, by operation.", apiErrors)
+
+	m.family("eloverblik_refreshes_total", "counter", "Refreshes of the data from the API.", []metricSample{{"", float64(e.refreshes)}})
+	if !e.lastRefresh.IsZero() {
+		success := 0.0
+		if e.lastOK {
+			success = 1
+		}
+		m.family("eloverblik_last_refresh_timestamp_seconds", "gauge", "Time of the latest refresh, as a Unix time.",
+			[]metricSample{{"", float64(e.lastRefresh.Unix())}})
+		m.family("eloverblik_last_refresh_success", "gauge", "Whether every call of the latest refresh succeeded.",
+			[]metricSample{{"", success}})
+	}
+
+	return m.err
+}
+
+// metricSample is a sample of a metric family, with its labels already formatted.
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+// metricsWriter writes metric families in the Prometheus text format, keeping the first
+// write error.
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// family writes a metric family. A family without samples is left out.
+func (m *metricsWriter) family(name, kind, help string, samples []metricSample) {
+	if m.err != nil || len(samples) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, s := range samples {
+		fmt.Fprintf(&b, "%s%s %s\n", name, s.labels, strconv.FormatFloat(s.value, 'f', -1, 64))
+	}
+	_, m.err = io.WriteString(m.w, b.String())
+}
+
+// labelValueEscaper escapes a label value of the Prometheus text format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelPairs formats name and value pairs as the labels of a sample. No pairs make no
+// labels.
+func labelPairs(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], labelValueEscaper.Replace(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// serveMetrics refreshes the exporter on the interval and serves it on the address until
+// the context is done.
+func serveMetrics(ctx context.Context, exporter *metricsExporter, address string, interval time.Duration) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := exporter.refresh(); err != nil {
+				_, _ = fmt.Fprintf(messageOutput, "refreshing metrics: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	_, _ = fmt.Fprintf(messageOutput, "Serving metrics on %s/metrics, refreshing every %s\n", address, interval)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServeMetricsCmd builds a fresh "serve-metrics" command for the parent of an API, as
+// newAliveCmd does.
+func newServeMetricsCmd(api string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve-metrics [metering point ids...]",
+		Short: "Serve time series metrics to Prometheus",
+		Long: `Fetch the latest time series and details of metering points on an interval, and serve them
+on /metrics in the Prometheus text format. Without metering point IDs, every metering point
+the token has access to is fetched: a customer's installations, or every metering point of
+a third party's authorizations.
+
+Prometheus scrapes the values of the latest refresh, so the API is called once per
+--interval however often it scrapes. The interval cannot be shorter than 5 minutes.
+
+Metrics:
+  eloverblik_last_hour_quantity              quantity of the latest hour with data,
+                                             labelled with the type and unit
+  eloverblik_last_day_quantity               total of the latest whole day with data
+  eloverblik_latest_data_timestamp_seconds   end of the latest hour with data
+  eloverblik_data_lag_seconds                time since the latest hour with data
+  eloverblik_metering_point_info             master data, in the labels
+  eloverblik_refresh_token_expiry_timestamp_seconds
+  eloverblik_api_errors_total                failed calls, by operation
+  eloverblik_refreshes_total, eloverblik_last_refresh_timestamp_seconds,
+  eloverblik_last_refresh_success`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			return meteringPointIDArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			address, _ := cmd.Flags().GetString("listen")
+			interval, _ := cmd.Flags().GetDuration("interval")
+			days, _ := cmd.Flags().GetInt("days")
+
+			if interval < minPollInterval {
+				return fmt.Errorf("--interval must be at least %s, to stay within the rate limits of the API", minPollInterval)
+			}
+			if days < 1 {
+				return errors.New("--days must be at least 1")
+			}
+
+			clients := newClientSource(clientInstance, buildClient)
+			exporter := newMetricsExporter(clients, meteringPointLister(api, clients, args), days)
+			if claims, err := refreshTokenClaims(cmd); err == nil {
+				exporter.tokenExpiry = claims.ExpiresAt
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serveMetrics(ctx, exporter, address, interval)
+		},
+	}
+
+	cmd.Flags().String("listen", defaultMetricsAddress, "Address to serve /metrics on")
+	cmd.Flags().Duration("interval", defaultMetricsInterval, "Time between refreshes of the data from the API")
+	cmd.Flags().Int("days", defaultPollDays, "Days of time series to look for the latest data in")
+	return cmd
+}
+
+func init() {
+	customerCmd.AddCommand(newServeMetricsCmd(apiCustomer))
+	thirdpartyCmd.AddCommand(newServeMetricsCmd(apiThirdParty))
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// hourlySeries returns a time series of a metering point with an hour of quantity 1 for
+// every hour from start, and the quantity of the last hour given by last.
+func hourlySeries(id string, start time.Time, hours int, last float64) eloverblik.TimeSeries {
+	points := make([]eloverblik.PointResponse, hours)
+	for i := range points {
+		points[i] = eloverblik.PointResponse{Position: i + 1, OutQuantityQuantity: 1, OutQuantityQuality: "A04"}
+	}
+	points[hours-1].OutQuantityQuantity = last
+
+	var ts eloverblik.TimeSeries
+	ts.Success = true
+	ts.MyEnergyDataMarketDocument.TimeSeries = []eloverblik.TimeSeriesTimeSeriesResponse{{
+		MRID:                id,
+		MeasurementUnitName: "KWH",
+		Periods: []eloverblik.PeriodResponse{{
+			Resolution:   "PT1H",
+			TimeInterval: eloverblik.TimeInterval{Start: start.UTC(), End: start.Add(time.Duration(hours) * time.Hour).UTC()},
+			Points:       points,
+		}},
+	}}
+	return ts
+}
+
+func scrape(t *testing.T, exporter *metricsExporter) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+
+	body, err := io.ReadAll(recorder.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetricsExporter(t *testing.T) {
+	const id = "571313174002485069"
+	now := time.Date(2025, 3, 12, 9, 30, 0, 0, eloverblik.Copenhagen)
+	// Data through 06:00 on the 11th: the 10th is the latest whole day
+	start := time.Date(2025, 3, 9, 0, 0, 0, 0, eloverblik.Copenhagen)
+
+	failTimeSeries := false
+	calls := 0
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			calls++
+			return []eloverblik.MeteringPointDetailsResponse{{
+				Result:         eloverblik.MeteringPointDetail{MeteringPointID: id, TypeOfMP: "E17", SettlementMethod: "D01", MeteringGridAreaIdentification: "031", GridOperatorName: `Radius "Øst"`},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			calls++
+			assert.Equal(t, []string{id}, meteringPointIDs)
+			assert.Equal(t, eloverblik.Hour, aggregation)
+			assert.True(t, from.Equal(time.Date(2025, 3, 9, 0, 0, 0, 0, eloverblik.Copenhagen)), "three days back from %s", from)
+			assert.True(t, to.Equal(time.Date(2025, 3, 12, 0, 0, 0, 0, eloverblik.Copenhagen)), "through yesterday, not %s", to)
+			if failTimeSeries {
+				return nil, eloverblik.ErrorClientConnection(http.StatusServiceUnavailable)
+			}
+			return []eloverblik.TimeSeries{hourlySeries(id, start, 24+24+6, 2.5)}, nil
+		},
+	}
+
+	exporter := newMetricsExporter(newClientSource(mock, nil), meteringPointLister(apiCustomer, newClientSource(mock, nil), []string{id}), 3)
+	exporter.now = func() time.Time { return now }
+	exporter.tokenExpiry = time.Unix(1767225600, 0)
+
+	assert.NotContains(t, scrape(t, exporter), "eloverblik_last_hour", "nothing before the first refresh")
+	assert.Equal(t, 0, calls)
+
+	assert.NoError(t, exporter.refresh())
+	metrics := scrape(t, exporter)
+	for _, line := range []string{
+		"# TYPE eloverblik_last_hour_quantity gauge",
+		`eloverblik_last_hour_quantity{metering_point_id="571313174002485069",type="E17",unit="KWH"} 2.5`,
+		`eloverblik_last_day_quantity{metering_point_id="571313174002485069",type="E17",unit="KWH"} 24`,
+		`eloverblik_latest_data_timestamp_seconds{metering_point_id="571313174002485069"} 1741669200`,
+		`eloverblik_data_lag_seconds{metering_point_id="571313174002485069"} 99000`,
+		`eloverblik_metering_point_info{metering_point_id="571313174002485069",type="E17",settlement_method="D01",grid_area="031",grid_operator="Radius \"Øst\""} 1`,
+		"eloverblik_refresh_token_expiry_timestamp_seconds 1767225600",
+		"eloverblik_refreshes_total 1",
+		"eloverblik_last_refresh_success 1",
+	} {
+		assert.Contains(t, metrics, line+"\n")
+	}
+	assert.NotContains(t, metrics, "eloverblik_api_errors_total")
+
+	t.Run("scrapes do not call the API", func(t *testing.T) {
+		calls = 0
+		scrape(t, exporter)
+		scrape(t, exporter)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("a failed refresh keeps the values and counts the error", func(t *testing.T) {
+		failTimeSeries = true
+		now = now.Add(time.Hour)
+
+		err := exporter.refresh()
+		assert.ErrorContains(t, err, "timeseries: ")
+
+		metrics := scrape(t, exporter)
+		assert.Contains(t, metrics, `eloverblik_api_errors_total{operation="timeseries"} 1`+"\n")
+		assert.Contains(t, metrics, `eloverblik_last_hour_quantity{metering_point_id="571313174002485069",type="E17",unit="KWH"} 2.5`+"\n")
+		assert.Contains(t, metrics, `eloverblik_data_lag_seconds{metering_point_id="571313174002485069"} 102600`+"\n", "the lag grows")
+		assert.Contains(t, metrics, "eloverblik_last_refresh_success 0\n")
+		assert.Contains(t, metrics, "eloverblik_refreshes_total 2\n")
+	})
+}
+
+func TestMetricsQuantityLabels(t *testing.T) {
+	const id = "571313174002485070"
+	exporter := newMetricsExporter(newClientSource(&MockClient{}, nil), nil, 3)
+	exporter.now = func() time.Time { return time.Date(2025, 3, 12, 9, 30, 0, 0, eloverblik.Copenhagen) }
+
+	production := hourlySeries(id, time.Date(2025, 3, 10, 0, 0, 0, 0, eloverblik.Copenhagen), 24, 3)
+	production.MyEnergyDataMarketDocument.TimeSeries[0].MeasurementUnitName = "KVARH"
+	point := exporter.point(id)
+	point.details = &eloverblik.MeteringPointDetail{MeteringPointID: id, TypeOfMP: "E18"}
+	point.observe(production.Flatten())
+
+	metrics := scrape(t, exporter)
+	assert.Contains(t, metrics, `eloverblik_last_hour_quantity{metering_point_id="571313174002485070",type="E18",unit="KVARH"} 3`+"\n")
+	assert.NotContains(t, metrics, "consumption")
+}
+
+func TestMetricsLastDay(t *testing.T) {
+	hours := func(start time.Time, n int) []eloverblik.FlatTimeSeriesPoint {
+		ts := hourlySeries("571313174002485069", start, n, 1)
+		return ts.Flatten()
+	}
+
+	t.Run("a day missing an hour is skipped", func(t *testing.T) {
+		// The 9th and 10th, with 13:00 on the 10th missing
+		points := hours(time.Date(2025, 3, 9, 0, 0, 0, 0, eloverblik.Copenhagen), 48)
+		points = slices.Delete(points, 24+13, 24+14)
+
+		var m meteringPointMetrics
+		m.observe(points)
+		assert.True(t, m.lastDayFound)
+		assert.Equal(t, 24.0, m.lastDay, "the 9th")
+	})
+
+	t.Run("no day is whole", func(t *testing.T) {
+		points := hours(time.Date(2025, 3, 10, 6, 0, 0, 0, eloverblik.Copenhagen), 24)
+
+		var m meteringPointMetrics
+		m.observe(points)
+		assert.False(t, m.lastDayFound)
+	})
+
+	t.Run("the day daylight saving time starts has 23 hours", func(t *testing.T) {
+		points := hours(time.Date(2025, 3, 30, 0, 0, 0, 0, eloverblik.Copenhagen), 23)
+
+		var m meteringPointMetrics
+		m.observe(points)
+		assert.True(t, m.lastDayFound)
+		assert.Equal(t, 23.0, m.lastDay)
+	})
+}
+
+func TestMetricsRenewExpiredClient(t *testing.T) {
+	const id = "571313174002485069"
+	clients, now := expiringClients()
+	exporter := newMetricsExporter(clients, meteringPointLister(apiCustomer, clients, []string{id}), 3)
+
+	typeOfMP := func() string {
+		assert.NoError(t, exporter.refresh())
+		exporter.mu.Lock()
+		defer exporter.mu.Unlock()
+		return exporter.points[id].details.TypeOfMP
+	}
+
+	assert.Equal(t, "1", typeOfMP())
+	*now = now.Add(25 * time.Hour)
+	assert.Equal(t, "2", typeOfMP(), "the token expired")
+}
+
+func TestMetricsFailedItems(t *testing.T) {
+	const id = "571313174002485069"
+	failed := eloverblik.StatusResponse{ID: id, ErrorCode: 20008, ErrorText: "MeteringPointNotFound"}
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			return []eloverblik.MeteringPointDetailsResponse{{StatusResponse: failed}}, nil
+		},
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			return []eloverblik.TimeSeries{{StatusResponse: failed}}, nil
+		},
+	}
+	exporter := newMetricsExporter(newClientSource(mock, nil), meteringPointLister(apiCustomer, newClientSource(mock, nil), []string{id}), 3)
+
+	err := exporter.refresh()
+	assert.ErrorIs(t, err, eloverblik.ErrorMeteringPointNotFound)
+	assert.ErrorContains(t, err, "details: metering point 571313174002485069: ")
+	assert.ErrorContains(t, err, "timeseries: metering point 571313174002485069: ")
+
+	metrics := scrape(t, exporter)
+	assert.Contains(t, metrics, `eloverblik_api_errors_total{operation="details"} 1`+"\n")
+	assert.Contains(t, metrics, `eloverblik_api_errors_total{operation="timeseries"} 1`+"\n")
+	assert.Contains(t, metrics, "eloverblik_last_refresh_success 0\n")
+	assert.NotContains(t, metrics, "eloverblik_metering_point_info")
+}
+
+func TestMetricsMeteringPoints(t *testing.T) {
+	t.Run("the installations of a customer", func(t *testing.T) {
+		mock := &metricsCustomerClient{points: []eloverblik.MeteringPoints{{MeteringPointID: "1"}, {MeteringPointID: "2"}}}
+		ids, err := meteringPointLister(apiCustomer, newClientSource(mock, nil), nil)()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("every metering point of a third party", func(t *testing.T) {
+		mock := &mockThirdPartyClient{
+			authorizations: []eloverblik.Authorization{{ID: "a"}, {ID: "b"}},
+			meteringPoints: map[string][]string{"a": {"2", "1"}, "b": {"2", "3"}},
+		}
+		ids, err := meteringPointLister(apiThirdParty, newClientSource(mock, nil), nil)()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, ids)
+	})
+
+	t.Run("those given", func(t *testing.T) {
+		ids, err := meteringPointLister(apiThirdParty, newClientSource(&MockClient{}, nil), []string{"9"})()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"9"}, ids)
+	})
+}
+
+type metricsCustomerClient struct {
+	MockCustomerClient
+	points []eloverblik.MeteringPoints
+}
+
+func (m *metricsCustomerClient) GetMeteringPoints(includeAll bool) ([]eloverblik.MeteringPoints, error) {
+	return m.points, nil
+}
+
+func TestServeMetricsFlags(t *testing.T) {
+	clientInstance = &MockClient{}
+	defer func() { clientInstance = nil }()
+
+	_, err := execute(t, "customer", "serve-metrics", "--interval", "1m", "--token", "dummy")
+	assert.ErrorContains(t, err, "--interval must be at least 5m0s")
+
+	_, err = execute(t, "customer", "serve-metrics", "--days", "0", "--token", "dummy")
+	assert.ErrorContains(t, err, "--days must be at least 1")
+
+	_, err = execute(t, "thirdparty", "serve-metrics", "12345", "--token", "dummy")
+	assert.Error(t, err)
+}
+
+func TestLabelPairs(t *testing.T) {
+	assert.Equal(t, `{a="x",b="back\\slash \"quoted\" new\nline"}`, labelPairs("a", "x", "b", "back\\slash \"quoted\" new\nline"))
+	assert.Equal(t, "", labelPairs())
+}
@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultMQTTBroker            = "tcp://localhost:1883"
+	defaultMQTTClientID          = "go-eloverblik"
+	defaultMQTTPointsTopic       = "eloverblik/{id}/points"
+	defaultMQTTLastTopic         = "eloverblik/{id}/last"
+	defaultMQTTAvailabilityTopic = "eloverblik/status"
+	defaultMQTTDiscoveryPrefix   = "homeassistant"
+
+	// mqttTimeout bounds connecting to the broker and each publish.
+	mqttTimeout = 10 * time.Second
+)
+
+// mqttTopics are the topics a publisher publishes on. {id} in a topic is replaced by the
+// metering point ID.
+type mqttTopics struct {
+	// points receives every new point, not retained.
+	points string
+	// last holds the latest point, retained.
+	last string
+	// availability holds "online" while the publisher runs, and "offline" after.
+	availability string
+	// discoveryPrefix is the Home Assistant discovery prefix. Empty publishes no discovery.
+	discoveryPrefix string
+}
+
+func (t mqttTopics) forMeteringPoint(topic, id string) string {
+	return strings.ReplaceAll(topic, "{id}", id)
+}
+
+// mqttPoint is the payload of a point: a flattened point with its metering point.
+type mqttPoint struct {
+	MeteringPointID string `json:"meteringPointId"`
+	eloverblik.FlatTimeSeriesPoint
+}
+
+// mqttPublisher polls the time series of metering points and publishes the points it has
+// not published before. It remembers the latest point of each metering point for as long
+// as it runs: after a restart, the points of the polled days are published again, so
+// consumers should key them by their from. The client is replaced when its data access
+// token expires.
+type mqttPublisher struct {
+	clients          *clientSource
+	meteringPointIDs func() ([]string, error)
+	broker           mqtt.Client
+	topics           mqttTopics
+	qos              byte
+	days             int
+	aggregation      eloverblik.Aggregation
+	now              func() time.Time
+
+	// published is the end of the latest point published of each metering point.
+	published map[string]time.Time
+	// discovered are the metering points whose discovery payload was published.
+	discovered map[string]bool
+}
+
+func newMQTTPublisher(clients *clientSource, meteringPointIDs func() ([]string, error), broker mqtt.Client, topics mqttTopics) *mqttPublisher {
+	return &mqttPublisher{
+		clients:          clients,
+		meteringPointIDs: meteringPointIDs,
+		broker:           broker,
+		topics:           topics,
+		qos:              1,
+		days:             defaultPollDays,
+		aggregation:      eloverblik.Hour,
+		now:              time.Now,
+		published:        make(map[string]time.Time),
+		discovered:       make(map[string]bool),
+	}
+}
+
+// poll fetches the time series of the metering points and publishes what is new, after
+// the Home Assistant discovery payloads of metering points it has not announced yet. A
+// failed batch does not stop the others: its error is returned joined with any others.
+func (p *mqttPublisher) poll() error {
+	var errs []error
+
+	ids, err := p.meteringPointIDs()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("listing metering points: %w", err))
+	}
+
+	from, to := pollWindow(p.now(), p.days)
+	for batch := range slices.Chunk(ids, eloverblik.MaxMeteringPointsPerRequest) {
+		if p.topics.discoveryPrefix != "" {
+			if err := p.discover(batch); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		series, err := p.clients.get().GetTimeSeries(batch, from, to, p.aggregation)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("metering points %s: %w", strings.Join(batch, ","), err))
+		}
+		for _, ts := range series {
+			if err := ts.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("metering point %s: %w", cmp.Or(ts.ID, ts.MeteringPointID()), err))
+				continue
+			}
+			if err := p.publishPoints(ts.MeteringPointID(), ts.Flatten()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// publishPoints publishes the points after the latest one published, each on the points
+// topic, and the last of them retained on the last topic.
+func (p *mqttPublisher) publishPoints(id string, points []eloverblik.FlatTimeSeriesPoint) error {
+	slices.SortFunc(points, func(a, b eloverblik.FlatTimeSeriesPoint) int {
+		return a.From.Compare(b.From)
+	})
+
+	published := p.published[id]
+	var latest *eloverblik.FlatTimeSeriesPoint
+	for i, point := range points {
+		if !point.To.After(published) {
+			continue
+		}
+		if err := p.publish(p.topics.forMeteringPoint(p.topics.points, id), false, mqttPoint{id, point}); err != nil {
+			return err
+		}
+		latest = &points[i]
+		p.published[id] = point.To
+	}
+
+	if latest == nil {
+		return nil
+	}
+	return p.publish(p.topics.forMeteringPoint(p.topics.last, id), true, mqttPoint{id, *latest})
+}
+
+// discover publishes the Home Assistant discovery payloads of the metering points it has
+// not announced yet, built from their details.
+func (p *mqttPublisher) discover(ids []string) error {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return p.discovered[id] })
+	if len(ids) == 0 {
+		return nil
+	}
+
+	details, err := p.clients.get().GetMeteringPointDetails(ids)
+	if err != nil {
+		return fmt.Errorf("details of metering points %s: %w", strings.Join(ids, ","), err)
+	}
+
+	var errs []error
+	for _, d := range details {
+		if err := d.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("details of metering point %s: %w", d.ID, err))
+			continue
+		}
+		id := d.Result.MeteringPointID
+		topic := fmt.Sprintf("%s/sensor/eloverblik_%s/energy/config", p.topics.discoveryPrefix, id)
+		if err := p.publish(topic, true, homeAssistantDiscovery(d.Result, p.topics)); err != nil {
+			return err
+		}
+		p.discovered[id] = true
+	}
+	return errors.Join(errs...)
+}
+
+// publish publishes a JSON payload and waits for the broker to take it.
+func (p *mqttPublisher) publish(topic string, retained bool, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return waitMQTT(p.broker.Publish(topic, p.qos, retained, body), "publishing to "+topic)
+}
+
+// waitMQTT waits for an MQTT operation to complete, for at most mqttTimeout.
+func waitMQTT(token mqtt.Token, operation string) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("%s: timed out after %s", operation, mqttTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("%s: %w", operation, err)
+	}
+	return nil
+}
+
+// homeAssistantSensor is the discovery payload of an MQTT sensor in Home Assistant.
+type homeAssistantSensor struct {
+	Name                   string              `json:"name"`
+	UniqueID               string              `json:"unique_id"`
+	ObjectID               string              `json:"object_id"`
+	StateTopic             string              `json:"state_topic"`
+	ValueTemplate          string              `json:"value_template"`
+	LastResetValueTemplate string              `json:"last_reset_value_template"`
+	JSONAttributesTopic    string              `json:"json_attributes_topic"`
+	UnitOfMeasurement      string              `json:"unit_of_measurement,omitempty"`
+	DeviceClass            string              `json:"device_class,omitempty"`
+	StateClass             string              `json:"state_class"`
+	AvailabilityTopic      string              `json:"availability_topic,omitempty"`
+	Device                 homeAssistantDevice `json:"device"`
+}
+
+type homeAssistantDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	SerialNumber string   `json:"serial_number,omitempty"`
+}
+
+// homeAssistantUnits are the Home Assistant spellings and device classes of the units the
+// API measures in.
+var homeAssistantUnits = map[string]struct{ unit, deviceClass string }{
+	"KWH": {"kWh", "energy"},
+	"MWH": {"MWh", "energy"},
+	"WH":  {"Wh", "energy"},
+}
+
+// meteringPointTypes names the types of metering point (typeOfMP) that are common in a
+// home.
+var meteringPointTypes = map[string]string{
+	"E17": "Consumption",
+	"E18": "Production",
+	"E20": "Exchange",
+}
+
+// homeAssistantDiscovery builds the discovery payload of a sensor holding the quantity of
+// the latest point of a metering point. The quantity is that of a single interval rather
+// than a meter reading, so the sensor is a total that resets at the start of each point.
+func homeAssistantDiscovery(d eloverblik.MeteringPointDetail, topics mqttTopics) homeAssistantSensor {
+	id := d.MeteringPointID
+	typeName := cmp.Or(meteringPointTypes[d.TypeOfMP], d.TypeOfMP, "Metering point")
+
+	unit := homeAssistantUnits[strings.ToUpper(d.EnergyTimeSeriesMeasureUnit)]
+	if unit.unit == "" {
+		unit.unit = d.EnergyTimeSeriesMeasureUnit
+	}
+
+	name := cmp.Or(d.MeteringPointAlias, d.Address(), id)
+	lastTopic := topics.forMeteringPoint(topics.last, id)
+
+	return homeAssistantSensor{
+		Name:                   typeName,
+		UniqueID:               "eloverblik_" + id + "_energy",
+		ObjectID:               "eloverblik_" + id + "_energy",
+		StateTopic:             lastTopic,
+		ValueTemplate:          "{{ value_json.measurement }}",
+		LastResetValueTemplate: "{{ value_json.from }}",
+		JSONAttributesTopic:    lastTopic,
+		UnitOfMeasurement:      unit.unit,
+		DeviceClass:            unit.deviceClass,
+		StateClass:             "total",
+		AvailabilityTopic:      topics.availability,
+		Device: homeAssistantDevice{
+			Identifiers:  []string{"eloverblik_" + id},
+			Name:         name,
+			Manufacturer: d.GridOperatorName,
+			Model:        typeName,
+			SerialNumber: d.MeterNumber,
+		},
+	}
+}
+
+// connectMQTT connects to the broker, announcing availability: "online" once connected,
+// and "offline" as the will the broker publishes when the connection is lost.
+func connectMQTT(broker, clientID, username, password string, topics mqttTopics) (mqtt.Client, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(mqttTimeout)
+	if topics.availability != "" {
+		opts.SetWill(topics.availability, "offline", 1, true)
+		opts.SetOnConnectHandler(func(c mqtt.Client) {
+			c.Publish(topics.availability, 1, true, "online")
+		})
+	}
+
+	client := mqtt.NewClient(opts)
+	if err := waitMQTT(client.Connect(), "connecting to "+broker); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// disconnectMQTT marks the publisher offline and disconnects.
+func disconnectMQTT(client mqtt.Client, topics mqttTopics) {
+	if topics.availability != "" {
+		_ = waitMQTT(client.Publish(topics.availability, 1, true, "offline"), "publishing to "+topics.availability)
+	}
+	client.Disconnect(uint(time.Second / time.Millisecond))
+}
+
+// newPublishMQTTCmd builds a fresh "publish-mqtt" command for the parent of an API, as
+// newAliveCmd does.
+func newPublishMQTTCmd(api string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "publish-mqtt [metering point ids...]",
+		Short: "Publish new time series points to an MQTT broker",
+		Long: `Poll the time series of metering points on an interval, and publish the points that are new
+to an MQTT broker, e.g. for Home Assistant or openHAB. Without metering point IDs, every
+metering point the token has access to is polled.
+
+Every new point is published as JSON on --points-topic, and the latest one retained on
+--last-topic. {id} in a topic is the metering point ID. The payload is a flattened point
+with its metering point:
+
+  {"meteringPointId":"5713...","from":"...","to":"...","measurement":0.42,"unit":"KWH",...}
+
+With --discovery, a Home Assistant discovery payload is published, retained, for every
+metering point, built from its details: the address names the device, and the unit and
+type of metering point describe the sensor. --availability-topic holds "online" while the
+publisher runs and "offline" after, also when the connection is lost.
+
+The points published are remembered while the publisher runs. After a restart, the points
+of the last --days are published again.
+
+The broker password is read from $` + envMQTTPassword + ` unless --password is given.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			return meteringPointIDArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			broker, _ := cmd.Flags().GetString("broker")
+			clientID, _ := cmd.Flags().GetString("client-id")
+			username, _ := cmd.Flags().GetString("username")
+			password, _ := cmd.Flags().GetString("password")
+			interval, _ := cmd.Flags().GetDuration("interval")
+			days, _ := cmd.Flags().GetInt("days")
+			aggregation, _ := cmd.Flags().GetString("aggregation")
+			qos, _ := cmd.Flags().GetInt("qos")
+			once, _ := cmd.Flags().GetBool("once")
+			discovery, _ := cmd.Flags().GetBool("discovery")
+
+			topics := mqttTopics{}
+			topics.points, _ = cmd.Flags().GetString("points-topic")
+			topics.last, _ = cmd.Flags().GetString("last-topic")
+			topics.availability, _ = cmd.Flags().GetString("availability-topic")
+			if discovery {
+				topics.discoveryPrefix, _ = cmd.Flags().GetString("discovery-prefix")
+			}
+
+			if !once && interval < minPollInterval {
+				return fmt.Errorf("--interval must be at least %s, to stay within the rate limits of the API", minPollInterval)
+			}
+			if days < 1 {
+				return errors.New("--days must be at least 1")
+			}
+			if qos < 0 || qos > 2 {
+				return errors.New("--qos must be 0, 1 or 2")
+			}
+			if !validAggregation(eloverblik.Aggregation(aggregation)) {
+				return fmt.Errorf("invalid aggregation '%s'", aggregation)
+			}
+			for _, topic := range []string{topics.points, topics.last} {
+				if topic == "" || strings.ContainsAny(topic, "+#") {
+					return fmt.Errorf("invalid topic '%s': it cannot be empty or hold wildcards", topic)
+				}
+			}
+			if password == "" {
+				password = os.Getenv(envMQTTPassword)
+			}
+
+			client, err := connectMQTT(broker, clientID, username, password, topics)
+			if err != nil {
+				return err
+			}
+			defer disconnectMQTT(client, topics)
+
+			clients := newClientSource(clientInstance, buildClient)
+			publisher := newMQTTPublisher(clients, meteringPointLister(api, clients, args), client, topics)
+			publisher.qos = byte(qos)
+			publisher.days = days
+			publisher.aggregation = eloverblik.Aggregation(aggregation)
+
+			if once {
+				return publisher.poll()
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			_, _ = fmt.Fprintf(messageOutput, "Publishing to %s every %s\n", broker, interval)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := publisher.poll(); err != nil {
+					_, _ = fmt.Fprintf(messageOutput, "publishing: %v\n", err)
+				}
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().String("broker", defaultMQTTBroker, "MQTT broker URL: tcp://, ssl:// or ws://")
+	cmd.Flags().String("client-id", defaultMQTTClientID, "MQTT client ID")
+	cmd.Flags().String("username", "", "MQTT username")
+	cmd.Flags().String("password", "", "MQTT password (or $"+envMQTTPassword+")")
+	cmd.Flags().Int("qos", 1, "MQTT quality of service of the messages (0, 1, 2)")
+	cmd.Flags().String("points-topic", defaultMQTTPointsTopic, "Topic of every new point; {id} is the metering point ID")
+	cmd.Flags().String("last-topic", defaultMQTTLastTopic, "Topic of the latest point, retained; {id} is the metering point ID")
+	cmd.Flags().String("availability-topic", defaultMQTTAvailabilityTopic, "Topic holding online or offline, retained; empty for none")
+	cmd.Flags().Bool("discovery", false, "Publish Home Assistant discovery payloads")
+	cmd.Flags().String("discovery-prefix", defaultMQTTDiscoveryPrefix, "Home Assistant discovery prefix")
+	cmd.Flags().Duration("interval", time.Hour, "Time between polls of the API")
+	cmd.Flags().Int("days", defaultPollDays, "Days of time series to poll")
+	cmd.Flags().String("aggregation", string(eloverblik.Hour), "Aggregation of the points (Actual, Quarter, Hour, Day, Month, Year)")
+	cmd.Flags().Bool("once", false, "Poll and publish once, then exit, e.g. from cron")
+	return cmd
+}
+
+func init() {
+	customerCmd.AddCommand(newPublishMQTTCmd(apiCustomer))
+	thirdpartyCmd.AddCommand(newPublishMQTTCmd(apiThirdParty))
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mqttMessages collects the messages an embedded broker receives.
+type mqttMessages struct {
+	mu       sync.Mutex
+	messages []packets.Packet
+}
+
+func (m *mqttMessages) on(topic string) []packets.Packet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var on []packets.Packet
+	for _, pk := range m.messages {
+		if pk.TopicName == topic {
+			on = append(on, pk)
+		}
+	}
+	return on
+}
+
+// startBroker starts an MQTT broker in the test, and returns its URL and the messages
+// published to it.
+func startBroker(t *testing.T) (string, *mqttMessages) {
+	t.Helper()
+
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, server.AddListener(listener))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { _ = server.Close() })
+
+	messages := &mqttMessages{}
+	require.NoError(t, server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		messages.mu.Lock()
+		defer messages.mu.Unlock()
+		messages.messages = append(messages.messages, pk)
+	}))
+	return "tcp://" + listener.Address(), messages
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	const id = "571313174002485069"
+	start := time.Date(2025, 3, 9, 0, 0, 0, 0, eloverblik.Copenhagen)
+
+	broker, messages := startBroker(t)
+	topics := mqttTopics{
+		points:          defaultMQTTPointsTopic,
+		last:            defaultMQTTLastTopic,
+		availability:    defaultMQTTAvailabilityTopic,
+		discoveryPrefix: defaultMQTTDiscoveryPrefix,
+	}
+	client, err := connectMQTT(broker, t.Name(), "", "", topics)
+	require.NoError(t, err)
+
+	hours, detailCalls := 48, 0
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			detailCalls++
+			return []eloverblik.MeteringPointDetailsResponse{{
+				Result: eloverblik.MeteringPointDetail{
+					MeteringPointID:             id,
+					TypeOfMP:                    "E17",
+					EnergyTimeSeriesMeasureUnit: "KWH",
+					StreetName:                  "Vestergade",
+					BuildingNumber:              "12",
+					Postcode:                    "8000",
+					CityName:                    "Aarhus C",
+					GridOperatorName:            "Konstant Net A/S",
+					MeterNumber:                 "12345678",
+				},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			assert.Equal(t, []string{id}, meteringPointIDs)
+			assert.Equal(t, start.AddDate(0, 0, -1), from)
+			return []eloverblik.TimeSeries{hourlySeries(id, start, hours, 0.42)}, nil
+		},
+	}
+
+	publisher := newMQTTPublisher(newClientSource(mock, nil), func() ([]string, error) { return []string{id}, nil }, client, topics)
+	publisher.now = func() time.Time { return start.AddDate(0, 0, 2).Add(10 * time.Hour) }
+	require.NoError(t, publisher.poll())
+
+	assert.Eventually(t, func() bool { return len(messages.on("eloverblik/"+id+"/last")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, messages.on("eloverblik/"+id+"/points"), 48)
+
+	last := messages.on("eloverblik/" + id + "/last")[0]
+	assert.True(t, last.FixedHeader.Retain)
+	var point mqttPoint
+	assert.NoError(t, json.Unmarshal(last.Payload, &point))
+	assert.Equal(t, id, point.MeteringPointID)
+	assert.Equal(t, 0.42, point.Measurement)
+	assert.True(t, point.To.Equal(start.Add(48*time.Hour)))
+
+	t.Run("the availability is online", func(t *testing.T) {
+		online := messages.on(defaultMQTTAvailabilityTopic)
+		if assert.NotEmpty(t, online) {
+			assert.Equal(t, "online", string(online[0].Payload))
+			assert.True(t, online[0].FixedHeader.Retain)
+		}
+	})
+
+	t.Run("the discovery payload describes the metering point", func(t *testing.T) {
+		configs := messages.on("homeassistant/sensor/eloverblik_" + id + "/energy/config")
+		if !assert.Len(t, configs, 1) {
+			return
+		}
+		assert.True(t, configs[0].FixedHeader.Retain)
+
+		var sensor homeAssistantSensor
+		assert.NoError(t, json.Unmarshal(configs[0].Payload, &sensor))
+		assert.Equal(t, "Consumption", sensor.Name)
+		assert.Equal(t, "kWh", sensor.UnitOfMeasurement)
+		assert.Equal(t, "energy", sensor.DeviceClass)
+		assert.Equal(t, "eloverblik/"+id+"/last", sensor.StateTopic)
+		assert.Equal(t, defaultMQTTAvailabilityTopic, sensor.AvailabilityTopic)
+		assert.Equal(t, "Vestergade 12, 8000 Aarhus C", sensor.Device.Name)
+		assert.Equal(t, "Konstant Net A/S", sensor.Device.Manufacturer)
+		assert.Equal(t, "12345678", sensor.Device.SerialNumber)
+	})
+
+	t.Run("only new points are published again", func(t *testing.T) {
+		hours = 50
+		require.NoError(t, publisher.poll())
+
+		assert.Eventually(t, func() bool { return len(messages.on("eloverblik/"+id+"/last")) == 2 }, time.Second, 10*time.Millisecond)
+		assert.Len(t, messages.on("eloverblik/"+id+"/points"), 50)
+		assert.Equal(t, 1, detailCalls, "a metering point is announced once")
+	})
+
+	t.Run("nothing new publishes nothing", func(t *testing.T) {
+		require.NoError(t, publisher.poll())
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, messages.on("eloverblik/"+id+"/last"), 2)
+	})
+
+	t.Run("offline on disconnect", func(t *testing.T) {
+		disconnectMQTT(client, topics)
+
+		assert.Eventually(t, func() bool {
+			availability := messages.on(defaultMQTTAvailabilityTopic)
+			return string(availability[len(availability)-1].Payload) == "offline"
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestMQTTPublisherItemError(t *testing.T) {
+	broker, _ := startBroker(t)
+	client, err := connectMQTT(broker, t.Name(), "", "", mqttTopics{})
+	require.NoError(t, err)
+	defer client.Disconnect(0)
+
+	mock := &MockClient{
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			var ts eloverblik.TimeSeries
+			ts.ID = meteringPointIDs[0]
+			ts.ErrorCode = 20008
+			ts.ErrorText = "MeteringPointNotFound"
+			return []eloverblik.TimeSeries{ts}, nil
+		},
+	}
+	publisher := newMQTTPublisher(newClientSource(mock, nil), func() ([]string, error) { return []string{"571313174002485070"}, nil }, client, mqttTopics{points: "p/{id}", last: "l/{id}"})
+
+	assert.ErrorContains(t, publisher.poll(), "metering point 571313174002485070")
+}
+
+func TestMQTTRenewsExpiredClient(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var served []int
+	built := 0
+	build := func() eloverblik.Client {
+		built++
+		generation := built
+		return &expiringClient{
+			expiresAt: now.Add(24 * time.Hour),
+			MockClient: MockClient{
+				GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+					served = append(served, generation)
+					return nil, nil
+				},
+			},
+		}
+	}
+	clients := newClientSource(build(), build)
+	clients.now = func() time.Time { return now }
+	publisher := newMQTTPublisher(clients, func() ([]string, error) { return []string{"571313174002485069"}, nil }, nil, mqttTopics{points: "p/{id}", last: "l/{id}"})
+
+	require.NoError(t, publisher.poll())
+	now = now.Add(25 * time.Hour)
+	require.NoError(t, publisher.poll())
+	assert.Equal(t, []int{1, 2}, served, "the second poll uses a new client")
+}
+
+func TestPublishMQTTFlags(t *testing.T) {
+	clientInstance = &MockClient{}
+	defer func() { clientInstance = nil }()
+
+	_, err := execute(t, "customer", "publish-mqtt", "--interval", "1m", "--token", "dummy")
+	assert.ErrorContains(t, err, "--interval must be at least 5m0s")
+
+	_, err = execute(t, "thirdparty", "publish-mqtt", "--once", "--qos", "3", "--token", "dummy")
+	assert.ErrorContains(t, err, "--qos must be 0, 1 or 2")
+
+	_, err = execute(t, "customer", "publish-mqtt", "--once", "--points-topic", "eloverblik/#", "--token", "dummy")
+	assert.ErrorContains(t, err, "invalid topic 'eloverblik/#'")
+
+	_, err = execute(t, "customer", "publish-mqtt", "12345", "--token", "dummy")
+	assert.ErrorContains(t, err, "looks like an invalid id: 12345")
+}
@@ -0,0 +1,647 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Output formats selectable with --output.
+const (
+	formatJSON      = "json"
+	formatNDJSON    = "ndjson"
+	formatCSV       = "csv"
+	formatParquet   = "parquet"
+	formatInflux    = "influx"
+	formatTimescale = "timescale"
+	formatTable     = "table"
+)
+
+// encoder writes a command result to w in one output format.
+type encoder func(w io.Writer, r result) error
+
+// encoders maps every output format to its encoder. A new format is added here and
+// becomes available on every command that writes its result with writeResult.
+var encoders = map[string]encoder{
+	formatJSON:      encodeJSON,
+	formatNDJSON:    encodeNDJSON,
+	formatCSV:       encodeCSV,
+	formatParquet:   encodeParquet,
+	formatInflux:    encodeInflux,
+	formatTimescale: encodeTimescale,
+	formatTable:     encodeTable,
+}
+
+// isTerminal reports whether w is an interactive terminal (configurable for testing).
+// A table is meant for a person; piped into another program it would be the one format
+// that program cannot parse, so the table encoder writes JSON there instead.
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// result is what a command hands to its encoder. value is always set; the other fields
+// are set by the commands whose result has that shape, and an encoder that needs a shape
+// the result does not have reports so instead of guessing.
+type result struct {
+	// value is the result as the library returned it. json writes it verbatim.
+	value any
+	// series flattens a time series result per metering point, in the order the API
+	// returned them. It is set by the time series command.
+	series func() []flatSeries
+	// table renders the result as rows and columns, nil when it has no tabular form.
+	table func() table
+	// fields are the columns selected with --fields, nil when none were.
+	fields []string
+}
+
+// flatSeries is the flattened time series of a single metering point.
+type flatSeries struct {
+	meteringPointID string
+	points          []eloverblik.FlatTimeSeriesPoint
+}
+
+// table is a result laid out as rows of strings under named columns. defaults are the
+// columns the table output shows when no --fields are given; csv writes every column.
+type table struct {
+	columns  []string
+	rows     [][]string
+	defaults []string
+}
+
+// selectColumns returns the table reduced to the given columns, in the given order.
+func (t table) selectColumns(fields []string) (table, error) {
+	index := make(map[string]int, len(t.columns))
+	for i, column := range t.columns {
+		index[column] = i
+	}
+
+	picks := make([]int, 0, len(fields))
+	for _, field := range fields {
+		i, ok := index[field]
+		if !ok {
+			return table{}, fmt.Errorf("unknown field '%s', use any of: %s", field, strings.Join(t.columns, ", "))
+		}
+		picks = append(picks, i)
+	}
+
+	selected := table{columns: fields, rows: make([][]string, 0, len(t.rows))}
+	for _, row := range t.rows {
+		cells := make([]string, 0, len(picks))
+		for _, i := range picks {
+			cells = append(cells, row[i])
+		}
+		selected.rows = append(selected.rows, cells)
+	}
+	return selected, nil
+}
+
+// addOutputFormatFlag adds --output and --fields to a command that writes its result
+// with writeResult. --output-format is taken as another name of --output, on the command
+// line and in profile defaults.
+func addOutputFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "output-format" {
+			name = "output"
+		}
+		return pflag.NormalizedName(name)
+	})
+	cmd.Flags().StringP("output", "o", formatJSON, "output format ("+strings.Join(outputFormats(), ", ")+")")
+	cmd.Flags().StringSlice("fields", nil, "comma separated columns to show in table and csv output")
+}
+
+// outputFormats lists the registered output formats, sorted.
+func outputFormats() []string {
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// writeResult writes r to output in the format selected with --output.
+func writeResult(cmd *cobra.Command, r result) error {
+	format := formatJSON
+	if flag := cmd.Flags().Lookup("output"); flag != nil {
+		format = strings.ToLower(flag.Value.String())
+	}
+
+	encode, ok := encoders[format]
+	if !ok {
+		return fmt.Errorf("unknown output format '%s', use one of: %s", format, strings.Join(outputFormats(), ", "))
+	}
+
+	if fields, err := cmd.Flags().GetStringSlice("fields"); err == nil && len(fields) > 0 {
+		r.fields = fields
+	}
+	return encode(output, r)
+}
+
+// encodeJSON writes the result as a single JSON document, exactly as the API client
+// returned it.
+func encodeJSON(w io.Writer, r result) error {
+	return writeJSON(w, r.value)
+}
+
+// writeJSON writes v as a single line of JSON, ending in a newline so that the prompt
+// of a terminal starts on a line of its own.
+func writeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encodeNDJSON writes one JSON object per line: one per flattened point for a time
+// series, otherwise one per element of the result.
+func encodeNDJSON(w io.Writer, r result) error {
+	if r.series != nil {
+		return writeSeries(eloverblik.NewNDJSONWriter(w), r.series())
+	}
+
+	enc := json.NewEncoder(w)
+	value := reflect.ValueOf(r.value)
+	if value.Kind() != reflect.Slice {
+		return enc.Encode(r.value)
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := enc.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeCSV writes comma separated values with a header row.
+func encodeCSV(w io.Writer, r result) error {
+	if r.series != nil && r.fields == nil {
+		return writeSeries(eloverblik.NewCSVWriter(w), r.series())
+	}
+
+	t, err := r.tabulate(r.fields, false)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// encodeParquet writes an Apache Parquet file. Only time series have a schema of their
+// own; the other results are deeply nested and have no natural columnar form.
+func encodeParquet(w io.Writer, r result) error {
+	if r.series == nil {
+		return fmt.Errorf("parquet output is only supported for time series")
+	}
+	return writeSeries(eloverblik.NewParquetWriter(w), r.series())
+}
+
+// encodeInflux writes InfluxDB line protocol, for time series only.
+func encodeInflux(w io.Writer, r result) error {
+	if r.series == nil {
+		return fmt.Errorf("influx output is only supported for time series")
+	}
+	return writeSeries(eloverblik.NewInfluxWriter(w), r.series())
+}
+
+// encodeTimescale writes a psql script upserting the points into PostgreSQL or
+// TimescaleDB, for time series only.
+func encodeTimescale(w io.Writer, r result) error {
+	if r.series == nil {
+		return fmt.Errorf("timescale output is only supported for time series")
+	}
+	return writeSeries(eloverblik.NewTimescaleWriter(w), r.series())
+}
+
+// encodeTable writes aligned columns for a person to read. Off a terminal it writes JSON,
+// so a script piping the command keeps getting output it can parse.
+func encodeTable(w io.Writer, r result) error {
+	if !isTerminal(w) {
+		return encodeJSON(w, r)
+	}
+
+	t, err := r.tabulate(r.fields, true)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.ToUpper(strings.Join(t.columns, "\t"))); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// tabulate lays the result out as a table holding the given fields. Without fields it
+// holds the default columns when defaults is set, and every column otherwise.
+func (r result) tabulate(fields []string, defaults bool) (table, error) {
+	var t table
+	switch {
+	case r.table != nil:
+		t = r.table()
+	case r.series != nil:
+		t = seriesTable(r.series())
+	default:
+		return table{}, fmt.Errorf("this command has no tabular output")
+	}
+
+	if fields == nil && defaults {
+		fields = t.defaults
+	}
+	if fields == nil {
+		return t, nil
+	}
+	return t.selectColumns(fields)
+}
+
+// seriesRow is a flattened point as a table row, carrying its metering point.
+type seriesRow struct {
+	MeteringPointID string `json:"meteringPointId"`
+	eloverblik.FlatTimeSeriesPoint
+}
+
+// seriesTable lays out flattened time series with one row per point.
+func seriesTable(series []flatSeries) table {
+	rows := make([]seriesRow, 0)
+	for _, s := range series {
+		for _, point := range s.points {
+			rows = append(rows, seriesRow{MeteringPointID: s.meteringPointID, FlatTimeSeriesPoint: point})
+		}
+	}
+	t := structTable(rows)
+	t.defaults = []string{"meteringPointId", "from", "to", "measurement", "unit", "quality"}
+	return t
+}
+
+// writeSeries writes every flattened series with a library writer and closes it.
+func writeSeries(w eloverblik.FlatTimeSeriesWriter, series []flatSeries) error {
+	for _, s := range series {
+		if err := w.Write(s.meteringPointID, s.points); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// flattenTimeSeries flattens every time series of a result, keeping the API's order.
+func flattenTimeSeries(tss []eloverblik.TimeSeries) []flatSeries {
+	series := make([]flatSeries, 0, len(tss))
+	for i := range tss {
+		series = append(series, flatSeries{
+			meteringPointID: tss[i].MeteringPointID(),
+			points:          tss[i].Flatten(),
+		})
+	}
+	return series
+}
+
+// structTable lays out a slice of structs as a table: one row per element and one column
+// per field that holds a single value, named after its JSON key. Embedded structs are
+// inlined; nested lists and objects are left out, as a cell cannot hold them.
+func structTable(items any) table {
+	var t table
+	value := reflect.ValueOf(items)
+	if value.Kind() != reflect.Slice {
+		return t
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		columns, cells := structCells(value.Index(i))
+		if i == 0 {
+			t.columns = columns
+		}
+		t.rows = append(t.rows, cells)
+	}
+	if t.columns == nil {
+		t.columns, _ = structCells(reflect.New(value.Type().Elem()).Elem())
+	}
+	return t
+}
+
+// structCells returns the column names and the cell values of a single struct.
+func structCells(v reflect.Value) (columns []string, cells []string) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.New(v.Type().Elem()).Elem()
+			break
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embeddedColumns, embeddedCells := structCells(v.Field(i))
+			columns = append(columns, embeddedColumns...)
+			cells = append(cells, embeddedCells...)
+			continue
+		}
+
+		if cell, ok := cellValue(v.Field(i)); ok {
+			columns = append(columns, name)
+			cells = append(cells, cell)
+		}
+	}
+	return columns, cells
+}
+
+// cellValue renders a field as a cell. ok is false for a field a cell cannot hold.
+func cellValue(v reflect.Value) (string, bool) {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return formatCellTime(value), true
+	case eloverblik.FlexibleTime:
+		return formatCellTime(value.Time), true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "", cellKind(v.Type().Elem())
+		}
+		return cellValue(v.Elem())
+	}
+	return "", false
+}
+
+// cellKind reports whether a nil pointer to t would have been rendered as a cell, so a
+// column does not come and go between rows depending on whether the value was set.
+func cellKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// formatCellTime renders a time as RFC 3339, and a zero time as an empty cell.
+func formatCellTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// installationsTable lays out the metering points of a customer.
+func installationsTable(meters []eloverblik.MeteringPoints) func() table {
+	return func() table {
+		t := structTable(meters)
+		t.defaults = []string{"meteringPointId", "typeOfMP", "streetName", "buildingNumber", "postcode", "cityName", "balanceSupplierName"}
+		return t
+	}
+}
+
+// authorizationsTable lays out the authorizations of a third party.
+func authorizationsTable(authorizations []eloverblik.Authorization) func() table {
+	return func() table {
+		t := structTable(authorizations)
+		t.defaults = []string{"id", "customerName", "customerCVR", "validFrom", "validTo", "includeFutureMeteringPoints"}
+		return t
+	}
+}
+
+// authorizationChangesTable lays out the changes of a third party's authorizations.
+func authorizationChangesTable(changes []eloverblik.AuthorizationChange) func() table {
+	return func() table {
+		t := structTable(changes)
+		t.defaults = []string{"kind", "authorizationId", "customerName", "meteringPointId", "validTo", "previousValidTo"}
+		return t
+	}
+}
+
+// detailRow is a metering point detail as a table row: the detail itself, and the status
+// of the item, so a metering point the API failed to resolve still shows up.
+type detailRow struct {
+	eloverblik.MeteringPointDetail
+	Success   bool   `json:"success"`
+	ErrorCode int    `json:"errorCode"`
+	ErrorText string `json:"errorText"`
+}
+
+// detailsTable lays out metering point details with one row per metering point.
+func detailsTable(details []eloverblik.MeteringPointDetailsResponse) func() table {
+	return func() table {
+		rows := make([]detailRow, 0, len(details))
+		for _, detail := range details {
+			row := detailRow{
+				MeteringPointDetail: detail.Result,
+				Success:             detail.Success,
+				ErrorCode:           detail.ErrorCode,
+				ErrorText:           detail.ErrorText,
+			}
+			if row.MeteringPointID == "" {
+				row.MeteringPointID = detail.ID
+			}
+			rows = append(rows, row)
+		}
+		t := structTable(rows)
+		t.defaults = []string{"meteringPointId", "typeOfMP", "settlementMethod", "meterNumber", "gridOperatorName", "balanceSupplierName", "success"}
+		return t
+	}
+}
+
+// readingRow is a meter reading as a table row, with the status of its metering point, so
+// a metering point the API failed to resolve still shows up.
+type readingRow struct {
+	MeteringPointID string `json:"meteringPointId"`
+	eloverblik.MeterReading
+	Success   bool   `json:"success"`
+	ErrorCode int    `json:"errorCode"`
+	ErrorText string `json:"errorText"`
+}
+
+// readingsTable lays out meter readings with one row per reading, and a row without a
+// reading for a metering point that has none.
+func readingsTable(readings []eloverblik.MeterReadingsResponse) func() table {
+	return func() table {
+		rows := make([]readingRow, 0, len(readings))
+		for _, r := range readings {
+			row := readingRow{
+				MeteringPointID: r.Result.MeteringPointID,
+				Success:         r.Success,
+				ErrorCode:       r.ErrorCode,
+				ErrorText:       r.ErrorText,
+			}
+			if row.MeteringPointID == "" {
+				row.MeteringPointID = r.ID
+			}
+			if len(r.Result.Readings) == 0 {
+				rows = append(rows, row)
+			}
+			for _, reading := range r.Result.Readings {
+				row.MeterReading = reading
+				rows = append(rows, row)
+			}
+		}
+		t := structTable(rows)
+		t.defaults = []string{"meteringPointId", "readingDate", "meterNumber", "meterReading", "measurementUnit", "registrationDate"}
+		return t
+	}
+}
+
+// reconcileTable lays out a register reconciliation with one row per reading interval.
+func reconcileTable(rec []eloverblik.RegisterReconciliation) func() table {
+	return func() table {
+		t := structTable(rec)
+		t.defaults = []string{"from", "to", "meterNumber", "startReading", "endReading", "registerConsumption", "measuredConsumption", "difference", "complete"}
+		return t
+	}
+}
+
+// chargeRow is a single charge as a table row. A tariff holds a price per position, e.g.
+// per hour of the day, and gets a row per position.
+type chargeRow struct {
+	MeteringPointID string                  `json:"meteringPointId"`
+	ChargeType      string                  `json:"chargeType"`
+	PriceID         string                  `json:"priceId"`
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	Owner           string                  `json:"owner"`
+	ValidFromDate   eloverblik.FlexibleTime `json:"validFromDate"`
+	ValidToDate     eloverblik.FlexibleTime `json:"validToDate"`
+	PeriodType      string                  `json:"periodType"`
+	Position        string                  `json:"position"`
+	Price           float64                 `json:"price"`
+	Quantity        int                     `json:"quantity"`
+}
+
+// meteringPointCharges are the charges of one metering point, whichever API they came
+// from.
+type meteringPointCharges struct {
+	meteringPointID string
+	subscriptions   []eloverblik.Charge
+	fees            []eloverblik.Charge
+	tariffs         []eloverblik.TariffCharge
+}
+
+// chargesTable lays out the subscriptions, fees and tariffs of every metering point in a
+// single table, telling them apart by chargeType.
+func chargesTable(charges []meteringPointCharges) func() table {
+	return func() table {
+		rows := make([]chargeRow, 0)
+		row := func(id, chargeType string, c eloverblik.Charge) chargeRow {
+			return chargeRow{
+				MeteringPointID: id,
+				ChargeType:      chargeType,
+				PriceID:         c.PriceID,
+				Name:            c.Name,
+				Description:     c.Description,
+				Owner:           c.Owner,
+				ValidFromDate:   c.ValidFromDate,
+				ValidToDate:     c.ValidToDate,
+				PeriodType:      c.PeriodType,
+				Price:           c.Price,
+				Quantity:        c.Quantity,
+			}
+		}
+
+		for _, mp := range charges {
+			for _, c := range mp.subscriptions {
+				rows = append(rows, row(mp.meteringPointID, "subscription", c))
+			}
+			for _, c := range mp.fees {
+				rows = append(rows, row(mp.meteringPointID, "fee", c))
+			}
+			for _, tariff := range mp.tariffs {
+				base := row(mp.meteringPointID, "tariff", eloverblik.Charge{
+					PriceID:       tariff.PriceID,
+					Name:          tariff.Name,
+					Description:   tariff.Description,
+					Owner:         tariff.Owner,
+					ValidFromDate: tariff.ValidFromDate,
+					ValidToDate:   tariff.ValidToDate,
+					PeriodType:    tariff.PeriodType,
+				})
+				for _, price := range tariff.Prices {
+					priced := base
+					priced.Position = price.Position
+					priced.Price = price.Price
+					rows = append(rows, priced)
+				}
+			}
+		}
+		t := structTable(rows)
+		t.defaults = []string{"meteringPointId", "chargeType", "name", "owner", "validFromDate", "validToDate", "position", "price", "quantity"}
+		return t
+	}
+}
+
+// customerChargesTable lays out the charges the Customer API returns.
+func customerChargesTable(charges []eloverblik.CustomerChargeResponse) func() table {
+	sets := make([]meteringPointCharges, 0, len(charges))
+	for _, c := range charges {
+		sets = append(sets, meteringPointCharges{
+			meteringPointID: c.Result.MeteringPointID,
+			subscriptions:   c.Result.Subscriptions,
+			fees:            c.Result.Fees,
+			tariffs:         c.Result.Tariffs,
+		})
+	}
+	return chargesTable(sets)
+}
+
+// thirdPartyChargesTable lays out the charges the Third-Party API returns, which carry
+// no fees.
+func thirdPartyChargesTable(charges []eloverblik.ThirdPartyChargeResponse) func() table {
+	sets := make([]meteringPointCharges, 0, len(charges))
+	for _, c := range charges {
+		sets = append(sets, meteringPointCharges{
+			meteringPointID: c.Result.MeteringPointID,
+			subscriptions:   c.Result.Subscriptions,
+			tariffs:         c.Result.Tariffs,
+		})
+	}
+	return chargesTable(sets)
+}
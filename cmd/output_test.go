@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func outputTestTimeSeries() []eloverblik.TimeSeries {
+	start := time.Date(2025, 12, 31, 23, 0, 0, 0, time.UTC)
+	return []eloverblik.TimeSeries{{
+		MyEnergyDataMarketDocument: eloverblik.MyEnergyDataMarketDocumentResponse{
+			TimeSeries: []eloverblik.TimeSeriesTimeSeriesResponse{{
+				MRID:                "571313174002485069",
+				MeasurementUnitName: "KWH",
+				Periods: []eloverblik.PeriodResponse{{
+					Resolution:   "PT1H",
+					TimeInterval: eloverblik.TimeInterval{Start: start, End: start.Add(2 * time.Hour)},
+					Points: []eloverblik.PointResponse{
+						{Position: 1, OutQuantityQuantity: 0.5, OutQuantityQuality: "A04"},
+						{Position: 2, OutQuantityQuantity: 0.25, OutQuantityQuality: "A04"},
+					},
+				}},
+			}},
+		},
+	}}
+}
+
+func TestTimeseriesOutputFormats(t *testing.T) {
+	mock := &MockClient{
+		GetTimeSeriesFunc: func([]string, time.Time, time.Time, eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			return outputTestTimeSeries(), nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	t.Run("csv carries a metering point column", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--output", "csv", "--token", "dummy")
+		assert.NoError(t, err)
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, "meteringPointId", records[0][0])
+		assert.Equal(t, "571313174002485069", records[1][0])
+		assert.Equal(t, "0.25", records[2][3])
+	})
+
+	t.Run("ndjson writes a line per point", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--output", "ndjson", "--token", "dummy")
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"meteringPointId":"571313174002485069"`)
+	})
+
+	t.Run("parquet writes a parquet file", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--output", "parquet", "--token", "dummy")
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("PAR1")), "a parquet file starts with its magic number")
+	})
+
+	t.Run("influx writes a line per point", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--output", "influx", "--token", "dummy")
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], "meteringPointId=571313174002485069")
+	})
+
+	t.Run("--output-format is another name of --output", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--output-format", "ndjson", "--token", "dummy")
+		assert.NoError(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 2)
+	})
+
+	t.Run("timescale writes a psql script", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "-o", "timescale", "--token", "dummy")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(buf.String(), "BEGIN;"))
+		assert.True(t, strings.HasSuffix(buf.String(), "COMMIT;\n"))
+	})
+
+	t.Run("json with --flatten keys the points by metering point", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "--flatten", "--token", "dummy")
+		assert.NoError(t, err)
+
+		var flattened map[string][]eloverblik.FlatTimeSeriesPoint
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &flattened))
+		assert.Len(t, flattened["571313174002485069"], 2)
+		assert.True(t, strings.HasSuffix(buf.String(), "}\n"), "the document ends in a newline")
+	})
+}
+
+func TestDetailsCSV(t *testing.T) {
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func([]string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			return []eloverblik.MeteringPointDetailsResponse{
+				{
+					Result:         eloverblik.MeteringPointDetail{MeteringPointID: "571313174002485069", TypeOfMP: "E17"},
+					StatusResponse: eloverblik.StatusResponse{Success: true},
+				},
+				{
+					StatusResponse: eloverblik.StatusResponse{ID: "571313174002485070", ErrorCode: 20008, ErrorText: "Meteringpoint not found"},
+				},
+			}, nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	_, err := execute(t, "customer", "details", "571313174002485069", "571313174002485070", "--output", "csv", "--token", "dummy")
+	assert.NoError(t, err)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	column := func(name string) int {
+		for i, c := range records[0] {
+			if c == name {
+				return i
+			}
+		}
+		t.Fatalf("no column %s in %v", name, records[0])
+		return -1
+	}
+	assert.Equal(t, "E17", records[1][column("typeOfMP")])
+	assert.Equal(t, "571313174002485070", records[2][column("meteringPointId")], "a failed item is identified by its status ID")
+	assert.Equal(t, "20008", records[2][column("errorCode")])
+	assert.NotContains(t, records[0], "contactAddresses", "nested lists have no column")
+}
+
+func TestChargesTable(t *testing.T) {
+	charges := []eloverblik.CustomerChargeResponse{{
+		Result: eloverblik.CustomerCharges{
+			MeteringPointID: "571313174002485069",
+			Subscriptions:   []eloverblik.Charge{{Name: "Net abo", Price: 21.5, Quantity: 1}},
+			Fees:            []eloverblik.Charge{{Name: "Gebyr", Price: 100}},
+			Tariffs: []eloverblik.TariffCharge{{
+				Name:   "Nettarif",
+				Prices: []eloverblik.TariffPrice{{Position: "1", Price: 0.1}, {Position: "2", Price: 0.2}},
+			}},
+		},
+	}}
+
+	tbl := customerChargesTable(charges)()
+	assert.Len(t, tbl.rows, 4, "one row per subscription and fee, one per tariff position")
+	assert.Equal(t, []string{"meteringPointId", "chargeType"}, tbl.columns[:2])
+	assert.Equal(t, "subscription", tbl.rows[0][1])
+	assert.Equal(t, "fee", tbl.rows[1][1])
+	assert.Equal(t, "tariff", tbl.rows[3][1])
+}
+
+func TestWriteResult(t *testing.T) {
+	newCmd := func(format string) *cobra.Command {
+		cmd := &cobra.Command{}
+		addOutputFormatFlag(cmd)
+		_ = cmd.Flags().Set("output", format)
+		return cmd
+	}
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	t.Run("rejects an unknown format", func(t *testing.T) {
+		err := writeResult(newCmd("xml"), result{value: []string{}})
+		assert.ErrorContains(t, err, "unknown output format 'xml'")
+	})
+
+	t.Run("parquet, influx and timescale need a time series", func(t *testing.T) {
+		for _, format := range []string{"parquet", "influx", "timescale"} {
+			err := writeResult(newCmd(format), result{value: []string{}})
+			assert.ErrorContains(t, err, "only supported for time series", format)
+		}
+	})
+
+	t.Run("csv needs a table", func(t *testing.T) {
+		err := writeResult(newCmd("csv"), result{value: []string{}})
+		assert.ErrorContains(t, err, "no tabular output")
+	})
+
+	t.Run("ndjson writes one line per element", func(t *testing.T) {
+		buf.Reset()
+		err := writeResult(newCmd("ndjson"), result{value: []eloverblik.Authorization{{ID: "a"}, {ID: "b"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	})
+}
+
+func TestTableOutput(t *testing.T) {
+	mock := &MockClient{
+		GetTimeSeriesFunc: func([]string, time.Time, time.Time, eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			return outputTestTimeSeries(), nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	oldIsTerminal := isTerminal
+	defer func() { isTerminal = oldIsTerminal }()
+
+	t.Run("renders aligned default columns on a terminal", func(t *testing.T) {
+		isTerminal = func(io.Writer) bool { return true }
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "-o", "table", "--token", "dummy")
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 3)
+		assert.Equal(t, []string{"METERINGPOINTID", "FROM", "TO", "MEASUREMENT", "UNIT", "QUALITY"}, strings.Fields(lines[0]))
+		assert.Equal(t, strings.Index(lines[0], "FROM"), strings.Index(lines[1], "2026-01-01T00:00:00+01:00"), "columns are aligned")
+	})
+
+	t.Run("--fields picks the columns and their order", func(t *testing.T) {
+		isTerminal = func(io.Writer) bool { return true }
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "-o", "table", "--fields", "measurement,from", "--token", "dummy")
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, []string{"MEASUREMENT", "FROM"}, strings.Fields(lines[0]))
+		assert.Equal(t, "0.5", strings.Fields(lines[1])[0])
+	})
+
+	t.Run("falls back to JSON when piped", func(t *testing.T) {
+		isTerminal = func(io.Writer) bool { return false }
+		buf.Reset()
+		_, err := execute(t, "customer", "timeseries", "571313174002485069", "--from", "2026-01-01", "-o", "table", "--token", "dummy")
+		assert.NoError(t, err)
+		assert.True(t, json.Valid(buf.Bytes()))
+	})
+}
+
+func TestSelectColumns(t *testing.T) {
+	tbl := table{columns: []string{"a", "b", "c"}, rows: [][]string{{"1", "2", "3"}}}
+
+	selected, err := tbl.selectColumns([]string{"c", "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, selected.columns)
+	assert.Equal(t, [][]string{{"3", "1"}}, selected.rows)
+
+	_, err = tbl.selectColumns([]string{"d"})
+	assert.ErrorContains(t, err, "unknown field 'd', use any of: a, b, c")
+}
+
+func TestDefaultColumnsExist(t *testing.T) {
+	// A default column that is not a column would make the table output fail on every
+	// call, so every table's defaults must be selectable.
+	tables := map[string]table{
+		"installations":  installationsTable(nil)(),
+		"authorizations": authorizationsTable(nil)(),
+		"changes":        authorizationChangesTable(nil)(),
+		"details":        detailsTable(nil)(),
+		"charges":        customerChargesTable(nil)(),
+		"readings":       readingsTable(nil)(),
+		"reconcile":      reconcileTable(nil)(),
+		"timeseries":     seriesTable(nil),
+	}
+	for name, tbl := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.NotEmpty(t, tbl.defaults)
+			_, err := tbl.selectColumns(tbl.defaults)
+			assert.NoError(t, err)
+		})
+	}
+}
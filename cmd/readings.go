@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+// newReadingsCmd builds a fresh command instance for each of the customer and thirdparty
+// commands, as cobra stores the parent on the command itself.
+func newReadingsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "readings <metering-id> [metering-id ...]",
+		Short: "Get meter (register) readings for one or more metering points",
+		Long: "Get the register readings of one or more metering points: the counter value of a\n" +
+			"meter on a date, as read by hand on meters that are not remotely read and submitted to\n" +
+			"DataHub. A reading is the absolute register value, not a consumption.\n\n" +
+			"Submitting readings has not been mandatory since the end of 2021, so many metering\n" +
+			"points have none. The endpoint is described in Energinet's technical description but\n" +
+			"not in their OpenAPI documents.",
+		Args: meteringPointArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			period, _ := cmd.Flags().GetString("period")
+
+			// Check for mutual exclusivity and requirements
+			if period != "" {
+				if cmd.Flags().Changed("from") || cmd.Flags().Changed("to") {
+					return errors.New("--period cannot be used with --from or --to")
+				}
+			} else {
+				if !cmd.Flags().Changed("from") {
+					return errors.New("either --period or --from is required")
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			period, _ := cmd.Flags().GetString("period")
+			fromFlag, _ := cmd.Flags().GetString("from")
+			toFlag, _ := cmd.Flags().GetString("to")
+
+			var from, to time.Time
+			var err error
+
+			if period != "" {
+				from, to, err = eloverblik.GetDatesFromPeriod(eloverblik.Period(period))
+				cobra.CheckErr(err)
+			} else {
+				from, err = parseDate(fromFlag)
+				cobra.CheckErr(err)
+				to, err = parseDate(toFlag)
+				cobra.CheckErr(err)
+			}
+
+			readings, err := clientInstance.GetMeterReadings(args, from, to)
+			cobra.CheckErr(err)
+			cobra.CheckErr(writeResult(cmd, result{value: readings, table: readingsTable(readings)}))
+		},
+	}
+	cmd.Flags().String("from", "", "start date (YYYY-MM-DD, now, now-30d/w/m/y)")
+	cmd.Flags().String("to", time.Now().Format(time.DateOnly), "end date (YYYY-MM-DD, now, now-30d/w/m/y, defaults to today)")
+	cmd.Flags().String("period", "", "predefined period (yesterday, last_week, etc.)")
+	addOutputFormatFlag(cmd)
+	return cmd
+}
+
+// newReconcileCmd builds a fresh command instance for each of the customer and
+// thirdparty commands, as cobra stores the parent on the command itself.
+func newReconcileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reconcile <metering-id>",
+		Short: "Check a metering point's register readings against its time series",
+		Long: "Check the register readings of a metering point against its hourly time series. Every\n" +
+			"pair of consecutive readings makes an interval, in which the consumption the counter\n" +
+			"shows, multiplied by the meter's multiply factor and corrected for a rollover of its\n" +
+			"digits, is compared with the consumption the time series measured.\n\n" +
+			"An interval is not complete when the time series does not cover all of it, and has\n" +
+			"no register consumption when the meter was replaced between the two readings.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			return meteringPointArgs(cmd, args)
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			period, _ := cmd.Flags().GetString("period")
+
+			// Check for mutual exclusivity and requirements
+			if period != "" {
+				if cmd.Flags().Changed("from") || cmd.Flags().Changed("to") {
+					return errors.New("--period cannot be used with --from or --to")
+				}
+			} else {
+				if !cmd.Flags().Changed("from") {
+					return errors.New("either --period or --from is required")
+				}
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			period, _ := cmd.Flags().GetString("period")
+			fromFlag, _ := cmd.Flags().GetString("from")
+			toFlag, _ := cmd.Flags().GetString("to")
+
+			var from, to time.Time
+			var err error
+
+			if period != "" {
+				from, to, err = eloverblik.GetDatesFromPeriod(eloverblik.Period(period))
+				cobra.CheckErr(err)
+			} else {
+				from, err = parseDate(fromFlag)
+				cobra.CheckErr(err)
+				to, err = parseDate(toFlag)
+				cobra.CheckErr(err)
+			}
+
+			rec, err := reconcileRegister(args[0], from, to)
+			cobra.CheckErr(err)
+			cobra.CheckErr(writeResult(cmd, result{value: rec, table: reconcileTable(rec)}))
+		},
+	}
+	cmd.Flags().String("from", "", "start date (YYYY-MM-DD, now, now-30d/w/m/y)")
+	cmd.Flags().String("to", time.Now().Format(time.DateOnly), "end date (YYYY-MM-DD, now, now-30d/w/m/y, defaults to today)")
+	cmd.Flags().String("period", "", "predefined period (yesterday, last_week, etc.)")
+	addOutputFormatFlag(cmd)
+	return cmd
+}
+
+// reconcileRegister fetches the meter's register, its readings and its hourly time
+// series, and reconciles them.
+func reconcileRegister(id string, from, to time.Time) ([]eloverblik.RegisterReconciliation, error) {
+	ids := []string{id}
+
+	details, err := clientInstance.GetMeteringPointDetails(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, fmt.Errorf("no details for metering point %s", id)
+	}
+	if err := statusError(id, details[0].StatusResponse); err != nil {
+		return nil, err
+	}
+	register, err := details[0].Result.MeterRegister()
+	if err != nil {
+		return nil, err
+	}
+
+	readings, err := clientInstance.GetMeterReadings(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var meterReadings []eloverblik.MeterReading
+	for _, r := range readings {
+		if err := statusError(id, r.StatusResponse); err != nil {
+			return nil, err
+		}
+		meterReadings = append(meterReadings, r.Result.Readings...)
+	}
+
+	series, err := clientInstance.GetTimeSeries(ids, from, to, eloverblik.Hour)
+	if err != nil {
+		return nil, err
+	}
+	var points []eloverblik.FlatTimeSeriesPoint
+	for _, ts := range series {
+		if err := statusError(id, ts.StatusResponse); err != nil {
+			return nil, err
+		}
+		points = slices.AppendSeq(points, ts.Points())
+	}
+
+	return eloverblik.ReconcileRegister(register, meterReadings, points)
+}
+
+// statusError turns the status of a metering point the API could not answer for into an
+// error.
+func statusError(id string, status eloverblik.StatusResponse) error {
+	if status.Success {
+		return nil
+	}
+	return fmt.Errorf("metering point %s: %s (%d)", id, status.ErrorText, status.ErrorCode)
+}
+
+func init() {
+	customerCmd.AddCommand(newReadingsCmd())
+	thirdpartyCmd.AddCommand(newReadingsCmd())
+	customerCmd.AddCommand(newReconcileCmd())
+	thirdpartyCmd.AddCommand(newReconcileCmd())
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadingsCmd(t *testing.T) {
+	mock := &MockClient{
+		GetMeterReadingsFunc: func(meteringPointIDs []string, from, to time.Time) ([]eloverblik.MeterReadingsResponse, error) {
+			assert.Equal(t, []string{"571313174002485069", "571313174002485070"}, meteringPointIDs)
+			assert.Equal(t, "2025-01-01", from.Format(time.DateOnly))
+			return []eloverblik.MeterReadingsResponse{
+				{
+					Result: eloverblik.MeterReadings{
+						MeteringPointID: "571313174002485069",
+						Readings: []eloverblik.MeterReading{
+							{MeterNumber: "12345678", MeterReading: 10234.5, MeasurementUnit: "KWH"},
+							{MeterNumber: "12345678", MeterReading: 11020, MeasurementUnit: "KWH"},
+						},
+					},
+					StatusResponse: eloverblik.StatusResponse{Success: true},
+				},
+				{
+					StatusResponse: eloverblik.StatusResponse{ID: "571313174002485070", ErrorCode: 20008, ErrorText: "MeteringPointNotFound"},
+				},
+			}, nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	t.Run("json keeps the response", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "thirdparty", "readings", "571313174002485069", "571313174002485070", "--from", "2025-01-01", "--token", "dummy")
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), `"meterReading":10234.5`)
+	})
+
+	t.Run("csv has a row per reading", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "customer", "readings", "571313174002485069", "571313174002485070", "--from", "2025-01-01", "-o", "csv", "--fields", "meteringPointId,meterReading,errorCode", "--token", "dummy")
+		assert.NoError(t, err)
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"meteringPointId", "meterReading", "errorCode"},
+			{"571313174002485069", "10234.5", "0"},
+			{"571313174002485069", "11020", "0"},
+			{"571313174002485070", "0", "20008"},
+		}, records)
+	})
+}
+
+func TestReconcileCmd(t *testing.T) {
+	id := "571313174002485069"
+	cph, _ := time.LoadLocation("Europe/Copenhagen")
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, cph)
+	feb := time.Date(2025, 1, 2, 0, 0, 0, 0, cph)
+
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			return []eloverblik.MeteringPointDetailsResponse{{
+				Result:         eloverblik.MeteringPointDetail{MeteringPointID: id, MeterCounterDigits: "5.0", MeterCounterMultiplyFactor: "1.0", MeterCounterUnit: "KWH"},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+		GetMeterReadingsFunc: func(meteringPointIDs []string, from, to time.Time) ([]eloverblik.MeterReadingsResponse, error) {
+			return []eloverblik.MeterReadingsResponse{{
+				Result: eloverblik.MeterReadings{MeteringPointID: id, Readings: []eloverblik.MeterReading{
+					{ReadingDate: eloverblik.FlexibleTime{Time: jan}, MeterNumber: "1", MeterReading: 99990},
+					{ReadingDate: eloverblik.FlexibleTime{Time: feb}, MeterNumber: "1", MeterReading: 20},
+				}},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			assert.Equal(t, eloverblik.Hour, aggregation)
+			var ts eloverblik.TimeSeries
+			ts.Success = true
+			ts.MyEnergyDataMarketDocument.TimeSeries = []eloverblik.TimeSeriesTimeSeriesResponse{{
+				MRID:                id,
+				MeasurementUnitName: "KWH",
+				Periods: []eloverblik.PeriodResponse{{
+					Resolution:   "P1D",
+					TimeInterval: eloverblik.TimeInterval{Start: jan.UTC(), End: feb.UTC()},
+					Points:       []eloverblik.PointResponse{{Position: 1, OutQuantityQuantity: 25}},
+				}},
+			}}
+			return []eloverblik.TimeSeries{ts}, nil
+		},
+	}
+	clientInstance = mock
+	defer func() { clientInstance = nil }()
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	_, err := execute(t, "customer", "reconcile", id, "--from", "2025-01-01", "-o", "csv", "--fields", "registerConsumption,measuredConsumption,difference,rollover,complete", "--token", "dummy")
+	assert.NoError(t, err)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"registerConsumption", "measuredConsumption", "difference", "rollover", "complete"},
+		{"30", "25", "5", "true", "true"},
+	}, records)
+
+	_, err = execute(t, "customer", "reconcile", id, id, "--from", "2025-01-01", "--token", "dummy")
+	assert.Error(t, err, "a single metering point is reconciled at a time")
+}
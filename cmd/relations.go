@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/slimcdk/go-eloverblik/v1"
@@ -19,10 +18,7 @@ var addRelationByIDCmd = &cobra.Command{
 		}
 		results, err := customerAPI.AddRelationByID(args)
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(results)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeJSON(output, results))
 	},
 }
 
@@ -37,10 +33,7 @@ var addRelationByCodeCmd = &cobra.Command{
 		}
 		result, err := customerAPI.AddRelationByWebAccessCode(args[0], args[1])
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(result)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeJSON(output, result))
 	},
 }
 
@@ -55,10 +48,7 @@ var deleteRelationCmd = &cobra.Command{
 		}
 		ok, err := customerAPI.DeleteRelation(args[0])
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(ok)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeJSON(output, ok))
 	},
 }
 
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+)
+
+// tokenRenewalMargin is how long before its data access token expires a long running
+// command replaces its client, so that no call goes out with a token about to lapse.
+const tokenRenewalMargin = 5 * time.Minute
+
+// clientSource hands the long running commands their client. A client never fetches a
+// new data access token once it holds one, and a token lasts about 24 hours, so the
+// source builds a new client when the token of the current one is about to expire.
+type clientSource struct {
+	// build builds a new client. Without it the first client is kept.
+	build func() eloverblik.Client
+	now   func() time.Time
+
+	mu     sync.Mutex
+	client eloverblik.Client
+}
+
+func newClientSource(client eloverblik.Client, build func() eloverblik.Client) *clientSource {
+	return &clientSource{build: build, now: time.Now, client: client}
+}
+
+// get returns the current client, after replacing it if its data access token expires
+// within tokenRenewalMargin. A token that cannot be fetched or read leaves the client as
+// it is, for the call to report the error.
+func (s *clientSource) get() eloverblik.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.build == nil {
+		return s.client
+	}
+	claims, err := s.client.DataAccessTokenClaims()
+	if err == nil && !claims.ExpiresAt.IsZero() && s.now().Add(tokenRenewalMargin).After(claims.ExpiresAt) {
+		s.client = s.build()
+	}
+	return s.client
+}
@@ -12,6 +12,11 @@ import (
 // clientInstance will hold the instantiated client (either Customer or ThirdParty)
 var clientInstance eloverblik.Client
 
+// buildClient builds a new client the way clientInstance was built, for the long running
+// commands to replace it once its data access token expires. It is nil when
+// clientInstance was not built from a token, as in tests.
+var buildClient func() eloverblik.Client
+
 // headerOutput is the destination for the HTTP response headers printed with
 // --print-response-headers (configurable for testing). It defaults to stderr so
 // stdout stays clean, parseable JSON.
@@ -27,7 +32,16 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	args, prefixed, err := apiPrefixedArgs(os.Args[1:])
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if prefixed {
+		rootCmd.SetArgs(args)
+	}
+
+	err = rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -45,7 +59,12 @@ func rootHelpFunc(cmd *cobra.Command, _ []string) {
 			continue
 		}
 		if sub.HasAvailableSubCommands() {
-			printf("\n  %s\n", sub.Name())
+			// A group that runs by itself as well, such as token, keeps its description
+			if sub.Runnable() {
+				printf("\n  %-26s %s\n", sub.Name(), sub.Short)
+			} else {
+				printf("\n  %s\n", sub.Name())
+			}
 			for _, subsub := range sub.Commands() {
 				if !subsub.IsAvailableCommand() {
 					continue
@@ -63,8 +82,9 @@ func rootHelpFunc(cmd *cobra.Command, _ []string) {
 }
 
 func init() {
-	rootCmd.PersistentFlags().String("token", "", "Eloverblik refresh token (required)")
-	_ = rootCmd.MarkPersistentFlagRequired("token")
+	rootCmd.PersistentFlags().String("token", "", "Eloverblik refresh token (or --token-file, $"+envToken+", or a profile)")
+	rootCmd.PersistentFlags().String("token-file", "", "Read the refresh token from a file, or from stdin with -")
+	rootCmd.PersistentFlags().String("profile", "", "Configuration profile to use (or $"+envProfile+")")
 	rootCmd.PersistentFlags().Bool("print-response-headers", false, "Print HTTP response headers from the Eloverblik API to stderr")
 	rootCmd.SetHelpFunc(rootHelpFunc)
 }
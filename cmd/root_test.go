@@ -17,6 +17,12 @@ import (
 func resetCommandFlags(cmd *cobra.Command) {
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
 		f.Changed = false
+		// A slice flag parses its "[]" default as a single element named "[]", so it
+		// has to be emptied rather than set back to its default text.
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			_ = slice.Replace(nil)
+			return
+		}
 		if f.Value != nil {
 			_ = f.Value.Set(f.DefValue)
 		}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// Token stores a profile can keep its refresh token in, instead of in the config file.
+const (
+	storeKeyring = "keyring"
+	storeFile    = "file"
+)
+
+// keyringService is the service name the refresh tokens are filed under in the OS secret
+// store, with the profile name as the account.
+const keyringService = "go-eloverblik"
+
+// envPassphrase holds the passphrase of the encrypted token file, for unattended use.
+const envPassphrase = "ELOVERBLIK_PASSPHRASE"
+
+// pbkdf2Iterations is the work factor of the encrypted token file's key derivation, the
+// OWASP recommendation for PBKDF2-HMAC-SHA256.
+const pbkdf2Iterations = 600_000
+
+// secretStore keeps refresh tokens outside the config file, one per profile.
+type secretStore interface {
+	get(profile string) (string, error)
+	set(profile, token string) error
+	remove(profile string) error
+}
+
+// errNoSecret is returned by a secretStore that holds no token for a profile.
+var errNoSecret = errors.New("no token stored")
+
+// newSecretStore returns the store a profile's tokenStore names.
+func newSecretStore(kind string) (secretStore, error) {
+	switch kind {
+	case storeKeyring:
+		return keyringStore{}, nil
+	case storeFile:
+		return fileStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store '%s', use %s or %s", kind, storeKeyring, storeFile)
+	}
+}
+
+// keyringStore keeps tokens in the OS secret store: the Keychain on macOS, the Credential
+// Manager on Windows and the Secret Service (GNOME Keyring, KWallet) on Linux.
+type keyringStore struct{}
+
+func (keyringStore) get(profile string) (string, error) {
+	token, err := keyring.Get(keyringService, profile)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", errNoSecret
+	}
+	return token, err
+}
+
+func (keyringStore) set(profile, token string) error {
+	return keyring.Set(keyringService, profile, token)
+}
+
+func (keyringStore) remove(profile string) error {
+	err := keyring.Delete(keyringService, profile)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return errNoSecret
+	}
+	return err
+}
+
+// noSecretService reports whether an error of the keyring means there is no Secret Service
+// to talk to: no session bus to reach, or no service on it answering for the Secret Service.
+// A locked keyring or a declined prompt comes from a Secret Service, and is not one.
+func noSecretService(err error) bool {
+	var dbusErr *dbus.Error
+	if errors.As(err, &dbusErr) {
+		switch {
+		case dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown",
+			dbusErr.Name == "org.freedesktop.DBus.Error.NameHasNoOwner",
+			strings.HasPrefix(dbusErr.Name, "org.freedesktop.DBus.Error.Spawn."):
+			return true
+		}
+		return false
+	}
+
+	// Finding the session bus runs dbus-launch when no address is set, and connecting to
+	// it dials a socket
+	var execErr *exec.Error
+	var exitErr *exec.ExitError
+	var netErr *net.OpError
+	return errors.As(err, &execErr) || errors.As(err, &exitErr) || errors.As(err, &netErr) ||
+		strings.HasPrefix(err.Error(), "dbus: couldn't determine address of session bus")
+}
+
+// fileStore keeps tokens in a file next to the config file, for machines without a
+// secret service, such as a headless Linux server. Every token is sealed with AES-256-GCM
+// under a key derived from a passphrase, so the file is worthless without it.
+type fileStore struct{}
+
+// sealedToken is a token as the encrypted file holds it.
+type sealedToken struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Iterations int    `json:"iterations"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// readPassphrase returns the passphrase of the encrypted token file: $ELOVERBLIK_PASSPHRASE,
+// or else one typed at the terminal without echo (configurable for testing).
+var readPassphrase = func() (string, error) {
+	if passphrase := os.Getenv(envPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd()) // #nosec G115 -- a file descriptor fits an int
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the token file needs a passphrase: set %s or run in a terminal", envPassphrase)
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, "Passphrase for the token file: ")
+	passphrase, err := term.ReadPassword(fd)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	return string(passphrase), nil
+}
+
+// tokenFilePath returns the location of the encrypted token file: tokens.json beside the
+// config file.
+func tokenFilePath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "tokens.json"), nil
+}
+
+func (fileStore) load() (map[string]sealedToken, error) {
+	sealed := map[string]sealedToken{}
+
+	path, err := tokenFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- the path is the user's own token file
+	if errors.Is(err, os.ErrNotExist) {
+		return sealed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("cannot read token file %s: %w", path, err)
+	}
+	return sealed, nil
+}
+
+func (fileStore) save(sealed map[string]sealedToken) error {
+	path, err := tokenFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func (s fileStore) get(profile string) (string, error) {
+	sealed, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	entry, ok := sealed[profile]
+	if !ok {
+		return "", errNoSecret
+	}
+
+	passphrase, err := readPassphrase()
+	if err != nil {
+		return "", err
+	}
+	aead, err := fileCipher(passphrase, entry.Salt, entry.Iterations)
+	if err != nil {
+		return "", err
+	}
+	token, err := aead.Open(nil, entry.Nonce, entry.Ciphertext, []byte(profile))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt the token of profile '%s': wrong passphrase?", profile)
+	}
+	return string(token), nil
+}
+
+func (s fileStore) set(profile, token string) error {
+	sealed, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase()
+	if err != nil {
+		return err
+	}
+
+	entry := sealedToken{Salt: make([]byte, 16), Iterations: pbkdf2Iterations}
+	if _, err := io.ReadFull(rand.Reader, entry.Salt); err != nil {
+		return err
+	}
+	aead, err := fileCipher(passphrase, entry.Salt, entry.Iterations)
+	if err != nil {
+		return err
+	}
+	entry.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, entry.Nonce); err != nil {
+		return err
+	}
+
+	// The profile name is authenticated along with the token, so a sealed token cannot be
+	// moved to another profile's entry unnoticed.
+	entry.Ciphertext = aead.Seal(nil, entry.Nonce, []byte(token), []byte(profile))
+
+	sealed[profile] = entry
+	return s.save(sealed)
+}
+
+func (s fileStore) remove(profile string) error {
+	sealed, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := sealed[profile]; !ok {
+		return errNoSecret
+	}
+	delete(sealed, profile)
+	return s.save(sealed)
+}
+
+// fileCipher derives the AES-256-GCM cipher of a sealed token from the passphrase.
+func fileCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
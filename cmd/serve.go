@@ -0,0 +1,565 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultGatewayAddress only listens on the loopback interface: the gateway serves the
+	// token's data to anyone who can reach it.
+	defaultGatewayAddress = "localhost:8080"
+	// defaultGatewayCacheTTL is how long the gateway answers a request from its cache.
+	defaultGatewayCacheTTL = 5 * time.Minute
+	// defaultGatewayRateLimit is the calls per minute the gateway makes by default, half of
+	// the 120 the API allows, leaving room for retries and other clients on the same IP.
+	defaultGatewayRateLimit = 60
+	// maxGatewayRateLimit is the 120 calls per minute the API allows.
+	maxGatewayRateLimit = 120
+)
+
+// gateway serves a small REST API over a client, so that apps in other languages get the
+// data without handling tokens themselves. Responses are cached, identical requests in
+// flight share a single call, and calls wait their turn under the rate limit. The client is
+// replaced when its data access token expires.
+type gateway struct {
+	api     string
+	clients *clientSource
+	cache   *responseCache
+	limiter *rateLimiter
+}
+
+func newGateway(api string, clients *clientSource, cacheTTL time.Duration, callsPerMinute int) *gateway {
+	return &gateway{
+		api:     api,
+		clients: clients,
+		cache:   newResponseCache(cacheTTL),
+		// A minute of calls may queue
+		limiter: &rateLimiter{interval: time.Minute / time.Duration(callsPerMinute), maxQueue: callsPerMinute},
+	}
+}
+
+// handler returns the routes of the gateway.
+func (g *gateway) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeGatewayResponse(w, cachedResponse{status: http.StatusOK, body: []byte(`{"status":"ok"}`)})
+	})
+	mux.Handle("GET /v1/installations", g.handle(g.installations))
+	mux.Handle("GET /v1/metering-points/{id}/details", g.handle(g.details))
+	mux.Handle("GET /v1/metering-points/{id}/timeseries", g.handle(g.timeSeries))
+	mux.Handle("GET /v1/metering-points/{id}/charges", g.handle(g.charges))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeGatewayResponse(w, problemResponse(&gatewayError{http.StatusNotFound, fmt.Errorf("no route for %s %s", r.Method, r.URL.Path)}))
+	})
+	return mux
+}
+
+// handle serves the value fn returns as JSON, or its error as a problem document, from the
+// cache when it can.
+func (g *gateway) handle(fn func(r *http.Request) (any, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path + "?" + r.URL.Query().Encode()
+		writeGatewayResponse(w, g.cache.do(key, func() cachedResponse {
+			value, err := fn(r)
+			if err != nil {
+				return problemResponse(err)
+			}
+			body, err := json.Marshal(value)
+			if err != nil {
+				return problemResponse(err)
+			}
+			return cachedResponse{status: http.StatusOK, body: body}
+		}))
+	})
+}
+
+func (g *gateway) installations(r *http.Request) (any, error) {
+	customerAPI, ok := g.clients.get().(eloverblik.Customer)
+	if !ok || g.api != apiCustomer {
+		return nil, &gatewayError{http.StatusNotFound, errors.New("installations are only available with a customer token")}
+	}
+	includeAll, err := queryBool(r, "includeAll")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.limiter.wait(r.Context()); err != nil {
+		return nil, err
+	}
+	return customerAPI.GetMeteringPoints(includeAll)
+}
+
+func (g *gateway) details(r *http.Request) (any, error) {
+	id, err := pathMeteringPointID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.limiter.wait(r.Context()); err != nil {
+		return nil, err
+	}
+	details, err := g.clients.get().GetMeteringPointDetails([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, &gatewayError{http.StatusNotFound, eloverblik.ErrorMeteringPointNotFound}
+	}
+	if err := itemErr(details[0].StatusResponse); err != nil {
+		return nil, err
+	}
+	return details[0].Result, nil
+}
+
+// gatewayTimeSeries is the answer of the time series route.
+type gatewayTimeSeries struct {
+	MeteringPointID string                           `json:"meteringPointId"`
+	Aggregation     eloverblik.Aggregation           `json:"aggregation"`
+	Points          []eloverblik.FlatTimeSeriesPoint `json:"points"`
+}
+
+func (g *gateway) timeSeries(r *http.Request) (any, error) {
+	id, err := pathMeteringPointID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") == "" {
+		return nil, &gatewayError{http.StatusBadRequest, errors.New("the query parameter 'from' is required")}
+	}
+	from, err := parseDate(query.Get("from"))
+	if err != nil {
+		return nil, &gatewayError{http.StatusBadRequest, err}
+	}
+	to := time.Now()
+	if query.Has("to") {
+		if to, err = parseDate(query.Get("to")); err != nil {
+			return nil, &gatewayError{http.StatusBadRequest, err}
+		}
+	}
+
+	aggregation := eloverblik.Hour
+	if query.Has("aggregation") {
+		aggregation = eloverblik.Aggregation(query.Get("aggregation"))
+	}
+	if !validAggregation(aggregation) {
+		return nil, &gatewayError{http.StatusBadRequest, fmt.Errorf("invalid aggregation '%s'", aggregation)}
+	}
+
+	if err := g.limiter.wait(r.Context()); err != nil {
+		return nil, err
+	}
+	series, err := g.clients.get().GetTimeSeries([]string{id}, from, to, aggregation)
+	if err != nil {
+		return nil, err
+	}
+
+	result := gatewayTimeSeries{MeteringPointID: id, Aggregation: aggregation, Points: []eloverblik.FlatTimeSeriesPoint{}}
+	for _, ts := range series {
+		if err := itemErr(ts.StatusResponse); err != nil {
+			return nil, err
+		}
+		result.Points = slices.AppendSeq(result.Points, ts.Points())
+	}
+
+	// Resampling sums into coarser intervals, which the API could have done, but it lets
+	// a caller derive several of them from a single cached fetch.
+	if query.Has("resample") {
+		result.Aggregation = eloverblik.Aggregation(query.Get("resample"))
+		if result.Points, err = eloverblik.Resample(result.Points, result.Aggregation); err != nil {
+			return nil, &gatewayError{http.StatusBadRequest, err}
+		}
+		if result.Points == nil {
+			result.Points = []eloverblik.FlatTimeSeriesPoint{}
+		}
+	}
+	return result, nil
+}
+
+func (g *gateway) charges(r *http.Request) (any, error) {
+	id, err := pathMeteringPointID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.limiter.wait(r.Context()); err != nil {
+		return nil, err
+	}
+	if g.api == apiThirdParty {
+		thirdpartyAPI, ok := g.clients.get().(eloverblik.ThirdParty)
+		if !ok {
+			return nil, errors.New("the client cannot get third party charges")
+		}
+		charges, err := thirdpartyAPI.GetThirdPartyCharges([]string{id})
+		if err != nil {
+			return nil, err
+		}
+		if len(charges) == 0 {
+			return nil, &gatewayError{http.StatusNotFound, eloverblik.ErrorMeteringPointNotFound}
+		}
+		if err := itemErr(charges[0].StatusResponse); err != nil {
+			return nil, err
+		}
+		return charges[0].Result, nil
+	}
+
+	customerAPI, ok := g.clients.get().(eloverblik.Customer)
+	if !ok {
+		return nil, errors.New("the client cannot get customer charges")
+	}
+	charges, err := customerAPI.GetCustomerCharges([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(charges) == 0 {
+		return nil, &gatewayError{http.StatusNotFound, eloverblik.ErrorMeteringPointNotFound}
+	}
+	if err := itemErr(charges[0].StatusResponse); err != nil {
+		return nil, err
+	}
+	return charges[0].Result, nil
+}
+
+// pathMeteringPointID reads the metering point ID of a route, which must be 18 digits.
+func pathMeteringPointID(r *http.Request) (string, error) {
+	id := r.PathValue("id")
+	if _, err := strconv.ParseUint(id, 10, 64); len(id) != 18 || err != nil {
+		return "", &gatewayError{http.StatusBadRequest, fmt.Errorf("invalid metering point ID '%s': it must be 18 digits", id)}
+	}
+	return id, nil
+}
+
+// queryBool reads an optional boolean query parameter, false when absent.
+func queryBool(r *http.Request, name string) (bool, error) {
+	if !r.URL.Query().Has(name) {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(r.URL.Query().Get(name))
+	if err != nil {
+		return false, &gatewayError{http.StatusBadRequest, fmt.Errorf("invalid value for '%s': %w", name, err)}
+	}
+	return value, nil
+}
+
+func validAggregation(aggregation eloverblik.Aggregation) bool {
+	switch aggregation {
+	case eloverblik.Actual, eloverblik.Quarter, eloverblik.Hour, eloverblik.Day, eloverblik.Month, eloverblik.Year:
+		return true
+	}
+	return false
+}
+
+// gatewayError is an error of the gateway's own, answered with its status.
+type gatewayError struct {
+	status int
+	err    error
+}
+
+func (e *gatewayError) Error() string { return e.err.Error() }
+func (e *gatewayError) Unwrap() error { return e.err }
+
+// itemStatusError is the failed status of a result item, which keeps its API error code.
+type itemStatusError struct {
+	status eloverblik.StatusResponse
+}
+
+func (e *itemStatusError) Error() string { return e.status.Err().Error() }
+func (e *itemStatusError) Unwrap() error { return e.status.Err() }
+
+// itemErr returns the error the status of a result item stands for, nil on success.
+func itemErr(status eloverblik.StatusResponse) error {
+	if status.Success {
+		return nil
+	}
+	return &itemStatusError{status}
+}
+
+// gatewayProblem is the error document of the gateway, in the RFC 7807 shape the API uses.
+type gatewayProblem struct {
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	// Code is the API error code, e.g. 20008, when the API sent one.
+	Code uint64 `json:"code,omitempty"`
+	// TraceID is the trace ID of the API's own error document. Quote it to Energinet.
+	TraceID string `json:"traceId,omitempty"`
+	// UpstreamStatus is the status the API answered with, when it differs from Status.
+	UpstreamStatus int `json:"upstreamStatus,omitempty"`
+}
+
+// gatewayStatuses are the statuses of the API errors a caller can act on. Any other
+// error of the API is a 502: the gateway's own request failed.
+var gatewayStatuses = []struct {
+	err    error
+	status int
+}{
+	{eloverblik.ErrCircuitOpen, http.StatusServiceUnavailable},
+	{eloverblik.ErrorTooManyRequests, http.StatusTooManyRequests},
+	{eloverblik.ErrorMeteringPointNotFound, http.StatusNotFound},
+	{eloverblik.ErrorNoMeteringPointDataAviliable, http.StatusNotFound},
+	{eloverblik.ErrorMeteringPointBlocked, http.StatusForbidden},
+	{eloverblik.ErrorAccessToMeteringPointDenied, http.StatusForbidden},
+	{eloverblik.ErrorDateNotCoveredByAuthorization, http.StatusForbidden},
+	{eloverblik.ErrorFromDateIsGreaterThanToday, http.StatusBadRequest},
+	{eloverblik.ErrorFromDateIsGreaterThanToDate, http.StatusBadRequest},
+	{eloverblik.ErrorToDateCanNotBeEqualToFromDate, http.StatusBadRequest},
+	{eloverblik.ErrorToDateIsGreaterThanToday, http.StatusBadRequest},
+	{eloverblik.ErrorInvalidDateFormat, http.StatusBadRequest},
+	{eloverblik.ErrorNumberOfDaysExcceded, http.StatusBadRequest},
+	{eloverblik.ErrorRequestedAggregationUnavaliable, http.StatusBadRequest},
+	{eloverblik.ErrorAggrationNotValid, http.StatusBadRequest},
+	{eloverblik.ErrorInvalidMeteringpointId, http.StatusBadRequest},
+}
+
+// problemResponse renders an error as a problem document.
+func problemResponse(err error) cachedResponse {
+	problem := gatewayProblem{Status: http.StatusBadGateway, Detail: err.Error()}
+
+	var gatewayErr *gatewayError
+	var apiErr *eloverblik.APIError
+	var statusErr *itemStatusError
+	switch {
+	case errors.As(err, &gatewayErr):
+		problem.Status = gatewayErr.status
+	case errors.As(err, &apiErr):
+		problem.Code = apiErr.Code
+		problem.TraceID = apiErr.TraceID
+		problem.UpstreamStatus = apiErr.StatusCode
+	case errors.As(err, &statusErr):
+		problem.Code = uint64(statusErr.status.ErrorCode)
+	}
+
+	if gatewayErr == nil {
+		for _, s := range gatewayStatuses {
+			if errors.Is(err, s.err) {
+				problem.Status = s.status
+				break
+			}
+		}
+	}
+	if problem.UpstreamStatus == problem.Status {
+		problem.UpstreamStatus = 0
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	body, _ := json.Marshal(problem)
+	return cachedResponse{status: problem.Status, body: body, problem: true}
+}
+
+func writeGatewayResponse(w http.ResponseWriter, response cachedResponse) {
+	contentType := "application/json"
+	if response.problem {
+		contentType = "application/problem+json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(response.status)
+	_, _ = w.Write(response.body)
+}
+
+// cachedResponse is a rendered answer of the gateway.
+type cachedResponse struct {
+	status  int
+	body    []byte
+	problem bool
+}
+
+// responseCache keeps successful answers for a while, and lets identical requests in
+// flight share the answer of the first. Errors are shared by the requests waiting for
+// them, but not kept.
+type responseCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	ready    chan struct{}
+	response cachedResponse
+	expires  time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, now: time.Now, entries: make(map[string]*cacheEntry)}
+}
+
+// do returns the answer kept under key, or the one fetch renders.
+func (c *responseCache) do(key string, fetch func() cachedResponse) cachedResponse {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		select {
+		case <-entry.ready:
+			if c.now().Before(entry.expires) {
+				c.mu.Unlock()
+				return entry.response
+			}
+		default:
+			c.mu.Unlock()
+			<-entry.ready
+			return entry.response
+		}
+	}
+
+	// Drop what expired, so the cache holds no more than the keys of one TTL
+	for key, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			if !c.now().Before(entry.expires) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+
+	entry := &cacheEntry{ready: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	entry.response = fetch()
+
+	c.mu.Lock()
+	if entry.response.status == http.StatusOK && c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	} else {
+		delete(c.entries, key)
+	}
+	close(entry.ready)
+	c.mu.Unlock()
+
+	return entry.response
+}
+
+// rateLimiter spaces calls evenly: each call waits for the next free slot. Calls queue in
+// the order they ask, at most maxQueue of them; one more is turned away with a 429 rather
+// than left waiting for minutes.
+type rateLimiter struct {
+	interval time.Duration
+	maxQueue int
+
+	mu      sync.Mutex
+	next    time.Time
+	waiting int
+}
+
+// wait waits for the next free slot, or until ctx is done. A call that stops waiting gives
+// its place in the queue back, and its slot too when no call queued after it.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.waiting >= l.maxQueue {
+		l.mu.Unlock()
+		return &gatewayError{http.StatusTooManyRequests, fmt.Errorf("%d requests are already waiting for the rate limit", l.maxQueue)}
+	}
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.waiting++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.waiting--
+		if l.next.Equal(slot.Add(l.interval)) {
+			l.next = slot
+		}
+		l.mu.Unlock()
+		return &gatewayError{http.StatusServiceUnavailable, fmt.Errorf("the request ended while waiting for the rate limit: %w", ctx.Err())}
+	}
+}
+
+// newServeCmd builds a fresh "serve" command for the parent of an API, as newAliveCmd
+// does.
+func newServeCmd(api string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a local REST API over the Eloverblik API",
+		Long: `Serve a small REST API over the Eloverblik API, so that apps in any language get the data
+without handling tokens: the gateway holds the refresh token and its data access token.
+
+Routes, all answering JSON:
+  GET /v1/installations[?includeAll=true]                 (customer tokens only)
+  GET /v1/metering-points/{id}/details
+  GET /v1/metering-points/{id}/timeseries?from=&to=&aggregation=&resample=
+  GET /v1/metering-points/{id}/charges
+  GET /healthz
+
+from and to take the dates --from and --to do; to defaults to now and aggregation to Hour.
+resample sums the points into Quarter, Hour, Day, Month or Year intervals.
+
+Successful answers are cached for --cache-ttl, and identical requests in flight share a
+single call. Calls to the API are queued to at most --rate-limit per minute; a request
+finding a minute of calls already queued is answered 429. Errors are RFC 7807 problem
+documents, carrying the API error code and the traceId of the API's own error document
+when there is one.
+
+The gateway has no authentication of its own: anyone who can reach it reads the data of
+the token. It listens on localhost unless --listen says otherwise.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			address, _ := cmd.Flags().GetString("listen")
+			cacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+			rateLimit, _ := cmd.Flags().GetInt("rate-limit")
+
+			if rateLimit < 1 || rateLimit > maxGatewayRateLimit {
+				return fmt.Errorf("--rate-limit must be between 1 and %d calls per minute", maxGatewayRateLimit)
+			}
+			if cacheTTL < 0 {
+				return errors.New("--cache-ttl cannot be negative")
+			}
+
+			g := newGateway(api, newClientSource(clientInstance, buildClient), cacheTTL, rateLimit)
+			server := &http.Server{Addr: address, Handler: g.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				<-ctx.Done()
+				shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = server.Shutdown(shutdown)
+			}()
+
+			_, _ = fmt.Fprintf(messageOutput, "Serving the %s API on http://%s\n", api, address)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("listen", defaultGatewayAddress, "Address to serve the REST API on")
+	cmd.Flags().Duration("cache-ttl", defaultGatewayCacheTTL, "How long to answer a request from the cache (0 disables it)")
+	cmd.Flags().Int("rate-limit", defaultGatewayRateLimit, "Calls per minute to make to the API at most")
+	return cmd
+}
+
+func init() {
+	customerCmd.AddCommand(newServeCmd(apiCustomer))
+	thirdpartyCmd.AddCommand(newServeCmd(apiThirdParty))
+}
@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const gatewayTestID = "571313174002485069"
+
+// get requests a path of the gateway, and returns the status, content type and body.
+func get(t *testing.T, server *httptest.Server, path string) (int, string, []byte) {
+	t.Helper()
+
+	res, err := http.Get(server.URL + path)
+	if !assert.NoError(t, err) {
+		return 0, "", nil
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return res.StatusCode, res.Header.Get("Content-Type"), body
+}
+
+func problem(t *testing.T, body []byte) gatewayProblem {
+	t.Helper()
+
+	var p gatewayProblem
+	assert.NoError(t, json.Unmarshal(body, &p))
+	return p
+}
+
+func newGatewayServer(t *testing.T, api string, client eloverblik.Client, cacheTTL time.Duration) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(newGateway(api, newClientSource(client, nil), cacheTTL, maxGatewayRateLimit*1000).handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGatewayDetails(t *testing.T) {
+	var calls atomic.Int32
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			calls.Add(1)
+			if meteringPointIDs[0] != gatewayTestID {
+				return []eloverblik.MeteringPointDetailsResponse{{
+					StatusResponse: eloverblik.StatusResponse{ID: meteringPointIDs[0], ErrorCode: 20008, ErrorText: "MeteringPointNotFound"},
+				}}, nil
+			}
+			return []eloverblik.MeteringPointDetailsResponse{{
+				Result:         eloverblik.MeteringPointDetail{MeteringPointID: gatewayTestID, TypeOfMP: "E17"},
+				StatusResponse: eloverblik.StatusResponse{Success: true},
+			}}, nil
+		},
+	}
+	server := newGatewayServer(t, apiCustomer, mock, time.Minute)
+
+	status, contentType, body := get(t, server, "/v1/metering-points/"+gatewayTestID+"/details")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "application/json", contentType)
+	var detail eloverblik.MeteringPointDetail
+	assert.NoError(t, json.Unmarshal(body, &detail))
+	assert.Equal(t, "E17", detail.TypeOfMP)
+
+	t.Run("answered from the cache", func(t *testing.T) {
+		calls.Store(0)
+		status, _, _ := get(t, server, "/v1/metering-points/"+gatewayTestID+"/details")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, int32(0), calls.Load())
+	})
+
+	t.Run("a failed item keeps its code", func(t *testing.T) {
+		status, contentType, body := get(t, server, "/v1/metering-points/571313174002485070/details")
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, "application/problem+json", contentType)
+		p := problem(t, body)
+		assert.Equal(t, uint64(20008), p.Code)
+		assert.Equal(t, "Not Found", p.Title)
+		assert.Equal(t, "meteringpoint not found", p.Detail)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		calls.Store(0)
+		get(t, server, "/v1/metering-points/571313174002485070/details")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("an invalid ID is not sent", func(t *testing.T) {
+		calls.Store(0)
+		status, _, body := get(t, server, "/v1/metering-points/12345/details")
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Contains(t, problem(t, body).Detail, "must be 18 digits")
+		assert.Equal(t, int32(0), calls.Load())
+	})
+}
+
+func TestGatewayAPIErrorCarriesTraceID(t *testing.T) {
+	mock := &MockClient{
+		GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+			return nil, &eloverblik.APIError{StatusCode: http.StatusUnauthorized, Title: "Unauthorized", TraceID: "00-9c485a3a3ed458eab22cab724111db63-ed7aa1e057161e52-01"}
+		},
+	}
+	server := newGatewayServer(t, apiCustomer, mock, time.Minute)
+
+	status, _, body := get(t, server, "/v1/metering-points/"+gatewayTestID+"/details")
+	assert.Equal(t, http.StatusBadGateway, status, "the gateway's own token was refused")
+	p := problem(t, body)
+	assert.Equal(t, "00-9c485a3a3ed458eab22cab724111db63-ed7aa1e057161e52-01", p.TraceID)
+	assert.Equal(t, http.StatusUnauthorized, p.UpstreamStatus)
+	assert.Equal(t, "Bad Gateway", p.Title)
+}
+
+// expiringClient is a client whose data access token expires at expiresAt.
+type expiringClient struct {
+	MockClient
+	expiresAt time.Time
+}
+
+func (c *expiringClient) DataAccessTokenClaims() (eloverblik.TokenClaims, error) {
+	return eloverblik.TokenClaims{ExpiresAt: c.expiresAt}, nil
+}
+
+// expiringClients returns a client source whose clients' tokens last a day from when each
+// was built, answering details with the number of the client, and its clock.
+func expiringClients() (*clientSource, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	built := 0
+	build := func() eloverblik.Client {
+		built++
+		generation := fmt.Sprint(built)
+		return &expiringClient{
+			expiresAt: now.Add(24 * time.Hour),
+			MockClient: MockClient{
+				GetMeteringPointDetailsFunc: func(meteringPointIDs []string) ([]eloverblik.MeteringPointDetailsResponse, error) {
+					return []eloverblik.MeteringPointDetailsResponse{{
+						Result:         eloverblik.MeteringPointDetail{MeteringPointID: meteringPointIDs[0], TypeOfMP: generation},
+						StatusResponse: eloverblik.StatusResponse{Success: true},
+					}}, nil
+				},
+			},
+		}
+	}
+	clients := newClientSource(build(), build)
+	clients.now = func() time.Time { return now }
+	return clients, &now
+}
+
+func TestGatewayRenewsExpiredClient(t *testing.T) {
+	clients, now := expiringClients()
+	server := httptest.NewServer(newGateway(apiCustomer, clients, 0, maxGatewayRateLimit*1000).handler())
+	t.Cleanup(server.Close)
+
+	typeOfMP := func() string {
+		status, _, body := get(t, server, "/v1/metering-points/"+gatewayTestID+"/details")
+		assert.Equal(t, http.StatusOK, status)
+		var detail eloverblik.MeteringPointDetail
+		assert.NoError(t, json.Unmarshal(body, &detail))
+		return detail.TypeOfMP
+	}
+
+	assert.Equal(t, "1", typeOfMP())
+	*now = now.Add(23 * time.Hour)
+	assert.Equal(t, "1", typeOfMP(), "the token is still good for an hour")
+	*now = now.Add(time.Hour)
+	assert.Equal(t, "2", typeOfMP(), "the token expired")
+	assert.Equal(t, "2", typeOfMP())
+}
+
+func TestGatewayTimeSeries(t *testing.T) {
+	start := time.Date(2025, 3, 9, 0, 0, 0, 0, eloverblik.Copenhagen)
+	mock := &MockClient{
+		GetTimeSeriesFunc: func(meteringPointIDs []string, from, to time.Time, aggregation eloverblik.Aggregation) ([]eloverblik.TimeSeries, error) {
+			assert.Equal(t, "2025-03-09", from.Format(time.DateOnly))
+			assert.Equal(t, "2025-03-11", to.Format(time.DateOnly))
+			assert.Equal(t, eloverblik.Hour, aggregation)
+			return []eloverblik.TimeSeries{hourlySeries(gatewayTestID, start, 48, 1)}, nil
+		},
+	}
+	server := newGatewayServer(t, apiThirdParty, mock, 0)
+
+	path := "/v1/metering-points/" + gatewayTestID + "/timeseries?from=2025-03-09&to=2025-03-11"
+
+	status, _, body := get(t, server, path)
+	assert.Equal(t, http.StatusOK, status)
+	var hours gatewayTimeSeries
+	assert.NoError(t, json.Unmarshal(body, &hours))
+	assert.Equal(t, eloverblik.Hour, hours.Aggregation)
+	assert.Len(t, hours.Points, 48)
+
+	status, _, body = get(t, server, path+"&resample=Day")
+	assert.Equal(t, http.StatusOK, status)
+	var days gatewayTimeSeries
+	assert.NoError(t, json.Unmarshal(body, &days))
+	assert.Equal(t, eloverblik.Day, days.Aggregation)
+	if assert.Len(t, days.Points, 2) {
+		assert.Equal(t, 24.0, days.Points[0].Measurement)
+	}
+
+	for query, detail := range map[string]string{
+		"?to=2025-03-11":                               "'from' is required",
+		"?from=yesterday":                              "invalid date format",
+		"?from=2025-03-09&aggregation=Bi":              "invalid aggregation 'Bi'",
+		"?from=2025-03-09&to=2025-03-11&resample=Week": "cannot resample to aggregation 'Week'",
+	} {
+		status, _, body := get(t, server, "/v1/metering-points/"+gatewayTestID+"/timeseries"+query)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+		assert.Contains(t, problem(t, body).Detail, detail, query)
+	}
+}
+
+func TestGatewayInstallations(t *testing.T) {
+	customer := &metricsCustomerClient{points: []eloverblik.MeteringPoints{{MeteringPointID: gatewayTestID}}}
+
+	status, _, body := get(t, newGatewayServer(t, apiCustomer, customer, 0), "/v1/installations?includeAll=true")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `"`+gatewayTestID+`"`, string(mustField(t, body, 0, "meteringPointId")))
+
+	status, _, body = get(t, newGatewayServer(t, apiThirdParty, customer, 0), "/v1/installations")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Contains(t, problem(t, body).Detail, "customer token")
+
+	status, _, _ = get(t, newGatewayServer(t, apiCustomer, customer, 0), "/v1/unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+// mustField returns a field of an item of a JSON array.
+func mustField(t *testing.T, body []byte, i int, field string) json.RawMessage {
+	t.Helper()
+
+	var items []map[string]json.RawMessage
+	if !assert.NoError(t, json.Unmarshal(body, &items)) || !assert.Greater(t, len(items), i) {
+		return nil
+	}
+	return items[i][field]
+}
+
+func TestResponseCacheSharesCallsInFlight(t *testing.T) {
+	cache := newResponseCache(0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func() cachedResponse {
+		calls.Add(1)
+		<-release
+		return cachedResponse{status: http.StatusOK, body: []byte("{}")}
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			assert.Equal(t, http.StatusOK, cache.do("key", fetch).status)
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Empty(t, cache.entries, "a zero TTL keeps nothing")
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	now := time.Now()
+	cache := newResponseCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	fetch := func() cachedResponse {
+		calls++
+		return cachedResponse{status: http.StatusOK}
+	}
+
+	cache.do("key", fetch)
+	cache.do("key", fetch)
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+	cache.do("key", fetch)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRateLimiterSpacesCalls(t *testing.T) {
+	limiter := &rateLimiter{interval: 10 * time.Millisecond, maxQueue: 4}
+
+	start := time.Now()
+	for range 4 {
+		assert.NoError(t, limiter.wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
+
+func TestRateLimiterQueue(t *testing.T) {
+	limiter := &rateLimiter{interval: time.Hour, maxQueue: 2}
+	assert.NoError(t, limiter.wait(context.Background()), "the first slot is now")
+
+	// The next call waits an hour, until its request ends
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() { waited <- limiter.wait(ctx) }()
+	assert.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		return limiter.waiting == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	err := <-waited
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, http.StatusServiceUnavailable, problemResponse(err).status)
+
+	limiter.mu.Lock()
+	assert.Equal(t, 0, limiter.waiting, "the cancelled call left the queue")
+	limiter.mu.Unlock()
+
+	t.Run("a full queue turns calls away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		for range 2 {
+			go func() { _ = limiter.wait(ctx) }()
+		}
+		assert.Eventually(t, func() bool {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			return limiter.waiting == 2
+		}, time.Second, time.Millisecond)
+
+		err := limiter.wait(context.Background())
+		response := problemResponse(err)
+		assert.Equal(t, http.StatusTooManyRequests, response.status)
+		assert.True(t, response.problem)
+		assert.Contains(t, string(response.body), `"detail":"2 requests are already waiting for the rate limit"`)
+	})
+}
+
+func TestServeFlags(t *testing.T) {
+	clientInstance = &MockClient{}
+	defer func() { clientInstance = nil }()
+
+	_, err := execute(t, "customer", "serve", "--rate-limit", "200", "--token", "dummy")
+	assert.ErrorContains(t, err, "--rate-limit must be between 1 and 120")
+
+	_, err = execute(t, "thirdparty", "serve", "--cache-ttl", "-1s", "--token", "dummy")
+	assert.ErrorContains(t, err, "--cache-ttl cannot be negative")
+}
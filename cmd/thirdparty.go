@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/slimcdk/go-eloverblik/v1"
@@ -15,14 +14,14 @@ var thirdpartyCmd = &cobra.Command{
 		if clientInstance != nil {
 			return nil
 		}
-		token, err := cmd.Root().PersistentFlags().GetString("token")
+		token, p, err := setupClient(cmd, apiThirdParty)
 		if err != nil {
 			return err
 		}
-		if token == "" {
-			return fmt.Errorf("required flag \"token\" not set")
+		buildClient = func() eloverblik.Client {
+			return eloverblik.NewThirdParty(token, clientOptions(cmd, p)...)
 		}
-		clientInstance = eloverblik.NewThirdParty(token, clientOptions(cmd)...)
+		clientInstance = buildClient()
 		return nil
 	},
 }
@@ -43,10 +42,7 @@ var meteringPointsForScopeCmd = &cobra.Command{
 
 		points, err := thirdpartyAPI.GetMeteringPointsForScope(eloverblik.AuthorizationScope(scope), identifier)
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(points)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeJSON(output, points))
 	},
 }
 
@@ -66,10 +62,7 @@ var meteringPointIDsForScopeCmd = &cobra.Command{
 
 		ids, err := thirdpartyAPI.GetMeteringPointIDsForScope(eloverblik.AuthorizationScope(scope), identifier)
 		cobra.CheckErr(err)
-		bytes, err := json.Marshal(ids)
-		cobra.CheckErr(err)
-		_, err = output.Write(bytes)
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeJSON(output, ids))
 	},
 }
 
@@ -1,8 +1,9 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
 	eloverblik "github.com/slimcdk/go-eloverblik/v1"
 	"github.com/spf13/cobra"
@@ -11,19 +12,22 @@ import (
 var tokenCmd = &cobra.Command{
 	Use:   "token",
 	Short: "Show what the Eloverblik token says about itself",
-	Long: `Decode the claims of the token given with --token: which API and roles it was issued
+	Long: `Decode the claims of the refresh token the CLI runs with: which API and roles it was issued
 for, who owns it, its name in the Eloverblik portal and when it expires.
 
 The claims are decoded, not verified, and no request is made to Eloverblik. Use
 --data-access to exchange the refresh token for a data access token and decode that one
-instead, which does make a request.`,
+instead, which does make a request.
+
+A warning is printed to stderr when the refresh token expires within 30 days.`,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		token, err := cmd.Root().PersistentFlags().GetString("token")
+		name, p, err := activeProfile(cmd)
 		if err != nil {
 			return err
 		}
-		if token == "" {
-			return fmt.Errorf("required flag \"token\" not set")
+		token, _, err := resolveToken(cmd, name, p)
+		if err != nil {
+			return err
 		}
 
 		dataAccess, _ := cmd.Flags().GetBool("data-access")
@@ -32,6 +36,7 @@ instead, which does make a request.`,
 		if err != nil {
 			return err
 		}
+		warnTokenExpiry(messageOutput, claims)
 
 		if dataAccess {
 			// The token itself says which API it belongs to, so the right client can be
@@ -41,9 +46,9 @@ instead, which does make a request.`,
 				return err
 			}
 
-			client := eloverblik.NewCustomer(token, clientOptions(cmd)...).(eloverblik.Client)
+			client := eloverblik.NewCustomer(token, clientOptions(cmd, p)...).(eloverblik.Client)
 			if apiType == eloverblik.ThirdPartyApi {
-				client = eloverblik.NewThirdParty(token, clientOptions(cmd)...)
+				client = eloverblik.NewThirdParty(token, clientOptions(cmd, p)...)
 			}
 
 			if claims, err = client.DataAccessTokenClaims(); err != nil {
@@ -51,16 +56,115 @@ instead, which does make a request.`,
 			}
 		}
 
-		bytes, err := json.Marshal(claims)
-		if err != nil {
+		return writeJSON(output, claims)
+	},
+}
+
+// refreshTokenClaims decodes the claims of the refresh token the CLI runs with.
+func refreshTokenClaims(cmd *cobra.Command) (eloverblik.TokenClaims, error) {
+	name, p, err := activeProfile(cmd)
+	if err != nil {
+		return eloverblik.TokenClaims{}, err
+	}
+	token, _, err := resolveToken(cmd, name, p)
+	if err != nil {
+		return eloverblik.TokenClaims{}, err
+	}
+	return eloverblik.ParseToken(token)
+}
+
+// Exit codes of a Nagios plugin, as read by Nagios, Icinga, Sensu and their kin.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// checkStatus names the exit codes in a plugin's status line.
+var checkStatus = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// exit ends the process with a status code (configurable for testing).
+var exit = os.Exit
+
+// checkTokenExpiry grades the remaining lifetime of a refresh token against the warning
+// and critical thresholds, and describes it in a plugin status line with performance data.
+func checkTokenExpiry(claims eloverblik.TokenClaims, warningDays, criticalDays int) (int, string) {
+	if claims.ExpiresAt.IsZero() {
+		return checkUnknown, "TOKEN UNKNOWN - the token carries no expiry"
+	}
+
+	name := "the refresh token"
+	if claims.TokenName != "" {
+		name = fmt.Sprintf("refresh token '%s'", claims.TokenName)
+	}
+
+	days := int(claims.ExpiresIn().Hours() / 24)
+	perfdata := fmt.Sprintf("days_left=%d;%d;%d;0", days, warningDays, criticalDays)
+	expiry := claims.ExpiresAt.Format(time.DateOnly)
+
+	code := checkOK
+	switch {
+	case claims.IsExpired() || days < criticalDays:
+		code = checkCritical
+	case days < warningDays:
+		code = checkWarning
+	}
+
+	if claims.IsExpired() {
+		return code, fmt.Sprintf("TOKEN %s - %s expired on %s | %s", checkStatus[code], name, expiry, perfdata)
+	}
+	return code, fmt.Sprintf("TOKEN %s - %s expires in %d days, on %s | %s", checkStatus[code], name, days, expiry, perfdata)
+}
+
+var tokenCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the refresh token's expiry, as a Nagios plugin",
+	Long: `Check how long the refresh token has left, for a monitoring system. It prints a single
+status line with performance data, and exits with the code of a Nagios plugin:
+
+  0  OK        the token expires in --warning days or more
+  1  WARNING   the token expires within --warning days
+  2  CRITICAL  the token expires within --critical days, or has expired
+  3  UNKNOWN   the token could not be found or read
+
+No request is made to Eloverblik.
+
+  go-eloverblik token check --profile pipeline -w 30 -c 7
+  TOKEN WARNING - refresh token 'pipeline' expires in 12 days, on 2027-01-15 | days_left=12;30;7;0`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		warningDays, _ := cmd.Flags().GetInt("warning")
+		criticalDays, _ := cmd.Flags().GetInt("critical")
+
+		code, line := checkUnknown, ""
+		if criticalDays > warningDays {
+			line = fmt.Sprintf("TOKEN UNKNOWN - --critical (%d days) must not exceed --warning (%d days)", criticalDays, warningDays)
+		} else if claims, err := refreshTokenClaims(cmd); err != nil {
+			line = "TOKEN UNKNOWN - " + err.Error()
+		} else {
+			code, line = checkTokenExpiry(claims, warningDays, criticalDays)
+		}
+
+		if _, err := fmt.Fprintln(output, line); err != nil {
 			return err
 		}
-		_, err = output.Write(bytes)
-		return err
+		if code != checkOK {
+			exit(code)
+		}
+		return nil
 	},
 }
 
 func init() {
 	tokenCmd.Flags().Bool("data-access", false, "Exchange the refresh token for a data access token and decode that instead")
+	tokenCheckCmd.Flags().IntP("warning", "w", 30, "days left below which the token is WARNING")
+	tokenCheckCmd.Flags().IntP("critical", "c", 7, "days left below which the token is CRITICAL")
+	tokenCmd.AddCommand(tokenCheckCmd)
 	rootCmd.AddCommand(tokenCmd)
 }
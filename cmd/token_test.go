@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	eloverblik "github.com/slimcdk/go-eloverblik/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTokenExpiry(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expiresIn time.Duration
+		code      int
+		line      string
+	}{
+		"ok":       {expiresIn: 100*24*time.Hour + time.Hour, code: checkOK, line: "TOKEN OK - the refresh token expires in 100 days"},
+		"warning":  {expiresIn: 20*24*time.Hour + time.Hour, code: checkWarning, line: "TOKEN WARNING - the refresh token expires in 20 days"},
+		"critical": {expiresIn: 3*24*time.Hour + time.Hour, code: checkCritical, line: "TOKEN CRITICAL - the refresh token expires in 3 days"},
+		"expired":  {expiresIn: -time.Hour, code: checkCritical, line: "TOKEN CRITICAL - the refresh token expired on"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			claims, err := eloverblik.ParseToken(loginTestToken(t, "CUSTOMERAPI_Refresh", tc.expiresIn))
+			assert.NoError(t, err)
+
+			code, line := checkTokenExpiry(claims, 30, 7)
+			assert.Equal(t, tc.code, code)
+			assert.Contains(t, line, tc.line)
+		})
+	}
+
+	t.Run("performance data carries the thresholds", func(t *testing.T) {
+		claims, _ := eloverblik.ParseToken(loginTestToken(t, "CUSTOMERAPI_Refresh", 20*24*time.Hour+time.Hour))
+		_, line := checkTokenExpiry(claims, 30, 7)
+		assert.Contains(t, line, "| days_left=20;30;7;0")
+	})
+
+	t.Run("a token without expiry is unknown", func(t *testing.T) {
+		code, _ := checkTokenExpiry(eloverblik.TokenClaims{TokenType: "CUSTOMERAPI_Refresh"}, 30, 7)
+		assert.Equal(t, checkUnknown, code)
+	})
+}
+
+func TestTokenCheckCmd(t *testing.T) {
+	useTempConfig(t)
+
+	oldOutput := output
+	var buf bytes.Buffer
+	output = &buf
+	defer func() { output = oldOutput }()
+
+	var exitCode int
+	oldExit := exit
+	exit = func(code int) { exitCode = code }
+	defer func() { exit = oldExit }()
+
+	t.Run("exits with the plugin code", func(t *testing.T) {
+		buf.Reset()
+		exitCode = checkOK
+		token := loginTestToken(t, "CUSTOMERAPI_Refresh", 20*24*time.Hour+time.Hour)
+		_, err := execute(t, "token", "check", "--token", token)
+		assert.NoError(t, err)
+		assert.Equal(t, checkWarning, exitCode)
+		assert.Contains(t, buf.String(), "TOKEN WARNING")
+
+		exitCode = checkOK
+		_, err = execute(t, "token", "check", "--token", token, "-w", "14")
+		assert.NoError(t, err)
+		assert.Equal(t, checkOK, exitCode, "20 days left is fine with a 14 day warning")
+	})
+
+	t.Run("a missing token is unknown", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "token", "check")
+		assert.NoError(t, err)
+		assert.Equal(t, checkUnknown, exitCode)
+		assert.Contains(t, buf.String(), "TOKEN UNKNOWN - no refresh token")
+	})
+
+	t.Run("thresholds the wrong way round are unknown", func(t *testing.T) {
+		buf.Reset()
+		_, err := execute(t, "token", "check", "--token", "x", "-w", "7", "-c", "30")
+		assert.NoError(t, err)
+		assert.Equal(t, checkUnknown, exitCode)
+	})
+}
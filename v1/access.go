@@ -0,0 +1,192 @@
+package eloverblik
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AccessWindow is the half-open interval [From, To) in which a third party may read the
+// data of a metering point. A zero From or To leaves that side open.
+type AccessWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// AccessWindow returns the period the authorization is valid in. The API sends validFrom
+// and validTo as dates, and the authorization is valid through the day of validTo.
+func (a Authorization) AccessWindow() (AccessWindow, error) {
+	return parseAccessWindow(a.ValidFrom, a.ValidTo)
+}
+
+// AccessWindow returns the period the third party has access to the metering point in,
+// from its accessFrom and accessTo.
+func (p ThirdPartyMeteringPoint) AccessWindow() (AccessWindow, error) {
+	return parseAccessWindow(p.AccessFrom, p.AccessTo)
+}
+
+// Intersect returns the part of the window that lies within other as well, e.g. the
+// access to a metering point within the validity of its authorization. The result is
+// empty, see Empty, when the two do not overlap.
+func (w AccessWindow) Intersect(other AccessWindow) AccessWindow {
+	if other.From.After(w.From) {
+		w.From = other.From
+	}
+	if !other.To.IsZero() && (w.To.IsZero() || other.To.Before(w.To)) {
+		w.To = other.To
+	}
+	return w
+}
+
+// Empty reports whether the window holds no time at all.
+func (w AccessWindow) Empty() bool {
+	return !w.To.IsZero() && !w.From.Before(w.To)
+}
+
+// Clip narrows [from, to) to the window, in whole Danish days: the API is asked for dates,
+// so a window starting within a day starts at the next midnight, and one ending within a
+// day ends at the midnight before. ok is false when no whole day is left.
+func (w AccessWindow) Clip(from, to time.Time) (clippedFrom, clippedTo time.Time, ok bool) {
+	clippedFrom, clippedTo = from, to
+	if !w.From.IsZero() && w.From.After(clippedFrom) {
+		clippedFrom = ceilDay(w.From)
+	}
+	if !w.To.IsZero() && w.To.Before(clippedTo) {
+		clippedTo = floorDay(w.To)
+	}
+	return clippedFrom, clippedTo, clippedFrom.Before(clippedTo)
+}
+
+// AccessClip reports how GetTimeSeriesWithinAccess changed the interval it requested for
+// a metering point. From and To are the interval it was requested for instead, and are
+// zero when Skipped: the metering point's window does not overlap the interval at all.
+type AccessClip struct {
+	MeteringPointID string    `json:"meteringPointId"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Skipped         bool      `json:"skipped"`
+}
+
+// GetTimeSeriesWithinAccess fetches the time series of the metering points in windows,
+// each for the part of [from, to) its access window allows, instead of letting the API
+// reject or cut short a request beyond it. Metering points whose window does not overlap
+// [from, to) are not requested. Every metering point requested for less than [from, to),
+// or skipped, is reported in the returned clips.
+//
+// The windows usually come from ThirdPartyMeteringPoint.AccessWindow, intersected with
+// the Authorization.AccessWindow of the authorization that granted it. Metering points
+// sharing a clipped interval are requested together, in batches of
+// MaxMeteringPointsPerRequest.
+//
+// A failed batch does not stop the others: its error is returned joined with any others,
+// next to the time series that were fetched.
+//
+// Example:
+//
+//	points, _ := client.GetMeteringPointsForScope(eloverblik.AuthScopeID, authorization.ID)
+//	valid, _ := authorization.AccessWindow()
+//	windows := make(map[string]eloverblik.AccessWindow)
+//	for _, point := range points {
+//		window, _ := point.AccessWindow()
+//		windows[point.MeteringPointID] = window.Intersect(valid)
+//	}
+//	series, clips, err := eloverblik.GetTimeSeriesWithinAccess(client, windows, from, to, eloverblik.Hour)
+func GetTimeSeriesWithinAccess(client Client, windows map[string]AccessWindow, from, to time.Time, aggregation Aggregation) ([]TimeSeries, []AccessClip, error) {
+
+	type interval struct{ from, to time.Time }
+
+	var clips []AccessClip
+	var intervals []interval
+	groups := make(map[interval][]string)
+
+	for _, id := range slices.Sorted(maps.Keys(windows)) {
+		clippedFrom, clippedTo, ok := windows[id].Clip(from, to)
+		if !ok {
+			clips = append(clips, AccessClip{MeteringPointID: id, Skipped: true})
+			continue
+		}
+		if !clippedFrom.Equal(from) || !clippedTo.Equal(to) {
+			clips = append(clips, AccessClip{MeteringPointID: id, From: clippedFrom, To: clippedTo})
+		}
+
+		// In cph, so that equal intervals make equal keys
+		key := interval{clippedFrom.In(cph), clippedTo.In(cph)}
+		if _, ok := groups[key]; !ok {
+			intervals = append(intervals, key)
+		}
+		groups[key] = append(groups[key], id)
+	}
+
+	var series []TimeSeries
+	var errs []error
+	for _, key := range intervals {
+		for batch := range slices.Chunk(groups[key], MaxMeteringPointsPerRequest) {
+			result, err := client.GetTimeSeries(batch, key.from, key.to, aggregation)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("metering points %s: %w", strings.Join(batch, ","), err))
+			}
+			series = append(series, result...)
+		}
+	}
+
+	return series, clips, errors.Join(errs...)
+}
+
+// parseAccessWindow reads the bounds of an access window. They come as an RFC 3339 time
+// ("2024-10-31T23:00:00.000Z") or as a date ("2024-12-31"), which is a Danish day and,
+// as the end of the window, includes that day. An empty bound, or one in year 9999, is
+// open.
+func parseAccessWindow(from, to string) (AccessWindow, error) {
+	var w AccessWindow
+	var err error
+
+	if w.From, err = parseAccessBound(from, false); err != nil {
+		return AccessWindow{}, fmt.Errorf("invalid access start '%s': %w", from, err)
+	}
+	if w.To, err = parseAccessBound(to, true); err != nil {
+		return AccessWindow{}, fmt.Errorf("invalid access end '%s': %w", to, err)
+	}
+	return w, nil
+}
+
+func parseAccessBound(value string, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		date, dateErr := time.ParseInLocation(time.DateOnly, value, cph)
+		if dateErr != nil {
+			return time.Time{}, err
+		}
+		t = date
+		if end {
+			t = date.AddDate(0, 0, 1)
+		}
+	}
+
+	if t.Year() >= 9999 {
+		return time.Time{}, nil
+	}
+	return t.In(cph), nil
+}
+
+// floorDay returns the Danish midnight starting the day of t.
+func floorDay(t time.Time) time.Time {
+	year, month, day := t.In(cph).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, cph)
+}
+
+// ceilDay returns t when it is a Danish midnight, and the next one otherwise.
+func ceilDay(t time.Time) time.Time {
+	day := floorDay(t)
+	if day.Equal(t) {
+		return day
+	}
+	return day.AddDate(0, 0, 1)
+}
@@ -0,0 +1,127 @@
+package eloverblik
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessWindow(t *testing.T) {
+	t.Run("authorization dates include the last day", func(t *testing.T) {
+		w, err := Authorization{ValidFrom: "2024-01-01", ValidTo: "2024-12-31"}.AccessWindow()
+		assert.NoError(t, err)
+		assert.True(t, w.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, cph)))
+		assert.True(t, w.To.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, cph)))
+	})
+
+	t.Run("metering point access is RFC 3339", func(t *testing.T) {
+		w, err := ThirdPartyMeteringPoint{AccessFrom: "2024-10-31T23:00:00.000Z", AccessTo: "2026-07-31T22:00:00.000Z"}.AccessWindow()
+		assert.NoError(t, err)
+		assert.True(t, w.From.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, cph)))
+		assert.True(t, w.To.Equal(time.Date(2026, 8, 1, 0, 0, 0, 0, cph)))
+	})
+
+	t.Run("open ends", func(t *testing.T) {
+		w, err := ThirdPartyMeteringPoint{AccessTo: "9999-12-31T23:00:00.000Z"}.AccessWindow()
+		assert.NoError(t, err)
+		assert.Equal(t, AccessWindow{}, w)
+	})
+
+	t.Run("invalid bounds", func(t *testing.T) {
+		_, err := Authorization{ValidFrom: "tomorrow"}.AccessWindow()
+		assert.ErrorContains(t, err, "invalid access start 'tomorrow'")
+	})
+}
+
+func TestAccessWindowIntersect(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	feb := jan.AddDate(0, 1, 0)
+	mar := jan.AddDate(0, 2, 0)
+
+	assert.Equal(t, AccessWindow{From: feb, To: mar}, AccessWindow{From: jan, To: mar}.Intersect(AccessWindow{From: feb}))
+	assert.Equal(t, AccessWindow{From: jan, To: feb}, AccessWindow{From: jan}.Intersect(AccessWindow{To: feb}))
+	assert.True(t, AccessWindow{From: jan, To: feb}.Intersect(AccessWindow{From: feb, To: mar}).Empty())
+	assert.False(t, AccessWindow{}.Empty(), "an open window holds everything")
+}
+
+func TestAccessWindowClip(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	mar := jan.AddDate(0, 2, 0)
+
+	t.Run("whole days only", func(t *testing.T) {
+		w := AccessWindow{From: time.Date(2024, 1, 10, 14, 0, 0, 0, cph), To: time.Date(2024, 2, 20, 9, 0, 0, 0, cph)}
+		from, to, ok := w.Clip(jan, mar)
+		assert.True(t, ok)
+		assert.True(t, from.Equal(time.Date(2024, 1, 11, 0, 0, 0, 0, cph)), "a partial first day is left out")
+		assert.True(t, to.Equal(time.Date(2024, 2, 20, 0, 0, 0, 0, cph)), "a partial last day is left out")
+	})
+
+	t.Run("a wider window changes nothing", func(t *testing.T) {
+		from, to, ok := AccessWindow{}.Clip(jan, mar)
+		assert.True(t, ok)
+		assert.Equal(t, jan, from)
+		assert.Equal(t, mar, to)
+	})
+
+	t.Run("no overlap", func(t *testing.T) {
+		_, _, ok := AccessWindow{From: mar}.Clip(jan, mar)
+		assert.False(t, ok)
+
+		_, _, ok = AccessWindow{From: time.Date(2024, 2, 29, 12, 0, 0, 0, cph)}.Clip(jan, mar)
+		assert.False(t, ok, "less than a whole day is left")
+	})
+}
+
+// timeSeriesRecorder answers GetTimeSeries with an empty series per metering point, and
+// records the requests it got.
+type timeSeriesRecorder struct {
+	Client
+	requests []timeSeriesRequest
+}
+
+type timeSeriesRequest struct {
+	ids      []string
+	from, to time.Time
+}
+
+func (r *timeSeriesRecorder) GetTimeSeries(ids []string, from, to time.Time, aggregation Aggregation) ([]TimeSeries, error) {
+	r.requests = append(r.requests, timeSeriesRequest{ids, from, to})
+
+	series := make([]TimeSeries, len(ids))
+	for i, id := range ids {
+		series[i].ID = id
+	}
+	return series, nil
+}
+
+func TestGetTimeSeriesWithinAccess(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	feb := jan.AddDate(0, 1, 0)
+	mar := jan.AddDate(0, 2, 0)
+
+	windows := map[string]AccessWindow{
+		"571313180100000001": {},
+		"571313180100000002": {From: feb},
+		"571313180100000003": {From: feb.UTC()}, // the same instant in another location
+		"571313180100000004": {To: jan},
+	}
+
+	client := &timeSeriesRecorder{}
+	series, clips, err := GetTimeSeriesWithinAccess(client, windows, jan, mar, Hour)
+	assert.NoError(t, err)
+	assert.Len(t, series, 3)
+
+	if assert.Len(t, client.requests, 2, "metering points sharing an interval share a request") {
+		assert.Equal(t, []string{"571313180100000001"}, client.requests[0].ids)
+		assert.Equal(t, []string{"571313180100000002", "571313180100000003"}, client.requests[1].ids)
+		assert.True(t, client.requests[1].from.Equal(feb))
+		assert.True(t, client.requests[1].to.Equal(mar))
+	}
+
+	if assert.Len(t, clips, 3) {
+		assert.Equal(t, "571313180100000002", clips[0].MeteringPointID)
+		assert.True(t, clips[0].From.Equal(feb))
+		assert.Equal(t, AccessClip{MeteringPointID: "571313180100000004", Skipped: true}, clips[2])
+	}
+}
@@ -50,8 +50,9 @@ type ThirdPartyMeteringPoint struct {
 	ChildMeteringPoints     []ChildMeteringPoint `json:"childMeteringPoints"`
 }
 
-// Fetches and sets a access token on the base client
-func (c *client) authenticate() error {
+// authenticate fetches a data access token with the refresh token. It does not store it:
+// GetDataAccessToken does, so that concurrent callers share a single request.
+func (c *client) authenticate() (string, error) {
 
 	// Response struct
 	var result struct {
@@ -69,30 +70,69 @@ func (c *client) authenticate() error {
 	// Execute request
 	res, err := req.Get("/token")
 	if err != nil {
-		return err
+		return "", err
 	}
 	if err = apiErrorFromBody(apiErrBody, res.StatusCode()); err != nil {
-		return err
+		return "", err
 	}
 
 	// A response without a token leaves the client unauthenticated, which would make
 	// every following call fail with a confusing 401 instead of the real cause
 	if result.AccessToken == "" {
-		return ErrorErrorCreatingToken
+		return "", ErrorErrorCreatingToken
 	}
 
-	// Set access token on client
-	c.accessToken = result.AccessToken
-	return nil
+	return result.AccessToken, nil
 }
 
+// tokenRequest is a request to /token in flight. Callers that need a data access token
+// while it runs wait for done and share its outcome.
+type tokenRequest struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// GetDataAccessToken returns the client's data access token, fetching one with the
+// refresh token first if the client does not hold one yet.
+//
+// It is safe for concurrent use. The API allows two calls to /token per minute per IP, so
+// goroutines asking while a token is being fetched wait for that request and share its
+// outcome, error included, rather than each making their own.
 func (c *client) GetDataAccessToken() (string, error) {
-	if c.accessToken == "" {
-		if err := c.authenticate(); err != nil {
-			return c.accessToken, err
-		}
+
+	// Every call needs a token, which makes it the place to notice the refresh token
+	// running out
+	c.checkTokenExpiry()
+
+	c.tokenMu.Lock()
+	if c.accessToken != "" {
+		token := c.accessToken
+		c.tokenMu.Unlock()
+		return token, nil
+	}
+	if req := c.tokenRequest; req != nil {
+		c.tokenMu.Unlock()
+		<-req.done
+		return req.token, req.err
 	}
-	return c.accessToken, nil
+	req := &tokenRequest{done: make(chan struct{})}
+	c.tokenRequest = req
+	c.tokenMu.Unlock()
+
+	// The lock is not held during the request, so a caller that finds the token cached
+	// is never held up by one
+	req.token, req.err = c.authenticate()
+
+	c.tokenMu.Lock()
+	if req.err == nil {
+		c.accessToken = req.token
+	}
+	c.tokenRequest = nil
+	c.tokenMu.Unlock()
+	close(req.done)
+
+	return req.token, req.err
 }
 
 func (c *client) GetAuthorizations() ([]Authorization, error) {
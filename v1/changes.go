@@ -0,0 +1,177 @@
+package eloverblik
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// AuthorizationSnapshot is the state of a third party's authorizations at a point in
+// time. It marshals to JSON, so it can be persisted and compared with a later one by
+// DiffAuthorizations.
+type AuthorizationSnapshot struct {
+	TakenAt        time.Time       `json:"takenAt"`
+	Authorizations []Authorization `json:"authorizations"`
+	// MeteringPoints are the IDs of the metering points each authorization covers, keyed
+	// by authorization ID.
+	MeteringPoints map[string][]string `json:"meteringPoints"`
+}
+
+// TakeAuthorizationSnapshot lists the authorizations of a third party and the metering
+// points each covers. Unlike LoadPortfolio it fails when any authorization cannot be
+// resolved, as a snapshot missing its metering points would report them all as removed.
+func TakeAuthorizationSnapshot(client ThirdParty) (AuthorizationSnapshot, error) {
+	takenAt := time.Now()
+
+	portfolio, err := LoadPortfolio(client, 0)
+	if err != nil {
+		return AuthorizationSnapshot{}, err
+	}
+
+	return AuthorizationSnapshot{
+		TakenAt:        takenAt,
+		Authorizations: portfolio.Authorizations,
+		MeteringPoints: portfolio.MeteringPoints,
+	}, nil
+}
+
+// AuthorizationChangeKind is the kind of an AuthorizationChange.
+type AuthorizationChangeKind string
+
+const (
+	// AuthorizationNew is an authorization that was granted.
+	AuthorizationNew AuthorizationChangeKind = "new"
+	// AuthorizationRevoked is an authorization that is gone before it expired.
+	AuthorizationRevoked AuthorizationChangeKind = "revoked"
+	// AuthorizationExpired is an authorization that is gone after it expired.
+	AuthorizationExpired AuthorizationChangeKind = "expired"
+	// AuthorizationExpiring is an authorization that expires soon.
+	AuthorizationExpiring AuthorizationChangeKind = "expiring"
+	// AuthorizationExtended is an authorization whose validTo moved later.
+	AuthorizationExtended AuthorizationChangeKind = "extended"
+	// MeteringPointAdded is a metering point an authorization covers that it did not,
+	// e.g. a new one of a customer whose authorization includes future metering points.
+	MeteringPointAdded AuthorizationChangeKind = "meteringPointAdded"
+	// MeteringPointRemoved is a metering point an authorization no longer covers.
+	MeteringPointRemoved AuthorizationChangeKind = "meteringPointRemoved"
+)
+
+// AuthorizationChange is a difference between two snapshots of a third party's
+// authorizations.
+//
+// MeteringPointID is only set for MeteringPointAdded and MeteringPointRemoved.
+// PreviousValidTo is only set for AuthorizationExtended. ValidTo is the authorization's
+// validTo in the later snapshot, or in the earlier one for an authorization that is gone.
+type AuthorizationChange struct {
+	Kind            AuthorizationChangeKind `json:"kind"`
+	AuthorizationID string                  `json:"authorizationId"`
+	CustomerName    string                  `json:"customerName"`
+	CustomerCVR     string                  `json:"customerCVR"`
+	MeteringPointID string                  `json:"meteringPointId,omitempty"`
+	ValidTo         string                  `json:"validTo"`
+	PreviousValidTo string                  `json:"previousValidTo,omitempty"`
+}
+
+// DiffAuthorizations reports what changed between two snapshots of a third party's
+// authorizations: authorizations that are new, revoked, expired or extended, and the
+// metering points that were added to or removed from an authorization both snapshots
+// have. The metering points of a new or gone authorization are not listed one by one.
+//
+// Expiring is a state rather than a change: every authorization of the later snapshot
+// whose validity ends within expiringWithin of its TakenAt is reported, whether it was
+// reported before or not. Zero or less reports none.
+//
+// The changes are ordered by authorization ID and then by kind and metering point, so two
+// diffs of the same snapshots are equal.
+func DiffAuthorizations(before, after AuthorizationSnapshot, expiringWithin time.Duration) []AuthorizationChange {
+	previous := make(map[string]Authorization, len(before.Authorizations))
+	for _, authorization := range before.Authorizations {
+		previous[authorization.ID] = authorization
+	}
+	current := make(map[string]Authorization, len(after.Authorizations))
+	for _, authorization := range after.Authorizations {
+		current[authorization.ID] = authorization
+	}
+
+	var changes []AuthorizationChange
+	change := func(kind AuthorizationChangeKind, authorization Authorization) AuthorizationChange {
+		return AuthorizationChange{
+			Kind:            kind,
+			AuthorizationID: authorization.ID,
+			CustomerName:    authorization.CustomerName,
+			CustomerCVR:     authorization.CustomerCVR,
+			ValidTo:         authorization.ValidTo,
+		}
+	}
+
+	for _, authorization := range before.Authorizations {
+		if _, ok := current[authorization.ID]; ok {
+			continue
+		}
+		kind := AuthorizationRevoked
+		if window, err := authorization.AccessWindow(); err == nil && !window.To.IsZero() && !window.To.After(after.TakenAt) {
+			kind = AuthorizationExpired
+		}
+		changes = append(changes, change(kind, authorization))
+	}
+
+	for _, authorization := range after.Authorizations {
+		window, windowErr := authorization.AccessWindow()
+
+		old, existed := previous[authorization.ID]
+		if !existed {
+			changes = append(changes, change(AuthorizationNew, authorization))
+		} else {
+			if oldWindow, err := old.AccessWindow(); err == nil && windowErr == nil && extends(oldWindow.To, window.To) {
+				extended := change(AuthorizationExtended, authorization)
+				extended.PreviousValidTo = old.ValidTo
+				changes = append(changes, extended)
+			}
+
+			for _, id := range addedIDs(before.MeteringPoints[authorization.ID], after.MeteringPoints[authorization.ID]) {
+				point := change(MeteringPointAdded, authorization)
+				point.MeteringPointID = id
+				changes = append(changes, point)
+			}
+			for _, id := range addedIDs(after.MeteringPoints[authorization.ID], before.MeteringPoints[authorization.ID]) {
+				point := change(MeteringPointRemoved, authorization)
+				point.MeteringPointID = id
+				changes = append(changes, point)
+			}
+		}
+
+		if expiringWithin > 0 && windowErr == nil && !window.To.IsZero() && window.To.Before(after.TakenAt.Add(expiringWithin)) {
+			changes = append(changes, change(AuthorizationExpiring, authorization))
+		}
+	}
+
+	slices.SortStableFunc(changes, func(a, b AuthorizationChange) int {
+		return cmp.Or(
+			cmp.Compare(a.AuthorizationID, b.AuthorizationID),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.MeteringPointID, b.MeteringPointID),
+		)
+	})
+	return changes
+}
+
+// extends reports whether the end of a validity moved later. A zero end is open, and
+// later than any other.
+func extends(before, after time.Time) bool {
+	if before.IsZero() {
+		return false
+	}
+	return after.IsZero() || after.After(before)
+}
+
+// addedIDs returns the IDs in after that are not in before, sorted.
+func addedIDs(before, after []string) []string {
+	var ids []string
+	for _, id := range after {
+		if !slices.Contains(before, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
@@ -0,0 +1,87 @@
+package eloverblik
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffAuthorizations(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, cph)
+
+	before := AuthorizationSnapshot{
+		TakenAt: now.AddDate(0, 0, -7),
+		Authorizations: []Authorization{
+			{ID: "kept", ValidFrom: "2024-01-01", ValidTo: "2024-12-31"},
+			{ID: "extended", ValidFrom: "2024-01-01", ValidTo: "2024-06-30"},
+			{ID: "revoked", ValidFrom: "2024-01-01", ValidTo: "2024-12-31"},
+			{ID: "expired", ValidFrom: "2024-01-01", ValidTo: "2024-05-31"},
+		},
+		MeteringPoints: map[string][]string{
+			"kept":     {"571313180100000001", "571313180100000002"},
+			"extended": {"571313180100000003"},
+		},
+	}
+	after := AuthorizationSnapshot{
+		TakenAt: now,
+		Authorizations: []Authorization{
+			{ID: "kept", ValidFrom: "2024-01-01", ValidTo: "2024-12-31", IncludeFutureMeteringPoints: true},
+			{ID: "extended", ValidFrom: "2024-01-01", ValidTo: "2025-06-30"},
+			{ID: "new", ValidFrom: "2024-06-01", ValidTo: "2024-06-20", CustomerName: "New Customer"},
+		},
+		MeteringPoints: map[string][]string{
+			"kept":     {"571313180100000002", "571313180100000004"},
+			"extended": {"571313180100000003"},
+			"new":      {"571313180100000005"},
+		},
+	}
+
+	changes := DiffAuthorizations(before, after, 30*24*time.Hour)
+	assert.Equal(t, []AuthorizationChange{
+		{Kind: AuthorizationExpired, AuthorizationID: "expired", ValidTo: "2024-05-31"},
+		{Kind: AuthorizationExtended, AuthorizationID: "extended", ValidTo: "2025-06-30", PreviousValidTo: "2024-06-30"},
+		{Kind: MeteringPointAdded, AuthorizationID: "kept", ValidTo: "2024-12-31", MeteringPointID: "571313180100000004"},
+		{Kind: MeteringPointRemoved, AuthorizationID: "kept", ValidTo: "2024-12-31", MeteringPointID: "571313180100000001"},
+		{Kind: AuthorizationExpiring, AuthorizationID: "new", CustomerName: "New Customer", ValidTo: "2024-06-20"},
+		{Kind: AuthorizationNew, AuthorizationID: "new", CustomerName: "New Customer", ValidTo: "2024-06-20"},
+		{Kind: AuthorizationRevoked, AuthorizationID: "revoked", ValidTo: "2024-12-31"},
+	}, changes)
+
+	t.Run("nothing changed", func(t *testing.T) {
+		assert.Empty(t, DiffAuthorizations(after, after, 0))
+	})
+
+	t.Run("an empty snapshot makes everything new", func(t *testing.T) {
+		changes := DiffAuthorizations(AuthorizationSnapshot{}, after, 0)
+		assert.Len(t, changes, 3)
+		for _, change := range changes {
+			assert.Equal(t, AuthorizationNew, change.Kind)
+		}
+	})
+}
+
+func TestTakeAuthorizationSnapshot(t *testing.T) {
+	client := &fakeThirdParty{
+		authorizations: []Authorization{{ID: "a"}, {ID: "b"}},
+		meteringPoints: map[string][]string{"a": {"571313180100000001"}},
+	}
+
+	snapshot, err := TakeAuthorizationSnapshot(client)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), snapshot.TakenAt, time.Minute)
+	assert.Len(t, snapshot.Authorizations, 2)
+	assert.Equal(t, map[string][]string{"a": {"571313180100000001"}, "b": nil}, snapshot.MeteringPoints)
+
+	// A snapshot survives being persisted
+	data, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+	var restored AuthorizationSnapshot
+	assert.NoError(t, json.Unmarshal(data, &restored))
+	assert.Empty(t, DiffAuthorizations(restored, snapshot, 0))
+
+	client.failing = map[string]error{"b": ErrorThirdPartyNotFound}
+	_, err = TakeAuthorizationSnapshot(client)
+	assert.ErrorIs(t, err, ErrorThirdPartyNotFound, "an incomplete snapshot is no snapshot")
+}
@@ -128,6 +128,7 @@ func (c *client) GetChargeLinksWithCharges(meteringPointIDs []string, from, to t
 
 	// Execute request
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetHeader("Accept", "application/json").
 		SetAuthToken(accessToken).
 		SetBody(chargeLinksRequest(meteringPointIDs, from, to)).
@@ -68,6 +68,7 @@ func (c *client) GetCustomerCharges(meteringPointIDs []string) ([]CustomerCharge
 	}
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetError(&apiErrBody).
@@ -80,7 +81,7 @@ func (c *client) GetCustomerCharges(meteringPointIDs []string) ([]CustomerCharge
 	if err = apiErrorFromBody(apiErrBody, res.StatusCode()); err != nil {
 		return nil, err
 	}
-	return result.Result, nil
+	return partialResult(c, result.Result)
 }
 
 func (c *client) GetThirdPartyCharges(meteringPointIDs []string) ([]ThirdPartyChargeResponse, error) {
@@ -95,6 +96,7 @@ func (c *client) GetThirdPartyCharges(meteringPointIDs []string) ([]ThirdPartyCh
 	}
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetError(&apiErrBody).
@@ -107,7 +109,7 @@ func (c *client) GetThirdPartyCharges(meteringPointIDs []string) ([]ThirdPartyCh
 	if err = apiErrorFromBody(apiErrBody, res.StatusCode()); err != nil {
 		return nil, err
 	}
-	return result.Result, nil
+	return partialResult(c, result.Result)
 }
 
 func (c *client) ExportCharges(meteringPointIDs []string) (io.ReadCloser, error) {
@@ -121,6 +123,7 @@ func (c *client) ExportCharges(meteringPointIDs []string) (io.ReadCloser, error)
 	}
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetDoNotParseResponse(true).
@@ -0,0 +1,213 @@
+package eloverblik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Defaults of NewCircuitBreaker.
+const (
+	// DefaultCircuitThreshold is the number of consecutive failures that opens the circuit.
+	DefaultCircuitThreshold = 5
+	// DefaultCircuitCooldown is how long an open circuit fails fast before it probes the
+	// API again.
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is matched, with errors.Is, by the *CircuitOpenError a call fails with
+// while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned without calling the API while the circuit breaker is open.
+type CircuitOpenError struct {
+	// Until is when the breaker probes the API again.
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("eloverblik: %v: DataHub is unavailable, next probe at %s", ErrCircuitOpen, e.Until.Format(time.RFC3339))
+}
+
+// Unwrap returns ErrCircuitOpen.
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through. It is the initial state.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call with a *CircuitOpenError until the cooldown ends.
+	CircuitOpen
+	// CircuitHalfOpen probes the API with IsAlive; calls meanwhile fail fast.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreaker stops calling the API while DataHub is down. When DataHub is unavailable
+// the API answers 503 for minutes at a time, and without a breaker every call waits out
+// the full retry policy before it fails.
+//
+// The breaker counts consecutive failures: every attempt answered with a 503, and every
+// call that timed out. Any other answer, an error status included, shows the API is up
+// and resets the count; streamed exports are not counted. Once the count reaches the
+// threshold the circuit opens, and calls fail at once with a *CircuitOpenError, also
+// those waiting to retry. When the cooldown has passed, the next call probes /isalive;
+// the circuit closes when the API is alive and opens for another cooldown when it is not.
+//
+// A breaker is safe for concurrent use, and can be shared by several clients: they then
+// open and close together. Pass it to a client with WithCircuitBreaker.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	until    time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker that opens after threshold
+// consecutive failures and probes the API again after cooldown. A threshold or cooldown
+// of zero or less falls back to DefaultCircuitThreshold or DefaultCircuitCooldown.
+//
+// Example:
+//
+//	breaker := eloverblik.NewCircuitBreaker(5, 30*time.Second)
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithCircuitBreaker(breaker))
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitCooldown
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// State returns the current state of the breaker. An open circuit whose cooldown has
+// passed stays CircuitOpen until a call probes the API.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow decides whether a call may go ahead. probe is true for the one call that has to
+// probe the API before the circuit can close.
+func (b *CircuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.state == CircuitClosed:
+		return false, nil
+	case b.state == CircuitOpen && !time.Now().Before(b.until):
+		b.state = CircuitHalfOpen
+		return true, nil
+	}
+	return false, &CircuitOpenError{Until: b.until}
+}
+
+// probed closes the circuit after a successful probe, and opens it for another cooldown
+// after a failed one.
+func (b *CircuitBreaker) probed(alive bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if alive {
+		b.state, b.failures = CircuitClosed, 0
+		return nil
+	}
+	b.open()
+	return &CircuitOpenError{Until: b.until}
+}
+
+// record counts the outcome of an attempt while the circuit is closed.
+func (b *CircuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	if b.failures++; b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+// open opens the circuit for a cooldown. The caller holds mu.
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.until = time.Now().Add(b.cooldown)
+}
+
+// circuitResponse is response middleware that counts every response for the breaker: a
+// 503 as a failure, and any other answer as a sign the API is up.
+func (c *client) circuitResponse(_ *resty.Client, res *resty.Response) error {
+	if !isCircuitProbe(res.Request) {
+		c.breaker.record(res.StatusCode() == http.StatusServiceUnavailable)
+	}
+	return nil
+}
+
+// circuitError is an error hook that counts a call that timed out as a failure. It runs
+// once per call, with the error of the last attempt.
+func (c *client) circuitError(req *resty.Request, err error) {
+	var netErr net.Error
+	if !isCircuitProbe(req) && errors.As(err, &netErr) && netErr.Timeout() {
+		c.breaker.record(true)
+	}
+}
+
+// circuitProbeKey marks the context of the request probing the API for the breaker, which
+// bypasses the breaker and is neither retried nor counted.
+type circuitProbeKey struct{}
+
+// isCircuitProbe reports whether a request is a probe of the breaker.
+func isCircuitProbe(req *resty.Request) bool {
+	probe, _ := req.Context().Value(circuitProbeKey{}).(bool)
+	return probe
+}
+
+// circuitGate is request middleware that fails a request while the client's circuit is
+// open, and probes the API when the cooldown has passed.
+func (c *client) circuitGate(_ *resty.Client, req *resty.Request) error {
+	if isCircuitProbe(req) {
+		return nil
+	}
+
+	probe, err := c.breaker.allow()
+	if !probe {
+		return err
+	}
+
+	res, err := c.resty.R().
+		SetContext(context.WithValue(req.Context(), circuitProbeKey{}, true)).
+		Get("/isalive")
+	return c.breaker.probed(err == nil && res.IsSuccess())
+}
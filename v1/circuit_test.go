@@ -0,0 +1,171 @@
+package eloverblik
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// outageServer answers /token with a token, and /isalive and the metering points with
+// the statuses its fields hold, counting the calls to each.
+type outageServer struct {
+	pointsStatus, aliveStatus atomic.Int32
+	pointsCalls, aliveCalls   atomic.Int32
+}
+
+func newOutageServer(t *testing.T) (*outageServer, *httptest.Server) {
+	t.Helper()
+
+	s := &outageServer{}
+	s.pointsStatus.Store(http.StatusOK)
+	s.aliveStatus.Store(http.StatusOK)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/token"):
+			_, _ = io.WriteString(w, `{"result":"fake-access-token"}`)
+		case strings.HasSuffix(r.URL.Path, "/isalive"):
+			s.aliveCalls.Add(1)
+			w.WriteHeader(int(s.aliveStatus.Load()))
+			_, _ = io.WriteString(w, "true")
+		default:
+			s.pointsCalls.Add(1)
+			status := int(s.pointsStatus.Load())
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				_, _ = io.WriteString(w, `{"result":[]}`)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return s, server
+}
+
+func newBreakerClient(t *testing.T, breaker *CircuitBreaker, opts ...Option) (*outageServer, Customer) {
+	t.Helper()
+
+	s, server := newOutageServer(t)
+	c := NewCustomer("test-refresh-token", append([]Option{WithCircuitBreaker(breaker)}, opts...)...)
+	c.(*client).resty.SetBaseURL(server.URL)
+	return s, c
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	breaker := NewCircuitBreaker(3, time.Hour)
+	s, c := newBreakerClient(t, breaker, WithoutRetry())
+	s.pointsStatus.Store(http.StatusServiceUnavailable)
+
+	for range 3 {
+		_, err := c.GetMeteringPoints(false)
+		assert.Equal(t, ErrorClientConnection(http.StatusServiceUnavailable), err)
+	}
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	_, err := c.GetMeteringPoints(false)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	var open *CircuitOpenError
+	if assert.True(t, errors.As(err, &open)) {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), open.Until, time.Minute)
+	}
+	assert.Equal(t, int32(3), s.pointsCalls.Load(), "an open circuit does not call the API")
+}
+
+func TestCircuitBreakerResetsOnAnswer(t *testing.T) {
+	breaker := NewCircuitBreaker(3, time.Hour)
+	s, c := newBreakerClient(t, breaker, WithoutRetry())
+
+	for _, status := range []int{503, 503, 404, 503, 503} {
+		s.pointsStatus.Store(int32(status))
+		_, _ = c.GetMeteringPoints(false)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State(), "an error status other than 503 shows the API is up")
+}
+
+func TestCircuitBreakerStopsRetries(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Hour)
+	s, c := newBreakerClient(t, breaker, WithRetry(5, testRetryWait))
+	s.pointsStatus.Store(http.StatusServiceUnavailable)
+
+	_, err := c.GetMeteringPoints(false)
+	assert.Equal(t, ErrorClientConnection(http.StatusServiceUnavailable), err)
+	assert.Equal(t, int32(2), s.pointsCalls.Load(), "the retries stop once the circuit opens")
+	assert.Equal(t, CircuitOpen, breaker.State())
+}
+
+func TestCircuitBreakerProbes(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+
+	breaker := NewCircuitBreaker(1, cooldown)
+	s, c := newBreakerClient(t, breaker, WithoutRetry())
+	s.pointsStatus.Store(http.StatusServiceUnavailable)
+	s.aliveStatus.Store(http.StatusServiceUnavailable)
+
+	_, _ = c.GetMeteringPoints(false)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// Still down after the cooldown: the probe fails and the circuit opens again
+	time.Sleep(cooldown)
+	_, err := c.GetMeteringPoints(false)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(1), s.aliveCalls.Load())
+	assert.Equal(t, int32(1), s.pointsCalls.Load())
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// Back up: the probe closes the circuit and the call goes through
+	s.pointsStatus.Store(http.StatusOK)
+	s.aliveStatus.Store(http.StatusOK)
+	time.Sleep(cooldown)
+	_, err = c.GetMeteringPoints(false)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), s.aliveCalls.Load())
+	assert.Equal(t, int32(2), s.pointsCalls.Load())
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+// TestCircuitBreakerProbesOnce makes sure that concurrent callers after the cooldown do
+// not all probe the API: one does, and the others fail fast or find the circuit closed.
+func TestCircuitBreakerProbesOnce(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+
+	breaker := NewCircuitBreaker(1, cooldown)
+	s, c := newBreakerClient(t, breaker, WithoutRetry())
+	s.pointsStatus.Store(http.StatusServiceUnavailable)
+	_, _ = c.GetMeteringPoints(false)
+	s.pointsStatus.Store(http.StatusOK)
+
+	time.Sleep(cooldown)
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetMeteringPoints(false); err != nil {
+				assert.ErrorIs(t, err, ErrCircuitOpen)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), s.aliveCalls.Load())
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestNewCircuitBreakerDefaults(t *testing.T) {
+	breaker := NewCircuitBreaker(0, -time.Second)
+	assert.Equal(t, DefaultCircuitThreshold, breaker.threshold)
+	assert.Equal(t, DefaultCircuitCooldown, breaker.cooldown)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+}
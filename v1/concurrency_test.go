@@ -0,0 +1,191 @@
+package eloverblik
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrencyServer answers every endpoint of both APIs with an empty but well formed
+// result, and counts the calls to /token. A token request takes a while, so that
+// concurrent callers overlap with it.
+func concurrencyServer(t *testing.T, accessToken string, tokenCalls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			tokenCalls.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			_, _ = io.WriteString(w, `{"result":"`+accessToken+`"}`)
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/isalive") && r.Header.Get("Authorization") != "Bearer "+accessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/isalive"):
+			_, _ = io.WriteString(w, "true")
+		case strings.Contains(r.URL.Path, "/export"):
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, exportedCSV)
+		case strings.HasSuffix(r.URL.Path, "getchargelinkswithcharges"):
+			_, _ = io.WriteString(w, `{"result":{}}`)
+		case r.Method == http.MethodPut:
+			_, _ = io.WriteString(w, `{"result":"added"}`)
+		case r.Method == http.MethodDelete:
+			_, _ = io.WriteString(w, `{"result":true}`)
+		default:
+			_, _ = io.WriteString(w, `{"result":[]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// TestClientConcurrentUse hammers every method of a shared client from many goroutines.
+// Run with -race, as CI does, it guards the client's claim to be goroutine-safe; the
+// token count guards the single request to /token, which is limited to two a minute.
+func TestClientConcurrentUse(t *testing.T) {
+	const goroutines = 8
+
+	ids := []string{"571313180100000001"}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	to := from.AddDate(0, 1, 0)
+
+	// shared are the calls both APIs have
+	shared := func(c Client) []func() error {
+		return []func() error{
+			func() error { _, err := c.GetDataAccessToken(); return err },
+			func() error { _, err := c.RefreshTokenClaims(); return err },
+			func() error { _, err := c.DataAccessTokenClaims(); return err },
+			func() error { _, err := c.GetMeteringPointDetails(ids); return err },
+			func() error { _, err := c.GetTimeSeries(ids, from, to, Hour); return err },
+			func() error { _, err := c.GetMeterReadings(ids, from, to); return err },
+			func() error { _, err := c.GetChargeLinksWithCharges(ids, from, to); return err },
+			func() error { _, err := c.IsAlive(); return err },
+		}
+	}
+	export := func(stream io.ReadCloser, err error) error {
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(io.Discard, stream)
+		_ = stream.Close()
+		return err
+	}
+
+	run := func(t *testing.T, calls []func() error) {
+		t.Helper()
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for range goroutines {
+			for _, call := range calls {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					assert.NoError(t, call())
+				}()
+			}
+		}
+		close(start)
+		wg.Wait()
+	}
+
+	t.Run("customer", func(t *testing.T) {
+		accessToken := testToken(t, map[string]any{"tokenType": "CUSTOMERAPI_DataAccess"})
+		var tokenCalls atomic.Int32
+		server := concurrencyServer(t, accessToken, &tokenCalls)
+
+		c := NewCustomer(testToken(t, map[string]any{"tokenType": "CUSTOMERAPI_Refresh"}), WithResponseHeaderOutput(io.Discard))
+		c.(*client).resty.SetBaseURL(server.URL + "/customerapi/api")
+
+		run(t, append(shared(c),
+			func() error { _, err := c.GetCustomerCharges(ids); return err },
+			func() error { _, err := c.AddRelationByID(ids); return err },
+			func() error { _, err := c.AddRelationByWebAccessCode(ids[0], "12345678"); return err },
+			func() error { _, err := c.DeleteRelation(ids[0]); return err },
+			func() error { _, err := c.GetMeteringPoints(false); return err },
+			func() error { return export(c.ExportTimeSeries(ids, from, to, Hour)) },
+			func() error { return export(c.ExportMasterdata(ids)) },
+			func() error { return export(c.ExportCharges(ids)) },
+		))
+
+		assert.Equal(t, int32(1), tokenCalls.Load(), "concurrent callers share a single token request")
+	})
+
+	t.Run("third party", func(t *testing.T) {
+		accessToken := testToken(t, map[string]any{"tokenType": "THIRDPARTYAPI_DataAccess"})
+		var tokenCalls atomic.Int32
+		server := concurrencyServer(t, accessToken, &tokenCalls)
+
+		c := NewThirdParty(testToken(t, map[string]any{"tokenType": "THIRDPARTYAPI_Refresh"}), WithPartialResultErrors())
+		c.(*client).resty.SetBaseURL(server.URL + "/thirdpartyapi/api")
+
+		run(t, append(shared(c),
+			func() error { _, err := c.GetThirdPartyCharges(ids); return err },
+			func() error { _, err := c.GetAuthorizations(); return err },
+			func() error { _, err := c.GetMeteringPointsForScope(AuthScopeCustomerCVR, "12345678"); return err },
+			func() error { _, err := c.GetMeteringPointIDsForScope(AuthScopeCustomerCVR, "12345678"); return err },
+		))
+
+		assert.Equal(t, int32(1), tokenCalls.Load(), "concurrent callers share a single token request")
+	})
+}
+
+// TestGetDataAccessTokenSharesFailure makes sure that callers waiting for a token request
+// get its error too, instead of each trying again in turn.
+func TestGetDataAccessTokenSharesFailure(t *testing.T) {
+	const goroutines = 16
+
+	var tokenCalls atomic.Int32
+	var arrived sync.WaitGroup
+	arrived.Add(goroutines)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenCalls.Add(1)
+		// Answer once every caller is on its way, and give them time to queue up
+		arrived.Wait()
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `"[50001] Token is invalid"`)
+	}))
+	defer server.Close()
+
+	c := NewCustomer("revoked-refresh-token", WithoutRetry())
+	c.(*client).resty.SetBaseURL(server.URL)
+
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arrived.Done()
+			token, err := c.GetDataAccessToken()
+			assert.ErrorIs(t, err, ErrorTokenNotValid)
+			assert.Empty(t, token)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), tokenCalls.Load())
+
+	// A failed request is not cached: the next caller tries again
+	_, err := c.GetDataAccessToken()
+	assert.ErrorIs(t, err, ErrorTokenNotValid)
+	assert.Equal(t, int32(2), tokenCalls.Load())
+}
@@ -1,6 +1,12 @@
 package eloverblik
 
-import "time"
+import (
+	"time"
+
+	// The days of the API are Danish days, which must not depend on the host having a
+	// zoneinfo database, as scratch and distroless containers do not
+	_ "time/tzdata"
+)
 
 type Aggregation string
 type APIType string
@@ -22,6 +28,10 @@ var (
 	ApiType APIType = customerApiAtype
 
 	cph, _ = time.LoadLocation("Europe/Copenhagen")
+
+	// Copenhagen is the time zone the days, months and years of the API are in. Its zone
+	// data is embedded, so it loads on hosts without a zoneinfo database too.
+	Copenhagen = cph
 )
 
 const (
@@ -1,15 +1,44 @@
 package eloverblik
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/go-resty/resty/v2"
 )
 
 // client is the internal implementation that satisfies the Customer and ThirdParty interfaces.
+//
+// A client is safe for concurrent use by multiple goroutines. Its fields are set once by
+// the constructor and its options, except for the data access token and the time of the
+// last token expiry check, which are guarded by tokenMu.
 type client struct {
 	refreshToken string
-	accessToken  string
 	resty        *resty.Client
 	apiType      apiType
+
+	// accessToken is the data access token, fetched on first use. tokenRequest is the
+	// request fetching it, while one runs.
+	tokenMu      sync.Mutex
+	accessToken  string
+	tokenRequest *tokenRequest
+
+	// expiryThreshold and onExpiry are set with WithTokenExpiryWarning. expiryCheckedAt
+	// is when the refresh token was last checked for it, guarded by tokenMu.
+	expiryThreshold time.Duration
+	onExpiry        func(TokenClaims)
+	expiryCheckedAt time.Time
+
+	// partialResultErrors is set with WithPartialResultErrors.
+	partialResultErrors bool
+
+	// retryMaxElapsed and retryHook are set with WithRetryMaxElapsed and WithRetryHook.
+	retryMaxElapsed time.Duration
+	retryHook       func(RetryEvent)
+
+	// breaker is set with WithCircuitBreaker.
+	breaker *CircuitBreaker
 }
 
 type apiType int
@@ -28,7 +57,8 @@ const (
 )
 
 // NewCustomer creates and returns a new Eloverblik Customer client.
-// Zero or more options can be passed to configure the client.
+// Zero or more options can be passed to configure the client. The client is safe for
+// concurrent use by multiple goroutines.
 //
 // Example:
 //
@@ -39,12 +69,14 @@ func NewCustomer(refreshToken string, opts ...Option) Customer {
 		resty:        newRestyClient("https://" + prodModeHost + "/customerapi/api"),
 		apiType:      CustomerApi,
 	}
+	c.useRetryCondition()
 	applyOptions(c, opts)
 	return c
 }
 
 // NewThirdParty creates and returns a new Eloverblik ThirdParty client.
-// Zero or more options can be passed to configure the client.
+// Zero or more options can be passed to configure the client. The client is safe for
+// concurrent use by multiple goroutines.
 //
 // Example:
 //
@@ -55,16 +87,61 @@ func NewThirdParty(refreshToken string, opts ...Option) ThirdParty {
 		resty:        newRestyClient("https://" + prodModeHost + "/thirdpartyapi/api"),
 		apiType:      ThirdPartyApi,
 	}
+	c.useRetryCondition()
 	applyOptions(c, opts)
 	return c
 }
 
+// NewFromToken creates a client for the API the refresh token was issued for, read from
+// its claims. The result implements Customer or ThirdParty accordingly, and a type switch
+// tells which:
+//
+//	c, err := eloverblik.NewFromToken(refreshToken)
+//	if err != nil {
+//		return err
+//	}
+//	switch api := c.(type) {
+//	case eloverblik.ThirdParty:
+//		authorizations, err := api.GetAuthorizations()
+//	case eloverblik.Customer:
+//		meteringPoints, err := api.GetMeteringPoints(false)
+//	}
+//
+// It fails when the token's claims cannot be read or name neither API, or when the token
+// is a data access token rather than a refresh token.
+func NewFromToken(refreshToken string, opts ...Option) (Client, error) {
+	claims, err := ParseToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsDataAccessToken() {
+		return nil, fmt.Errorf("token type '%s' is a data access token, not a refresh token", claims.TokenType)
+	}
+
+	api, err := claims.APIType()
+	if err != nil {
+		return nil, err
+	}
+	if api == ThirdPartyApi {
+		return thirdPartyClient{NewThirdParty(refreshToken, opts...)}, nil
+	}
+	return customerClient{NewCustomer(refreshToken, opts...)}, nil
+}
+
+// customerClient and thirdPartyClient narrow a client to the methods of one API. *client
+// implements both interfaces, so without them a type switch on the result of
+// NewFromToken would take whichever case came first.
+type customerClient struct{ Customer }
+type thirdPartyClient struct{ ThirdParty }
+
 // newRestyClient creates the HTTP client shared by both APIs: the base URL, the pinned
-// api-version header and the default retry policy for the documented rate limits.
+// api-version header and the default retry policy for the documented rate limits and
+// transient network errors.
 func newRestyClient(baseURL string) *resty.Client {
 	client := resty.New().
 		SetBaseURL(baseURL).
-		SetHeader(apiVersionHeader, apiVersion)
+		SetHeader(apiVersionHeader, apiVersion).
+		OnBeforeRequest(markFirstAttempt)
 
 	return setRetryPolicy(client, DefaultRetryCount, DefaultRetryMaxWait)
 }
@@ -39,6 +39,41 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestNewFromToken(t *testing.T) {
+	t.Run("a customer refresh token makes a Customer client", func(t *testing.T) {
+		c, err := NewFromToken(testToken(t, map[string]any{"tokenType": "CUSTOMERAPI_Refresh"}))
+		assert.NoError(t, err)
+
+		_, isThirdParty := c.(ThirdParty)
+		assert.False(t, isThirdParty)
+		customer, isCustomer := c.(Customer)
+		assert.True(t, isCustomer)
+		assert.Equal(t, CustomerApi, customer.(customerClient).Customer.(*client).apiType)
+	})
+
+	t.Run("a third party refresh token makes a ThirdParty client", func(t *testing.T) {
+		c, err := NewFromToken(testToken(t, map[string]any{"tokenType": "THIRDPARTYAPI_Refresh"}), WithPreprod())
+		assert.NoError(t, err)
+
+		thirdParty, isThirdParty := c.(ThirdParty)
+		assert.True(t, isThirdParty)
+		_, isCustomer := c.(Customer)
+		assert.False(t, isCustomer)
+		assert.Equal(t, "https://"+testModeHost+"/thirdpartyapi/api", thirdParty.(thirdPartyClient).ThirdParty.(*client).resty.BaseURL, "options are applied")
+	})
+
+	t.Run("fails for tokens that name no API", func(t *testing.T) {
+		_, err := NewFromToken("not-a-jwt")
+		assert.Error(t, err)
+
+		_, err = NewFromToken(testToken(t, map[string]any{"tokenType": "SomethingElse"}))
+		assert.ErrorContains(t, err, "cannot tell the API")
+
+		_, err = NewFromToken(testToken(t, map[string]any{"tokenType": "CUSTOMERAPI_DataAccess"}))
+		assert.ErrorContains(t, err, "not a refresh token")
+	})
+}
+
 // TestApiVersionHeader guards the pinned api-version. Both specs declare the header on
 // every operation with a server-side default of "1.0"; the library used to leave it out
 // entirely, so a bump of that default could silently change the response shapes.
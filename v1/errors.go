@@ -2,12 +2,16 @@ package eloverblik
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 func ErrorClientConnection(status int) error {
@@ -170,11 +174,133 @@ func newAPIError(problem *problemDetails, statusCode int) *APIError {
 	return apiErr
 }
 
+// PartialResultError reports the metering points a multi-item call failed for, while
+// the call itself succeeded. getdetails, getcharges, gettimeseries and getmeterreadings
+// answer 200 with one item per metering point, and an item the API could not serve
+// carries its error in its StatusResponse instead of failing the request.
+//
+// It is returned by PartialResult, and by the client itself when created with
+// WithPartialResultErrors, next to the items that succeeded. It unwraps to the sentinel
+// of every failure, so errors.Is matches any of them:
+//
+//	details, err := client.GetMeteringPointDetails(ids)
+//	var partial *eloverblik.PartialResultError
+//	if errors.As(err, &partial) {
+//		for _, failure := range partial.Failures {
+//			log.Printf("%s: %v", failure.MeteringPointID, failure.Err)
+//		}
+//	}
+type PartialResultError struct {
+	// Failures are the failed items, in the order the API returned them.
+	Failures []ItemError
+}
+
+// ItemError is a single failed item of a partial result.
+type ItemError struct {
+	// MeteringPointID is the metering point the item was for.
+	MeteringPointID string
+	// Code and Text are the errorCode and errorText of the item, e.g. 20008 and
+	// "MeteringPointNotFound".
+	Code int
+	Text string
+	// Err is the sentinel the code maps to, e.g. ErrorMeteringPointNotFound.
+	Err error
+}
+
+// Error lists the failures, each by its Err, or by its Code and Text when it has none.
+func (e *PartialResultError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		reason := fmt.Sprintf("[%d] %s", failure.Code, failure.Text)
+		if failure.Err != nil {
+			reason = failure.Err.Error()
+		}
+		failures = append(failures, failure.MeteringPointID+": "+reason)
+	}
+	return fmt.Sprintf("eloverblik: %d metering point(s) failed: %s", len(e.Failures), strings.Join(failures, "; "))
+}
+
+// Unwrap returns the sentinel of every failure that has one.
+func (e *PartialResultError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		if failure.Err != nil {
+			errs = append(errs, failure.Err)
+		}
+	}
+	return errs
+}
+
+// Err returns the error the status of a result item stands for: nil on success, the
+// sentinel its error code maps to, or an error carrying the code and text when the code
+// has no sentinel.
+func (s StatusResponse) Err() error {
+	if s.Success {
+		return nil
+	}
+	if sentinel, known := apiErrorMap[uint64(s.ErrorCode)]; known && sentinel != nil {
+		return sentinel
+	}
+	return fmt.Errorf("unhandled error: [%d] %s", s.ErrorCode, s.ErrorText)
+}
+
+// status gives access to the StatusResponse a result item embeds.
+func (s StatusResponse) status() StatusResponse { return s }
+
+// resultItem is a result item that embeds StatusResponse: the items of getdetails,
+// getcharges, gettimeseries and getmeterreadings.
+type resultItem interface {
+	status() StatusResponse
+}
+
+// PartialResult separates the items the API served from the ones it failed for. It
+// returns the successful items, and a *PartialResultError listing the failed ones, nil
+// when there are none.
+//
+// Example:
+//
+//	series, err := client.GetTimeSeries(ids, from, to, eloverblik.Hour)
+//	if err != nil {
+//		return err
+//	}
+//	series, err = eloverblik.PartialResult(series)
+func PartialResult[T resultItem](items []T) ([]T, error) {
+	var partial PartialResultError
+	succeeded := make([]T, 0, len(items))
+
+	for _, item := range items {
+		status := item.status()
+		if err := status.Err(); err != nil {
+			partial.Failures = append(partial.Failures, ItemError{
+				MeteringPointID: status.ID,
+				Code:            status.ErrorCode,
+				Text:            status.ErrorText,
+				Err:             err,
+			})
+			continue
+		}
+		succeeded = append(succeeded, item)
+	}
+
+	if len(partial.Failures) > 0 {
+		return succeeded, &partial
+	}
+	return succeeded, nil
+}
+
+// partialResult applies WithPartialResultErrors to the items of a call that succeeded.
+func partialResult[T resultItem](c *client, items []T) ([]T, error) {
+	if !c.partialResultErrors {
+		return items, nil
+	}
+	return PartialResult(items)
+}
+
 // isRetryableError reports whether a response is worth retrying. Only the two transient
 // conditions documented by the API qualify: 429 when a rate limit is exceeded and 503
 // when DataHub is unable to keep up. Everything else - 401, any other 4xx, 500 - is a
-// permanent answer and is handed to the caller right away. Transport errors are not
-// retried either, so a request is never sent twice by accident.
+// permanent answer and is handed to the caller right away. A request that failed without
+// a response is classified by transportError instead.
 func isRetryableError(statusCode int, err error) bool {
 
 	if err != nil {
@@ -183,6 +309,38 @@ func isRetryableError(statusCode int, err error) bool {
 	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
 }
 
+// transportError classifies the error of a request that got no response. transient
+// reports whether trying again can help, and sent whether the request may have reached
+// the API: a failed DNS lookup or a refused connection means it never left, while a reset
+// connection or a timeout leaves it unknown whether the API acted on it. reason describes
+// the error for a RetryEvent.
+func transportError(err error) (transient, sent bool, reason string) {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false, true, "canceled"
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return false, false, "host not found"
+		}
+		return true, false, "DNS lookup failed"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return true, false, "connection failed"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return true, true, "connection reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true, true, "connection closed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true, true, "timeout"
+	}
+
+	// Certificate errors, malformed responses and the like fail the same way every time
+	return false, true, "transport error"
+}
+
 // isSuccessStatus reports whether a status code is a 2xx.
 func isSuccessStatus(statusCode int) bool {
 	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
@@ -248,3 +248,40 @@ func TestApiErrorNoCprConsent(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, ErrorNoCprConsent, err)
 }
+
+func TestPartialResult(t *testing.T) {
+	series := []TimeSeries{
+		{StatusResponse: StatusResponse{Success: true, ErrorCode: 10000, ErrorText: "NoError", ID: "571313180100000001"}},
+		{StatusResponse: StatusResponse{ErrorCode: 20008, ErrorText: "MeteringPointNotFound", ID: "571313180100000002"}},
+		{StatusResponse: StatusResponse{ErrorCode: 30010, ErrorText: "DateNotCoveredByAuthorization", ID: "571313180100000003"}},
+		{StatusResponse: StatusResponse{ErrorCode: 99999, ErrorText: "Something new", ID: "571313180100000004"}},
+	}
+
+	succeeded, err := PartialResult(series)
+
+	require.Len(t, succeeded, 1)
+	assert.Equal(t, "571313180100000001", succeeded[0].ID)
+
+	var partial *PartialResultError
+	require.ErrorAs(t, err, &partial)
+	require.Len(t, partial.Failures, 3)
+	assert.Equal(t, ItemError{MeteringPointID: "571313180100000002", Code: 20008, Text: "MeteringPointNotFound", Err: ErrorMeteringPointNotFound}, partial.Failures[0])
+	assert.Equal(t, "571313180100000003", partial.Failures[1].MeteringPointID)
+	assert.ErrorContains(t, partial.Failures[2].Err, "[99999] Something new", "a code without a sentinel keeps its text")
+
+	assert.ErrorIs(t, err, ErrorMeteringPointNotFound)
+	assert.ErrorIs(t, err, ErrorDateNotCoveredByAuthorization)
+	assert.Contains(t, err.Error(), "3 metering point(s) failed")
+
+	t.Run("a failure built without Err", func(t *testing.T) {
+		err := &PartialResultError{Failures: []ItemError{{MeteringPointID: "571313180100000002", Code: 20008, Text: "MeteringPointNotFound"}}}
+		assert.EqualError(t, err, "eloverblik: 1 metering point(s) failed: 571313180100000002: [20008] MeteringPointNotFound")
+		assert.NotErrorIs(t, err, ErrorMeteringPointNotFound)
+	})
+
+	t.Run("no failures is no error", func(t *testing.T) {
+		succeeded, err := PartialResult(series[:1])
+		assert.NoError(t, err)
+		assert.Len(t, succeeded, 1)
+	})
+}
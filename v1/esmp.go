@@ -0,0 +1,125 @@
+package eloverblik
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// esmpDocumentElement is the name of the root element of the XML form of a
+// MyEnergyDataMarketDocumentResponse.
+const esmpDocumentElement = "MyEnergyData_MarketDocument"
+
+// MarshalXML writes the document in the ESMP layout of the IEC CIM: the element names are
+// those of the JSON the API renders, an identifier carries its coding scheme as the
+// codingScheme attribute, and times are in UTC, the bounds of a time interval to the
+// minute as ESMP_DateTime requires:
+//
+//	<MyEnergyData_MarketDocument>
+//	  <mRID>2b43a48a-ae74-4059-a72c-325515b6279a</mRID>
+//	  <createdDateTime>2024-01-02T05:00:00Z</createdDateTime>
+//	  <sender_MarketParticipant.mRID codingScheme="A10">5790001330583</sender_MarketParticipant.mRID>
+//	  <period.timeInterval><start>2023-12-31T23:00Z</start><end>2024-01-01T23:00Z</end></period.timeInterval>
+//	  <TimeSeries>
+//	    <MarketEvaluationPoint><mRID codingScheme="A10">571313180100000001</mRID></MarketEvaluationPoint>
+//	    <Period>
+//	      <resolution>PT1H</resolution>
+//	      <Point><position>1</position><out_Quantity.quantity>0.123</out_Quantity.quantity>...
+//
+// The root element is always MyEnergyData_MarketDocument, in the namespace of start, so a
+// document nested in another keeps its name. It is written with encoding/xml:
+//
+//	out, err := xml.MarshalIndent(ts.MyEnergyDataMarketDocument, "", "  ")
+func (d MyEnergyDataMarketDocumentResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	// document has the fields but not the methods, so encoding it does not recurse
+	type document MyEnergyDataMarketDocumentResponse
+
+	start.Name.Local = esmpDocumentElement
+	d.CreatedDateTime = d.CreatedDateTime.UTC()
+	return e.EncodeElement(document(d), start)
+}
+
+// ParseMyEnergyDataMarketDocument reads a MyEnergyData_MarketDocument in its XML form, as
+// MarshalXML writes it, from any source: the namespace is not checked, and the bounds of a
+// time interval may be given to the minute or to the second. A document of another kind
+// is an error.
+//
+// Wrap the result in a TimeSeries to flatten it:
+//
+//	doc, err := eloverblik.ParseMyEnergyDataMarketDocument(file)
+//	ts := eloverblik.TimeSeries{MyEnergyDataMarketDocument: doc}
+//	points := ts.Flatten()
+func ParseMyEnergyDataMarketDocument(r io.Reader) (MyEnergyDataMarketDocumentResponse, error) {
+	var doc MyEnergyDataMarketDocumentResponse
+
+	dec := xml.NewDecoder(r)
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return doc, fmt.Errorf("no %s in the document", esmpDocumentElement)
+		}
+		if err != nil {
+			return doc, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != esmpDocumentElement {
+			return doc, fmt.Errorf("expected a %s, got a %s", esmpDocumentElement, start.Name.Local)
+		}
+		return doc, dec.DecodeElement(&doc, &start)
+	}
+}
+
+// esmpTimeInterval is the XML form of a TimeInterval.
+type esmpTimeInterval struct {
+	Start string `xml:"start"`
+	End   string `xml:"end"`
+}
+
+// esmpMinute is the layout of an ESMP_DateTime: UTC, to the minute.
+const esmpMinute = "2006-01-02T15:04Z07:00"
+
+// MarshalXML writes the bounds of the interval in UTC to the minute, the ESMP_DateTime
+// layout, or to the second when a bound is not on a whole minute.
+func (ti TimeInterval) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(esmpTimeInterval{Start: formatESMPTime(ti.Start), End: formatESMPTime(ti.End)}, start)
+}
+
+// UnmarshalXML reads the bounds of the interval to the minute or to the second.
+func (ti *TimeInterval) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var interval esmpTimeInterval
+	if err := d.DecodeElement(&interval, &start); err != nil {
+		return err
+	}
+
+	var err error
+	if ti.Start, err = parseESMPTime(interval.Start); err != nil {
+		return err
+	}
+	ti.End, err = parseESMPTime(interval.End)
+	return err
+}
+
+func formatESMPTime(t time.Time) string {
+	t = t.UTC()
+	if t.Second() != 0 || t.Nanosecond() != 0 {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(esmpMinute)
+}
+
+func parseESMPTime(value string) (time.Time, error) {
+	t, err := time.Parse(esmpMinute, value)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339Nano, value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s' in a time interval", value)
+	}
+	return t.UTC(), nil
+}
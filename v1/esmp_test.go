@@ -0,0 +1,161 @@
+package eloverblik
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceDocument returns the MyEnergyData_MarketDocument of the API's reference
+// response in docs, and the keys it names. The reference holds "string" for every value,
+// so each is replaced by a value of its type, distinct where the type allows it.
+func referenceDocument(t *testing.T) (MyEnergyDataMarketDocumentResponse, []string) {
+	t.Helper()
+
+	body, err := os.ReadFile("../docs/MyEnergyDataMarketDocumentResponse.json")
+	require.NoError(t, err)
+
+	var reference struct {
+		Result []map[string]any `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(body, &reference))
+	require.NotEmpty(t, reference.Result)
+
+	values := map[string]string{
+		"createdDateTime":       "2024-01-02T05:00:00Z",
+		"start":                 "2023-12-31T23:00:00Z",
+		"end":                   "2024-01-01T23:00:00Z",
+		"resolution":            "PT1H",
+		"position":              "1",
+		"out_Quantity.quantity": "0.123",
+		"codingScheme":          "A10",
+	}
+	var keys []string
+	var fill func(v any) any
+	fill = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				keys = append(keys, key)
+				if s, ok := value.(string); ok {
+					v[key] = values[key]
+					if v[key] == "" {
+						v[key] = key + "-" + s
+					}
+					continue
+				}
+				v[key] = fill(value)
+			}
+		case []any:
+			for i := range v {
+				v[i] = fill(v[i])
+			}
+		}
+		return v
+	}
+	filled, err := json.Marshal(fill(reference.Result[0]["MyEnergyData_MarketDocument"]))
+	require.NoError(t, err)
+
+	var doc MyEnergyDataMarketDocumentResponse
+	require.NoError(t, json.Unmarshal(filled, &doc))
+	return doc, keys
+}
+
+func TestMyEnergyDataMarketDocumentXML(t *testing.T) {
+	doc, keys := referenceDocument(t)
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	require.NoError(t, err)
+
+	t.Run("round trips the reference document", func(t *testing.T) {
+		parsed, err := ParseMyEnergyDataMarketDocument(strings.NewReader(xml.Header + string(out)))
+		assert.NoError(t, err)
+		assert.Equal(t, doc, parsed)
+	})
+
+	t.Run("every key of the reference has an element or attribute", func(t *testing.T) {
+		for _, key := range keys {
+			if key == "name" {
+				continue // the identifier of an mRID is the element's text
+			}
+			if key == "codingScheme" {
+				assert.Contains(t, string(out), ` codingScheme="A10"`)
+				continue
+			}
+			assert.Regexp(t, "<"+regexp.QuoteMeta(key)+"[ >]", string(out), key)
+		}
+	})
+
+	t.Run("the ESMP layout", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(string(out), "<MyEnergyData_MarketDocument>"))
+		assert.Contains(t, string(out), `<sender_MarketParticipant.mRID codingScheme="A10">name-string</sender_MarketParticipant.mRID>`)
+		assert.Contains(t, string(out), "<start>2023-12-31T23:00Z</start>", "an ESMP_DateTime is to the minute")
+		assert.Contains(t, string(out), "<createdDateTime>2024-01-02T05:00:00Z</createdDateTime>")
+	})
+}
+
+func TestParseMyEnergyDataMarketDocument(t *testing.T) {
+	t.Run("a document from another source", func(t *testing.T) {
+		doc, err := ParseMyEnergyDataMarketDocument(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<MyEnergyData_MarketDocument xmlns="urn:example:esmp">
+  <mRID>doc-1</mRID>
+  <createdDateTime>2024-01-02T06:00:00+01:00</createdDateTime>
+  <TimeSeries>
+    <mRID>571313180100000001</mRID>
+    <measurement_Unit.name>KWH</measurement_Unit.name>
+    <MarketEvaluationPoint><mRID codingScheme="A10">571313180100000001</mRID></MarketEvaluationPoint>
+    <Period>
+      <resolution>PT1H</resolution>
+      <timeInterval><start>2023-12-31T23:00:00Z</start><end>2024-01-01T01:00Z</end></timeInterval>
+      <Point><position>1</position><out_Quantity.quantity>0.5</out_Quantity.quantity><out_Quantity.quality>A04</out_Quantity.quality></Point>
+      <Point><position>2</position><out_Quantity.quantity>0.25</out_Quantity.quantity><out_Quantity.quality>A03</out_Quantity.quality></Point>
+    </Period>
+  </TimeSeries>
+</MyEnergyData_MarketDocument>`))
+		require.NoError(t, err)
+		assert.Equal(t, "doc-1", doc.MRID)
+		assert.True(t, doc.CreatedDateTime.Equal(time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC)))
+
+		ts := TimeSeries{MyEnergyDataMarketDocument: doc}
+		points := ts.Flatten()
+		if assert.Len(t, points, 2) {
+			assert.Equal(t, "571313180100000001", ts.MeteringPointID())
+			assert.True(t, points[1].From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+			assert.Equal(t, 0.25, points[1].Measurement)
+			assert.Equal(t, "A03", points[1].Quality)
+		}
+	})
+
+	t.Run("another kind of document", func(t *testing.T) {
+		_, err := ParseMyEnergyDataMarketDocument(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom"/>`))
+		assert.ErrorContains(t, err, "expected a MyEnergyData_MarketDocument, got a feed")
+	})
+
+	t.Run("no document", func(t *testing.T) {
+		_, err := ParseMyEnergyDataMarketDocument(strings.NewReader(""))
+		assert.ErrorContains(t, err, "no MyEnergyData_MarketDocument")
+	})
+
+	t.Run("an invalid time", func(t *testing.T) {
+		_, err := ParseMyEnergyDataMarketDocument(strings.NewReader(
+			`<MyEnergyData_MarketDocument><period.timeInterval><start>yesterday</start></period.timeInterval></MyEnergyData_MarketDocument>`))
+		assert.ErrorContains(t, err, "invalid time 'yesterday'")
+	})
+}
+
+func TestMyEnergyDataMarketDocumentNestedKeepsItsName(t *testing.T) {
+	type envelope struct {
+		XMLName  xml.Name                           `xml:"envelope"`
+		Document MyEnergyDataMarketDocumentResponse `xml:"urn:example:esmp payload"`
+	}
+	out, err := xml.Marshal(envelope{})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `<MyEnergyData_MarketDocument xmlns="urn:example:esmp">`)
+}
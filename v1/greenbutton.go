@@ -0,0 +1,344 @@
+package eloverblik
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WriteGreenButton writes time series as a Green Button feed: the Atom feed of the NAESB
+// Energy Services Provider Interface (ESPI) that North American utilities publish for
+// "Download My Data", and which many analysis tools import.
+//
+// Every metering point becomes a UsagePoint, with a MeterReading and its ReadingType per
+// resolution of its points, and an IntervalBlock of readings per Danish day, or a single
+// one for daily and coarser points. The details of the metering points are optional: they
+// title the UsagePoint with the alias or address, and a production metering point (E18)
+// is read in the reverse direction. A single LocalTimeParameters entry describes Danish
+// time, in which ESPI tools present the readings.
+//
+// ESPI readings are integers, so quantities are written in Wh: the three decimals of a
+// kWh the API reports are kept. The quality of a point maps to an ESPI reading quality:
+//
+//	A04 (measured)      0  valid
+//	A01 (adjusted)      7  manually edited
+//	A03 (estimated)     8  estimated using reference day
+//	A02 (not available) 10 questionable
+//	A05 (incomplete)    10 questionable
+//	""  (see Resample)  13 mixed
+//	anything else       16 other
+//
+// The IDs of the entries are derived from the metering points, resolutions and the starts
+// of the blocks, so a second export of an overlapping range carries the same IDs for the
+// same days, and updates what a tool imported before. A time series that failed, see
+// StatusResponse.Err, is an error.
+func WriteGreenButton(w io.Writer, series []TimeSeries, details []MeteringPointDetail) error {
+	detailsByID := make(map[string]MeteringPointDetail, len(details))
+	for _, d := range details {
+		detailsByID[d.MeteringPointID] = d
+	}
+
+	feed := espiFeed{
+		ID:    espiID("feed"),
+		Title: "Eloverblik",
+	}
+	var updated time.Time
+	var entries []espiEntry
+	for i := range series {
+		ts := &series[i]
+		id := ts.MeteringPointID()
+		if err := ts.Err(); err != nil {
+			return fmt.Errorf("metering point %s: %w", id, err)
+		}
+
+		points := ts.Flatten()
+		for _, point := range points {
+			if point.To.After(updated) {
+				updated = point.To
+			}
+		}
+		if ts.MyEnergyDataMarketDocument.CreatedDateTime.After(updated) {
+			updated = ts.MyEnergyDataMarketDocument.CreatedDateTime
+		}
+
+		usagePoint, err := greenButtonUsagePoint(id, detailsByID[id], points)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, usagePoint...)
+	}
+
+	// The feed and its entries are as recent as the data, which keeps an export of the
+	// same data the same.
+	stamp := updated.UTC().Format(time.RFC3339)
+	feed.Updated = stamp
+	feed.Entries = append([]espiEntry{{
+		ID:      espiID(espiLocalTimeParametersHref),
+		Links:   []espiLink{{Href: espiLocalTimeParametersHref, Rel: "self"}},
+		Title:   "Europe/Copenhagen",
+		Content: espiContent{LocalTimeParameters: &danishLocalTimeParameters},
+	}}, entries...)
+	for i := range feed.Entries {
+		feed.Entries[i].Published, feed.Entries[i].Updated = stamp, stamp
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// greenButtonUsagePoint returns the entries of a metering point: its UsagePoint, and a
+// MeterReading, ReadingType and IntervalBlocks per resolution.
+func greenButtonUsagePoint(id string, detail MeteringPointDetail, points []FlatTimeSeriesPoint) ([]espiEntry, error) {
+	usagePointHref := "RetailCustomer/1/UsagePoint/" + id
+	entries := []espiEntry{{
+		ID: espiID(usagePointHref),
+		Links: []espiLink{
+			{Href: usagePointHref, Rel: "self"},
+			{Href: "RetailCustomer/1/UsagePoint", Rel: "up"},
+			{Href: usagePointHref + "/MeterReading", Rel: "related"},
+			{Href: espiLocalTimeParametersHref, Rel: "related"},
+		},
+		Title:   cmp.Or(detail.MeteringPointAlias, detail.Address(), id),
+		Content: espiContent{UsagePoint: &espiUsagePoint{ServiceCategory: espiServiceCategory{Kind: 0}}},
+	}}
+
+	flowDirection := 1 // forward: delivered to the customer
+	if detail.TypeOfMP == "E18" {
+		flowDirection = 19 // reverse: produced by the customer
+	}
+
+	var resolutions []Resolution
+	byResolution := make(map[Resolution][]FlatTimeSeriesPoint)
+	for _, point := range points {
+		if _, ok := byResolution[point.Resolution]; !ok {
+			resolutions = append(resolutions, point.Resolution)
+		}
+		byResolution[point.Resolution] = append(byResolution[point.Resolution], point)
+	}
+
+	for _, resolution := range resolutions {
+		points := byResolution[resolution]
+		factor, ok := greenButtonWhFactors[strings.ToUpper(points[0].Unit)]
+		if !ok {
+			return nil, fmt.Errorf("metering point %s: cannot write unit '%s' to Green Button", id, points[0].Unit)
+		}
+
+		meterReadingHref := usagePointHref + "/MeterReading/" + string(resolution)
+		readingTypeHref := "ReadingType/" + id + "-" + string(resolution)
+		entries = append(entries,
+			espiEntry{
+				ID: espiID(meterReadingHref),
+				Links: []espiLink{
+					{Href: meterReadingHref, Rel: "self"},
+					{Href: usagePointHref + "/MeterReading", Rel: "up"},
+					{Href: meterReadingHref + "/IntervalBlock", Rel: "related"},
+					{Href: readingTypeHref, Rel: "related"},
+				},
+				Title:   string(resolution),
+				Content: espiContent{MeterReading: &espiMeterReading{}},
+			},
+			espiEntry{
+				ID:    espiID(readingTypeHref),
+				Links: []espiLink{{Href: readingTypeHref, Rel: "self"}, {Href: "ReadingType", Rel: "up"}},
+				Title: "Energy in Wh",
+				Content: espiContent{ReadingType: &espiReadingType{
+					AccumulationBehaviour: 4,  // deltaData: the energy of each interval
+					Commodity:             1,  // electricity, secondary metered
+					DataQualifier:         12, // normal
+					FlowDirection:         flowDirection,
+					IntervalLength:        greenButtonIntervalLengths[resolution],
+					Kind:                  12, // energy
+					UOM:                   72, // Wh
+				}},
+			},
+		)
+
+		for _, block := range greenButtonBlocks(points, factor) {
+			href := fmt.Sprintf("%s/IntervalBlock/%d", meterReadingHref, block.Interval.Start)
+			entries = append(entries, espiEntry{
+				ID:      espiID(href),
+				Links:   []espiLink{{Href: href, Rel: "self"}, {Href: meterReadingHref + "/IntervalBlock", Rel: "up"}},
+				Content: espiContent{IntervalBlock: block},
+			})
+		}
+	}
+	return entries, nil
+}
+
+// greenButtonBlocks splits the points of a single resolution into IntervalBlocks: one per
+// Danish day for points shorter than a day, otherwise one.
+func greenButtonBlocks(points []FlatTimeSeriesPoint, factor float64) []*espiIntervalBlock {
+	// Points of a fixed length, shorter than a day, are split by day
+	_, perDay := greenButtonIntervalLengths[points[0].Resolution]
+
+	var blocks []*espiIntervalBlock
+	var blockDay time.Time
+	for _, point := range points {
+		day, _ := resampleInterval(point.From, Day)
+		if len(blocks) == 0 || perDay && !day.Equal(blockDay) {
+			blockDay = day
+			blocks = append(blocks, &espiIntervalBlock{Interval: espiInterval{Start: point.From.Unix()}})
+		}
+
+		block := blocks[len(blocks)-1]
+		block.Interval.Duration = point.To.Unix() - block.Interval.Start
+		block.Readings = append(block.Readings, espiIntervalReading{
+			ReadingQuality: espiReadingQuality{Quality: greenButtonQuality(point.Quality)},
+			TimePeriod:     espiInterval{Duration: point.To.Unix() - point.From.Unix(), Start: point.From.Unix()},
+			Value:          int64(math.Round(point.Measurement * factor)),
+		})
+	}
+	return blocks
+}
+
+// greenButtonQuality maps the quality of a point to an ESPI QualityOfReading.
+func greenButtonQuality(quality string) int {
+	switch quality {
+	case "A04":
+		return 0 // valid
+	case "A01":
+		return 7 // manually edited
+	case "A03":
+		return 8 // estimated using reference day
+	case "A02", "A05":
+		return 10 // questionable
+	case "":
+		return 13 // mixed
+	default:
+		return 16 // other
+	}
+}
+
+// greenButtonWhFactors convert the units the API measures in to Wh.
+var greenButtonWhFactors = map[string]float64{
+	"WH":  1,
+	"KWH": 1e3,
+	"MWH": 1e6,
+}
+
+// greenButtonIntervalLengths are the lengths in seconds of the resolutions shorter than a
+// day. A day, a month and a year vary in length, and their ReadingType carries none.
+var greenButtonIntervalLengths = map[Resolution]int64{
+	PT15M: 15 * 60,
+	PT1H:  60 * 60,
+}
+
+// espiID derives the ID of an entry from its href, so exporting the same metering point
+// again gives the same IDs.
+func espiID(href string) string {
+	return "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte("eloverblik:"+href)).String()
+}
+
+const espiLocalTimeParametersHref = "LocalTimeParameters/1"
+
+// danishLocalTimeParameters describe Danish time: UTC+1, and an hour of daylight saving
+// from 02:00 on the last Sunday of March to 03:00 on the last Sunday of October. A rule
+// is the bit field of ESPI: the month in bits 28-31, 6 ("the last") in bits 25-27 for the
+// occurrence, Sunday (7) in bits 17-19 and the hour in bits 12-16.
+var danishLocalTimeParameters = espiLocalTimeParameters{
+	DSTEndRule:   fmt.Sprintf("%08X", 10<<28|6<<25|7<<17|3<<12),
+	DSTOffset:    3600,
+	DSTStartRule: fmt.Sprintf("%08X", 3<<28|6<<25|7<<17|2<<12),
+	TZOffset:     3600,
+}
+
+type espiFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []espiEntry `xml:"entry"`
+}
+
+type espiEntry struct {
+	ID        string      `xml:"id"`
+	Links     []espiLink  `xml:"link"`
+	Title     string      `xml:"title"`
+	Content   espiContent `xml:"content"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+}
+
+type espiLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// espiContent holds the single ESPI resource of an entry.
+type espiContent struct {
+	UsagePoint          *espiUsagePoint
+	LocalTimeParameters *espiLocalTimeParameters
+	MeterReading        *espiMeterReading
+	ReadingType         *espiReadingType
+	IntervalBlock       *espiIntervalBlock
+}
+
+type espiUsagePoint struct {
+	XMLName         xml.Name            `xml:"http://naesb.org/espi UsagePoint"`
+	ServiceCategory espiServiceCategory `xml:"ServiceCategory"`
+}
+
+type espiServiceCategory struct {
+	Kind int `xml:"kind"` // 0: electricity
+}
+
+type espiLocalTimeParameters struct {
+	XMLName      xml.Name `xml:"http://naesb.org/espi LocalTimeParameters"`
+	DSTEndRule   string   `xml:"dstEndRule"`
+	DSTOffset    int      `xml:"dstOffset"`
+	DSTStartRule string   `xml:"dstStartRule"`
+	TZOffset     int      `xml:"tzOffset"`
+}
+
+type espiMeterReading struct {
+	XMLName xml.Name `xml:"http://naesb.org/espi MeterReading"`
+}
+
+// espiReadingType lists its elements in the order of the ESPI schema.
+type espiReadingType struct {
+	XMLName               xml.Name `xml:"http://naesb.org/espi ReadingType"`
+	AccumulationBehaviour int      `xml:"accumulationBehaviour"`
+	Commodity             int      `xml:"commodity"`
+	DataQualifier         int      `xml:"dataQualifier"`
+	FlowDirection         int      `xml:"flowDirection"`
+	IntervalLength        int64    `xml:"intervalLength,omitempty"`
+	Kind                  int      `xml:"kind"`
+	PowerOfTenMultiplier  int      `xml:"powerOfTenMultiplier"`
+	UOM                   int      `xml:"uom"`
+}
+
+type espiIntervalBlock struct {
+	XMLName  xml.Name              `xml:"http://naesb.org/espi IntervalBlock"`
+	Interval espiInterval          `xml:"interval"`
+	Readings []espiIntervalReading `xml:"IntervalReading"`
+}
+
+// espiInterval is a period in whole seconds since the Unix epoch.
+type espiInterval struct {
+	Duration int64 `xml:"duration"`
+	Start    int64 `xml:"start"`
+}
+
+type espiIntervalReading struct {
+	ReadingQuality espiReadingQuality `xml:"ReadingQuality"`
+	TimePeriod     espiInterval       `xml:"timePeriod"`
+	Value          int64              `xml:"value"`
+}
+
+type espiReadingQuality struct {
+	Quality int `xml:"quality"`
+}
@@ -0,0 +1,165 @@
+package eloverblik
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// greenButtonSeries returns a time series of hourly points from start, each of 0.5 kWh
+// measured, but the second estimated.
+func greenButtonSeries(id string, start time.Time, hours int) TimeSeries {
+	points := make([]PointResponse, hours)
+	for i := range points {
+		points[i] = PointResponse{Position: i + 1, OutQuantityQuantity: 0.5, OutQuantityQuality: "A04"}
+	}
+	points[1].OutQuantityQuantity = 0.1234
+	points[1].OutQuantityQuality = "A03"
+
+	var ts TimeSeries
+	ts.Success = true
+	ts.MyEnergyDataMarketDocument.CreatedDateTime = time.Date(2025, 3, 12, 6, 0, 0, 0, time.UTC)
+	ts.MyEnergyDataMarketDocument.TimeSeries = []TimeSeriesTimeSeriesResponse{{
+		MRID:                id,
+		MeasurementUnitName: "KWH",
+		Periods: []PeriodResponse{{
+			Resolution:   "PT1H",
+			TimeInterval: TimeInterval{Start: start.UTC(), End: start.Add(time.Duration(hours) * time.Hour).UTC()},
+			Points:       points,
+		}},
+	}}
+	return ts
+}
+
+// greenButtonDocument is a Green Button feed as a reader without the writer's types sees
+// it, matching elements by their namespace.
+type greenButtonDocument struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Updated string   `xml:"updated"`
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Content struct {
+			UsagePoint *struct {
+				Kind int `xml:"ServiceCategory>kind"`
+			} `xml:"http://naesb.org/espi UsagePoint"`
+			LocalTimeParameters *struct {
+				DSTStartRule string `xml:"dstStartRule"`
+				DSTEndRule   string `xml:"dstEndRule"`
+				TZOffset     int    `xml:"tzOffset"`
+			} `xml:"http://naesb.org/espi LocalTimeParameters"`
+			ReadingType *struct {
+				FlowDirection  int `xml:"flowDirection"`
+				IntervalLength int `xml:"intervalLength"`
+				UOM            int `xml:"uom"`
+			} `xml:"http://naesb.org/espi ReadingType"`
+			IntervalBlock *struct {
+				Interval struct {
+					Duration int64 `xml:"duration"`
+					Start    int64 `xml:"start"`
+				} `xml:"interval"`
+				Readings []struct {
+					Quality int   `xml:"ReadingQuality>quality"`
+					Start   int64 `xml:"timePeriod>start"`
+					Value   int64 `xml:"value"`
+				} `xml:"IntervalReading"`
+			} `xml:"http://naesb.org/espi IntervalBlock"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+func TestWriteGreenButton(t *testing.T) {
+	const id = "571313180100000001"
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, cph)
+	details := []MeteringPointDetail{{
+		MeteringPointID: id,
+		TypeOfMP:        "E18",
+		StreetName:      "Vestergade",
+		BuildingNumber:  "12",
+		Postcode:        "8000",
+		CityName:        "Aarhus C",
+	}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGreenButton(&buf, []TimeSeries{greenButtonSeries(id, start, 48)}, details))
+	assert.Contains(t, buf.String(), `<UsagePoint xmlns="http://naesb.org/espi">`)
+
+	var doc greenButtonDocument
+	if !assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc)) {
+		return
+	}
+	assert.Equal(t, "2025-03-12T06:00:00Z", doc.Updated, "as recent as the data")
+
+	// LocalTimeParameters, UsagePoint, MeterReading, ReadingType and a block per day
+	if !assert.Len(t, doc.Entries, 6) {
+		return
+	}
+
+	local := doc.Entries[0].Content.LocalTimeParameters
+	if assert.NotNil(t, local) {
+		assert.Equal(t, "3C0E2000", local.DSTStartRule)
+		assert.Equal(t, "AC0E3000", local.DSTEndRule)
+		assert.Equal(t, 3600, local.TZOffset)
+	}
+
+	assert.NotNil(t, doc.Entries[1].Content.UsagePoint)
+	assert.Equal(t, "Vestergade 12, 8000 Aarhus C", doc.Entries[1].Title)
+
+	readingType := doc.Entries[3].Content.ReadingType
+	if assert.NotNil(t, readingType) {
+		assert.Equal(t, 19, readingType.FlowDirection, "a production metering point is read in reverse")
+		assert.Equal(t, 3600, readingType.IntervalLength)
+		assert.Equal(t, 72, readingType.UOM)
+	}
+
+	first, second := doc.Entries[4].Content.IntervalBlock, doc.Entries[5].Content.IntervalBlock
+	if assert.NotNil(t, first) && assert.NotNil(t, second) {
+		assert.Equal(t, start.Unix(), first.Interval.Start)
+		assert.Equal(t, int64(24*3600), first.Interval.Duration)
+		assert.Len(t, first.Readings, 24)
+		assert.Equal(t, start.AddDate(0, 0, 1).Unix(), second.Interval.Start)
+
+		assert.Equal(t, int64(500), first.Readings[0].Value, "kWh are written as Wh")
+		assert.Equal(t, 0, first.Readings[0].Quality)
+		assert.Equal(t, int64(123), first.Readings[1].Value)
+		assert.Equal(t, 8, first.Readings[1].Quality, "an estimate")
+	}
+
+	t.Run("the same data gives the same feed", func(t *testing.T) {
+		var again bytes.Buffer
+		assert.NoError(t, WriteGreenButton(&again, []TimeSeries{greenButtonSeries(id, start, 48)}, details))
+		assert.Equal(t, buf.String(), again.String())
+	})
+
+	t.Run("without details", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, WriteGreenButton(&buf, []TimeSeries{greenButtonSeries(id, start, 24)}, nil))
+
+		var doc greenButtonDocument
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+		assert.Equal(t, id, doc.Entries[1].Title)
+		assert.Equal(t, 1, doc.Entries[3].Content.ReadingType.FlowDirection)
+	})
+
+	t.Run("a failed time series is an error", func(t *testing.T) {
+		failed := TimeSeries{StatusResponse: StatusResponse{ID: id, ErrorCode: 20008, ErrorText: "MeteringPointNotFound"}}
+		err := WriteGreenButton(&bytes.Buffer{}, []TimeSeries{failed}, nil)
+		assert.ErrorContains(t, err, "metering point "+id)
+	})
+
+	t.Run("an unknown unit is an error", func(t *testing.T) {
+		ts := greenButtonSeries(id, start, 2)
+		ts.MyEnergyDataMarketDocument.TimeSeries[0].MeasurementUnitName = "KVARH"
+		err := WriteGreenButton(&bytes.Buffer{}, []TimeSeries{ts}, nil)
+		assert.ErrorContains(t, err, "cannot write unit 'KVARH'")
+	})
+}
+
+func TestGreenButtonQuality(t *testing.T) {
+	for quality, want := range map[string]int{"A04": 0, "A01": 7, "A03": 8, "A02": 10, "A05": 10, "": 13, "E01": 16} {
+		assert.Equal(t, want, greenButtonQuality(quality), quality)
+	}
+}
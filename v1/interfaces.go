@@ -11,6 +11,8 @@ type Client interface {
 	DataAccessTokenClaims() (TokenClaims, error)
 	GetMeteringPointDetails(meteringPointIDs []string) ([]MeteringPointDetailsResponse, error)
 	GetTimeSeries(meteringPointIDs []string, from, to time.Time, aggregation Aggregation) ([]TimeSeries, error)
+	StreamTimeSeries(meteringPointIDs []string, from, to time.Time, aggregation Aggregation) (*TimeSeriesStream, error)
+	GetMeterReadings(meteringPointIDs []string, from, to time.Time) ([]MeterReadingsResponse, error)
 	GetChargeLinksWithCharges(meteringPointIDs []string, from, to time.Time) (*ChargeLinksWithChargesResponse, error)
 	IsAlive() (bool, error)
 }
@@ -176,6 +176,37 @@ func (c *client) RefreshTokenClaims() (TokenClaims, error) {
 	return ParseToken(c.refreshToken)
 }
 
+// tokenExpiryCheckInterval is how often a client checks its refresh token for the
+// WithTokenExpiryWarning hook.
+const tokenExpiryCheckInterval = 24 * time.Hour
+
+// checkTokenExpiry calls the WithTokenExpiryWarning hook when the refresh token expires
+// within the threshold, checking at most once per tokenExpiryCheckInterval. A token
+// without readable claims is left for the API to judge.
+func (c *client) checkTokenExpiry() {
+	if c.onExpiry == nil {
+		return
+	}
+
+	c.tokenMu.Lock()
+	due := c.expiryCheckedAt.IsZero() || time.Since(c.expiryCheckedAt) >= tokenExpiryCheckInterval
+	if due {
+		c.expiryCheckedAt = time.Now()
+	}
+	c.tokenMu.Unlock()
+	if !due {
+		return
+	}
+
+	claims, err := ParseToken(c.refreshToken)
+	if err != nil || claims.ExpiresAt.IsZero() {
+		return
+	}
+	if claims.IsExpired() || claims.ExpiresIn() < c.expiryThreshold {
+		c.onExpiry(claims)
+	}
+}
+
 // DataAccessTokenClaims decodes the claims of the client's data access token, fetching
 // one first if the client does not hold one yet.
 func (c *client) DataAccessTokenClaims() (TokenClaims, error) {
@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -159,3 +161,83 @@ func TestClientTokenClaims(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestWithTokenExpiryWarning(t *testing.T) {
+	mockResty := resty.New()
+	httpmock.ActivateNonDefault(mockResty.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "/token", httpmock.NewJsonResponderOrPanic(200, map[string]string{"result": "access-token"}))
+
+	// newClient returns a client for the refresh token, recording every time the hook fires.
+	newClient := func(refreshToken string, fired *[]TokenClaims) *client {
+		c := &client{refreshToken: refreshToken, resty: mockResty}
+		WithTokenExpiryWarning(30*24*time.Hour, func(claims TokenClaims) {
+			*fired = append(*fired, claims)
+		})(c)
+		return c
+	}
+	expiringIn := func(d time.Duration) string {
+		return testToken(t, map[string]any{
+			"tokenType": "CUSTOMERAPI_Refresh",
+			"tokenName": "pipeline",
+			"exp":       time.Now().Add(d).Unix(),
+		})
+	}
+
+	t.Run("fires when the refresh token is close to expiry", func(t *testing.T) {
+		var fired []TokenClaims
+		c := newClient(expiringIn(10*24*time.Hour), &fired)
+
+		_, err := c.GetDataAccessToken()
+
+		assert.NoError(t, err)
+		assert.Len(t, fired, 1)
+		assert.Equal(t, "pipeline", fired[0].TokenName)
+	})
+
+	t.Run("fires when the refresh token has expired", func(t *testing.T) {
+		var fired []TokenClaims
+		c := newClient(expiringIn(-time.Hour), &fired)
+
+		_, _ = c.GetDataAccessToken()
+
+		assert.Len(t, fired, 1)
+		assert.True(t, fired[0].IsExpired())
+	})
+
+	t.Run("stays quiet while the token is far from expiry", func(t *testing.T) {
+		var fired []TokenClaims
+		c := newClient(expiringIn(200*24*time.Hour), &fired)
+
+		_, err := c.GetDataAccessToken()
+
+		assert.NoError(t, err)
+		assert.Empty(t, fired)
+	})
+
+	t.Run("checks again a day later on the same client", func(t *testing.T) {
+		var fired []TokenClaims
+		c := newClient(expiringIn(10*24*time.Hour), &fired)
+
+		_, _ = c.GetDataAccessToken()
+		_, _ = c.GetDataAccessToken()
+		assert.Len(t, fired, 1, "once a day, not on every call")
+
+		c.expiryCheckedAt = c.expiryCheckedAt.Add(-tokenExpiryCheckInterval)
+		_, err := c.GetDataAccessToken()
+
+		assert.NoError(t, err)
+		assert.Len(t, fired, 2, "the cached data access token does not stop the check")
+	})
+
+	t.Run("stays quiet for a token without claims", func(t *testing.T) {
+		var fired []TokenClaims
+		c := newClient("not-a-jwt", &fired)
+
+		_, err := c.GetDataAccessToken()
+
+		assert.NoError(t, err)
+		assert.Empty(t, fired)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 type MeteringPoints struct {
@@ -107,6 +108,17 @@ type MeteringPointDetail struct {
 	ChildMeteringPoints             []ChildMeteringPoint `json:"childMeteringPoints"`
 }
 
+// Address formats the address of the metering point on a single line, e.g.
+// "Vestergade 12, 8000 Aarhus C". It is empty when the details carry none.
+func (d MeteringPointDetail) Address() string {
+	street := strings.TrimSpace(d.StreetName + " " + d.BuildingNumber)
+	city := strings.TrimSpace(d.Postcode + " " + d.CityName)
+	if street == "" || city == "" {
+		return street + city
+	}
+	return street + ", " + city
+}
+
 type ContactAddress struct {
 	ContactName1        string `json:"contactName1"`
 	ContactName2        string `json:"contactName2"`
@@ -193,6 +205,7 @@ func (c *client) GetMeteringPointDetails(meteringPointIDs []string) ([]MeteringP
 	var apiErrBody apiErrorBody
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetResult(&result).
@@ -206,7 +219,7 @@ func (c *client) GetMeteringPointDetails(meteringPointIDs []string) ([]MeteringP
 		return nil, err
 	}
 
-	return result.Result, nil
+	return partialResult(c, result.Result)
 }
 
 func (c *client) ExportMasterdata(meteringPointIDs []string) (io.ReadCloser, error) {
@@ -220,6 +233,7 @@ func (c *client) ExportMasterdata(meteringPointIDs []string) (io.ReadCloser, err
 	}
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetDoNotParseResponse(true).
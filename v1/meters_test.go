@@ -424,6 +424,16 @@ func TestGetMeteringPointDetailsNullPowerLimit(t *testing.T) {
 	assert.True(t, details[0].Result.Occurrence.IsZero(), "an empty occurrence must decode to the zero time")
 }
 
+func TestMeteringPointDetailAddress(t *testing.T) {
+	assert.Equal(t, "Vestergade 12, 8000 Aarhus C", MeteringPointDetail{
+		StreetName: "Vestergade", BuildingNumber: "12", Postcode: "8000", CityName: "Aarhus C",
+	}.Address())
+	assert.Equal(t, "Vestergade 12", MeteringPointDetail{StreetName: "Vestergade", BuildingNumber: "12"}.Address())
+	assert.Equal(t, "8000 Aarhus C", MeteringPointDetail{Postcode: "8000", CityName: "Aarhus C"}.Address())
+	assert.Equal(t, "Aarhus C", MeteringPointDetail{CityName: "Aarhus C"}.Address())
+	assert.Equal(t, "", MeteringPointDetail{}.Address())
+}
+
 func TestExportMasterdata(t *testing.T) {
 	mockResty := resty.New()
 	httpmock.ActivateNonDefault(mockResty.GetClient())
@@ -465,3 +475,39 @@ func TestExportMasterdata(t *testing.T) {
 		assert.Contains(t, err.Error(), "only available for Customer API")
 	})
 }
+
+func TestWithPartialResultErrors(t *testing.T) {
+	mockResty := resty.New()
+	httpmock.ActivateNonDefault(mockResty.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "/meteringpoints/meteringpoint/getdetails", httpmock.NewJsonResponderOrPanic(200, map[string]any{
+		"result": []map[string]any{
+			{"result": map[string]any{"meteringPointId": "571313180100000001"}, "success": true, "errorCode": 10000, "id": "571313180100000001"},
+			{"result": nil, "success": false, "errorCode": 20008, "errorText": "MeteringPointNotFound", "id": "571313180100000002"},
+		},
+	}))
+	ids := []string{"571313180100000001", "571313180100000002"}
+
+	t.Run("without the option every item is returned", func(t *testing.T) {
+		c := &client{accessToken: "test-access-token", resty: mockResty, apiType: CustomerApi}
+
+		details, err := c.GetMeteringPointDetails(ids)
+		assert.NoError(t, err)
+		assert.Len(t, details, 2)
+	})
+
+	t.Run("with the option failed items become a PartialResultError", func(t *testing.T) {
+		c := &client{accessToken: "test-access-token", resty: mockResty, apiType: CustomerApi}
+		WithPartialResultErrors()(c)
+
+		details, err := c.GetMeteringPointDetails(ids)
+		assert.ErrorIs(t, err, ErrorMeteringPointNotFound)
+		assert.Len(t, details, 1)
+		assert.Equal(t, "571313180100000001", details[0].Result.MeteringPointID)
+
+		var partial *PartialResultError
+		assert.ErrorAs(t, err, &partial)
+		assert.Equal(t, "571313180100000002", partial.Failures[0].MeteringPointID)
+	})
+}
@@ -1,6 +1,7 @@
 package eloverblik
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -37,6 +38,11 @@ const (
 // returned to the caller immediately. A Retry-After response header is honoured, capped
 // at maxWait.
 //
+// A request that gets no response at all is retried when the error is transient - a
+// reset connection, a timeout, a failed DNS lookup - and sending the request again is
+// safe: it never reached the API, or it only reads. Adding or deleting a relation is not
+// retried once it may have been sent, as the API may already have acted on it.
+//
 // count is the number of retries after the initial attempt, and is clamped to zero when
 // negative. maxWait falls back to DefaultRetryMaxWait when it is zero or negative, and
 // also caps the base backoff, so a short maxWait makes the whole policy short.
@@ -50,8 +56,8 @@ func WithRetry(count int, maxWait time.Duration) Option {
 	}
 }
 
-// WithoutRetry disables retrying: every response, including 429 and 503, is returned to
-// the caller as it arrives.
+// WithoutRetry disables retrying: every response, including 429 and 503, and every
+// transport error is returned to the caller as it arrives.
 //
 // Example:
 //
@@ -62,9 +68,91 @@ func WithoutRetry() Option {
 	}
 }
 
-// setRetryPolicy configures retrying on a resty client. It is idempotent: the retry
-// condition is assigned rather than appended, so calling it again from an option
-// replaces the policy instead of stacking a second condition on top of it.
+// WithRetryMaxElapsed bounds the total time a call spends on retries. A retry is not
+// started when the time since the first attempt plus the wait before it would exceed
+// maxElapsed, and the last error is returned instead. The wait is estimated from the base
+// backoff and any Retry-After header, so a jittered backoff can still overshoot slightly.
+// Zero or negative removes the bound, which is the default: the retry count alone limits
+// a call.
+//
+// Example:
+//
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithRetryMaxElapsed(20*time.Second))
+func WithRetryMaxElapsed(maxElapsed time.Duration) Option {
+	return func(c *client) {
+		c.retryMaxElapsed = max(maxElapsed, 0)
+	}
+}
+
+// RetryEvent describes a failed attempt and what the client decided to do about it. It is
+// passed to the hook set with WithRetryHook.
+type RetryEvent struct {
+	// Method and URL identify the request.
+	Method string
+	URL    string
+	// Attempt is the number of the attempt that failed, starting at 1.
+	Attempt int
+	// StatusCode is the status of the response, or 0 when none arrived.
+	StatusCode int
+	// Err is the transport error when no response arrived.
+	Err error
+	// Elapsed is the time since the first attempt started.
+	Elapsed time.Duration
+	// Retry reports whether the request is sent again.
+	Retry bool
+	// Reason explains the decision, e.g. "rate limited", "connection reset", "not
+	// idempotent" or "retries exhausted".
+	Reason string
+}
+
+// WithRetryHook calls hook for every attempt that fails with an error status or without
+// a response, with the decision whether to retry it. A successful attempt is not
+// reported, and nothing is reported with WithoutRetry. The hook runs on the goroutine
+// making the call, before the wait for the retry, and must be safe for concurrent use
+// when the client is shared.
+//
+// Example:
+//
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithRetryHook(func(e eloverblik.RetryEvent) {
+//		log.Printf("%s %s: attempt %d failed (%s), retry: %t", e.Method, e.URL, e.Attempt, e.Reason, e.Retry)
+//	}))
+func WithRetryHook(hook func(RetryEvent)) Option {
+	return func(c *client) {
+		c.retryHook = hook
+	}
+}
+
+// WithCircuitBreaker guards the client with a circuit breaker, which fails calls at once
+// with a *CircuitOpenError while DataHub is down instead of letting each of them wait out
+// the retry policy. See CircuitBreaker.
+//
+// Example:
+//
+//	breaker := eloverblik.NewCircuitBreaker(eloverblik.DefaultCircuitThreshold, eloverblik.DefaultCircuitCooldown)
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithCircuitBreaker(breaker))
+//
+//	if _, err := customerClient.GetMeteringPoints(false); errors.Is(err, eloverblik.ErrCircuitOpen) {
+//		// DataHub is down; breaker.State() tells how far it is from recovering
+//	}
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *client) {
+		if breaker == nil {
+			return
+		}
+		// The gate reads c.breaker, so passing the option twice keeps the last breaker
+		// without stacking a second gate
+		if c.breaker == nil {
+			c.resty.OnBeforeRequest(c.circuitGate)
+			c.resty.OnAfterResponse(c.circuitResponse)
+			c.resty.OnError(c.circuitError)
+		}
+		c.breaker = breaker
+	}
+}
+
+// setRetryPolicy configures retrying on a resty client. It is idempotent, so calling it
+// again from an option replaces the policy. The retry condition itself belongs to the
+// client, see useRetryCondition.
 func setRetryPolicy(client *resty.Client, count int, maxWait time.Duration) *resty.Client {
 	if count < 0 {
 		count = 0
@@ -79,8 +167,6 @@ func setRetryPolicy(client *resty.Client, count int, maxWait time.Duration) *res
 		wait = maxWait
 	}
 
-	client.RetryConditions = []resty.RetryConditionFunc{retryCondition}
-
 	return client.
 		SetRetryCount(count).
 		SetRetryWaitTime(wait).
@@ -88,12 +174,134 @@ func setRetryPolicy(client *resty.Client, count int, maxWait time.Duration) *res
 		SetRetryAfter(retryAfter)
 }
 
-// retryCondition decides whether resty retries a response. See isRetryableError.
-func retryCondition(res *resty.Response, err error) bool {
-	if res == nil {
+// useRetryCondition installs the client's retry condition on its resty client. The
+// condition is assigned rather than appended, so the client has exactly one.
+func (c *client) useRetryCondition() {
+	c.resty.RetryConditions = []resty.RetryConditionFunc{c.retryCondition}
+}
+
+// retryCondition decides whether resty retries an attempt: retryDecision, bounded by the
+// retry count, WithRetryMaxElapsed and an open circuit, and reported to the
+// WithRetryHook. resty only asks while retrying is enabled.
+func (c *client) retryCondition(res *resty.Response, err error) bool {
+	if res == nil || res.Request == nil || isCircuitProbe(res.Request) || (err == nil && isSuccessStatus(res.StatusCode())) {
 		return false
 	}
-	return isRetryableError(res.StatusCode(), err)
+
+	retry, reason := retryDecision(res, err)
+	elapsed := time.Since(firstAttempt(res.Request))
+
+	switch {
+	case !retry:
+	case res.Request.Attempt > c.resty.RetryCount:
+		retry, reason = false, "retries exhausted"
+	case c.breaker != nil && c.breaker.State() != CircuitClosed:
+		retry, reason = false, "circuit open"
+	case c.retryMaxElapsed > 0 && elapsed+nextRetryWait(c.resty, res) > c.retryMaxElapsed:
+		retry, reason = false, "max elapsed time reached"
+	}
+
+	if c.retryHook != nil {
+		c.retryHook(RetryEvent{
+			Method:     res.Request.Method,
+			URL:        requestURL(res.Request),
+			Attempt:    res.Request.Attempt,
+			StatusCode: res.StatusCode(),
+			Err:        err,
+			Elapsed:    elapsed,
+			Retry:      retry,
+			Reason:     reason,
+		})
+	}
+	return retry
+}
+
+// retryDecision classifies a failed attempt. A response is retried on the transient
+// statuses of isRetryableError. An attempt without a response is retried when its error
+// is transient and the request either never reached the API or is idempotent.
+func retryDecision(res *resty.Response, err error) (bool, string) {
+	if res == nil {
+		return false, "no response"
+	}
+
+	if err == nil {
+		switch status := res.StatusCode(); {
+		case !isRetryableError(status, nil):
+			return false, fmt.Sprintf("status %d is not transient", status)
+		case status == http.StatusTooManyRequests:
+			return true, "rate limited"
+		default:
+			return true, "service unavailable"
+		}
+	}
+
+	transient, sent, reason := transportError(err)
+	if transient && sent && (res.Request == nil || !isIdempotent(res.Request)) {
+		return false, reason + ", not idempotent"
+	}
+	return transient, reason
+}
+
+// readOnlyKey is the context key readOnly marks a request with.
+type readOnlyKey struct{}
+
+// readOnly is the context of a POST that only reads, such as a query or an export of the
+// metering points in its body. Only a request sent with it is retried as a GET is.
+var readOnly = context.WithValue(context.Background(), readOnlyKey{}, true)
+
+// isIdempotent reports whether sending a request twice is harmless. The GETs only read,
+// and so do the POSTs marked with readOnly. Adding a relation is a POST or PUT and
+// deleting one a DELETE: a retry of either after the API acted on the first attempt would
+// fail, or act twice. A POST that is not marked is never taken to be safe.
+func isIdempotent(req *resty.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		marked, _ := req.Context().Value(readOnlyKey{}).(bool)
+		return marked
+	}
+	return false
+}
+
+// requestURL returns the URL a request was sent to, or the path it was created with when
+// it never got that far.
+func requestURL(req *resty.Request) string {
+	if req.RawRequest != nil && req.RawRequest.URL != nil {
+		return req.RawRequest.URL.String()
+	}
+	return req.URL
+}
+
+// attemptStartKey is the context key markFirstAttempt stores the start of a call under.
+type attemptStartKey struct{}
+
+// markFirstAttempt is request middleware that remembers when the first attempt of a call
+// started, which WithRetryMaxElapsed measures from.
+func markFirstAttempt(_ *resty.Client, req *resty.Request) error {
+	if req.Attempt <= 1 {
+		req.SetContext(context.WithValue(req.Context(), attemptStartKey{}, time.Now()))
+	}
+	return nil
+}
+
+// firstAttempt returns when the first attempt of a call started, as recorded by
+// markFirstAttempt, or the start of the current attempt for a request it did not see.
+func firstAttempt(req *resty.Request) time.Time {
+	if start, ok := req.Context().Value(attemptStartKey{}).(time.Time); ok {
+		return start
+	}
+	return req.Time
+}
+
+// nextRetryWait estimates the wait before the next attempt: the base backoff, or the wait
+// a Retry-After header asks for when that is longer, both capped like resty caps them.
+func nextRetryWait(client *resty.Client, res *resty.Response) time.Duration {
+	wait := client.RetryWaitTime
+	if res.RawResponse != nil {
+		wait = max(wait, parseRetryAfter(res.Header().Get("Retry-After")))
+	}
+	return min(wait, client.RetryMaxWaitTime)
 }
 
 // retryAfter honours the Retry-After response header the API can send along with 429 and
@@ -131,6 +339,56 @@ func parseRetryAfter(value string) time.Duration {
 	return 0
 }
 
+// WithPreprod points the client at Energinet's pre-production API on
+// apipreprod.eloverblik.dk instead of the production API. Tokens are issued per
+// environment: a production refresh token is rejected by pre-production, and vice versa.
+//
+// Example:
+//
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithPreprod())
+func WithPreprod() Option {
+	return func(c *client) {
+		c.resty.SetBaseURL(strings.Replace(c.resty.BaseURL, "://"+prodModeHost+"/", "://"+testModeHost+"/", 1))
+	}
+}
+
+// WithTokenExpiryWarning calls fn with the refresh token's claims when the refresh token
+// expires within threshold, or has already expired. A refresh token lasts about a year and
+// has to be replaced by hand in the Eloverblik portal, so this is the place to alert
+// someone before a pipeline stops.
+//
+// The client checks on its first call and then on the first call of every day after, so a
+// long running client keeps warning once a day. fn runs before the call, on the calling
+// goroutine, and should return quickly. A refresh token whose claims cannot be read never
+// fires it.
+//
+// Example:
+//
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithTokenExpiryWarning(30*24*time.Hour, func(claims eloverblik.TokenClaims) {
+//		log.Printf("refresh token %q expires %s", claims.TokenName, claims.ExpiresAt)
+//	}))
+func WithTokenExpiryWarning(threshold time.Duration, fn func(TokenClaims)) Option {
+	return func(c *client) {
+		c.expiryThreshold = threshold
+		c.onExpiry = fn
+	}
+}
+
+// WithPartialResultErrors makes the calls that return an item per metering point -
+// GetMeteringPointDetails, GetCustomerCharges, GetThirdPartyCharges, GetTimeSeries and
+// GetMeterReadings - return only the items that succeeded, together with a
+// *PartialResultError listing the metering points that failed. Without it the failed
+// items are returned alongside the others, and the caller has to check every Success.
+//
+// Example:
+//
+//	customerClient := eloverblik.NewCustomer(refreshToken, eloverblik.WithPartialResultErrors())
+func WithPartialResultErrors() Option {
+	return func(c *client) {
+		c.partialResultErrors = true
+	}
+}
+
 // WithResponseHeaderOutput writes the HTTP response headers of every API call to w.
 //
 // It is intended for debugging. Headers are written as one block per response,
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -398,9 +401,11 @@ func TestRetryAfterHeader(t *testing.T) {
 	})
 }
 
-func TestRetryCondition(t *testing.T) {
+func TestRetryDecision(t *testing.T) {
 	t.Run("no response is not retried", func(t *testing.T) {
-		assert.False(t, retryCondition(nil, nil))
+		retry, reason := retryDecision(nil, nil)
+		assert.False(t, retry)
+		assert.Equal(t, "no response", reason)
 	})
 
 	t.Run("retries the transient statuses only", func(t *testing.T) {
@@ -414,7 +419,193 @@ func TestRetryCondition(t *testing.T) {
 			http.StatusServiceUnavailable:  true,
 		} {
 			res := &resty.Response{RawResponse: &http.Response{StatusCode: status, Header: http.Header{}}}
-			assert.Equal(t, expected, retryCondition(res, nil), "status %d", status)
+			retry, _ := retryDecision(res, nil)
+			assert.Equal(t, expected, retry, "status %d", status)
 		}
 	})
 }
+
+func TestIsIdempotent(t *testing.T) {
+	request := func(method, url string) *resty.Request {
+		req := resty.New().R()
+		req.Method, req.URL = method, url
+		return req
+	}
+
+	assert.True(t, isIdempotent(request(http.MethodGet, "/meteringpoints/meteringpoints")))
+	assert.True(t, isIdempotent(request(http.MethodPost, "/meteringpoints/meteringpoint/getdetails").SetContext(readOnly)))
+	assert.False(t, isIdempotent(request(http.MethodPost, "/meteringpoints/getrelations/add")), "a POST is not read-only by its path")
+	assert.False(t, isIdempotent(request(http.MethodPost, "/meteringpoints/masterdata/export")), "unmarked")
+	assert.False(t, isIdempotent(request(http.MethodPut, "/meteringpoints/meteringpoint/relation/add/1/2").SetContext(readOnly)))
+}
+
+// TestRetryTransportErrors covers requests that get no response: a transient error is
+// retried when the request never reached the API or only reads, and never when it may
+// have added or deleted a relation.
+func TestRetryTransportErrors(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	notFound := &net.DNSError{Err: "no such host", Name: "api.eloverblik.dk", IsNotFound: true}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		err           error
+		call          func(c *client) error
+		expectedCalls int
+	}{
+		{
+			name:          "a GET is retried after a reset",
+			method:        http.MethodGet,
+			path:          "/MeteringPoints/MeteringPoints",
+			err:           reset,
+			call:          func(c *client) error { _, err := c.GetMeteringPoints(false); return err },
+			expectedCalls: 3,
+		},
+		{
+			name:          "a read-only POST is retried after a reset",
+			method:        http.MethodPost,
+			path:          "/meteringpoints/meteringpoint/getdetails",
+			err:           reset,
+			call:          func(c *client) error { _, err := c.GetMeteringPointDetails([]string{"571313180100000001"}); return err },
+			expectedCalls: 3,
+		},
+		{
+			name:          "adding a relation is not retried after a reset",
+			method:        http.MethodPost,
+			path:          "/meteringpoints/meteringpoint/relation/add",
+			err:           reset,
+			call:          func(c *client) error { _, err := c.AddRelationByID([]string{"571313180100000001"}); return err },
+			expectedCalls: 1,
+		},
+		{
+			name:   "adding a relation by web access code is not retried after a timeout",
+			method: http.MethodPut,
+			path:   "/meteringpoints/meteringpoint/relation/add/571313180100000001/12345678",
+			err:    &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			call: func(c *client) error {
+				_, err := c.AddRelationByWebAccessCode("571313180100000001", "12345678")
+				return err
+			},
+			expectedCalls: 1,
+		},
+		{
+			name:          "deleting a relation is not retried after a reset",
+			method:        http.MethodDelete,
+			path:          "/meteringpoints/meteringpoint/relation/571313180100000001",
+			err:           reset,
+			call:          func(c *client) error { _, err := c.DeleteRelation("571313180100000001"); return err },
+			expectedCalls: 1,
+		},
+		{
+			name:          "adding a relation is retried when the connection was refused",
+			method:        http.MethodPost,
+			path:          "/meteringpoints/meteringpoint/relation/add",
+			err:           refused,
+			call:          func(c *client) error { _, err := c.AddRelationByID([]string{"571313180100000001"}); return err },
+			expectedCalls: 3,
+		},
+		{
+			name:          "an unknown host is not retried",
+			method:        http.MethodGet,
+			path:          "/MeteringPoints/MeteringPoints",
+			err:           notFound,
+			call:          func(c *client) error { _, err := c.GetMeteringPoints(false); return err },
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockedCustomer(t, WithRetry(DefaultRetryCount, testRetryWait))
+			httpmock.RegisterResponder("GET", tokenURL(c), tokenResponder(http.StatusOK))
+
+			var calls int
+			httpmock.RegisterResponder(tt.method, c.resty.BaseURL+tt.path,
+				func(req *http.Request) (*http.Response, error) {
+					calls++
+					return nil, tt.err
+				})
+
+			err := tt.call(c)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expectedCalls, calls)
+		})
+	}
+}
+
+func TestWithRetryMaxElapsed(t *testing.T) {
+	run := func(t *testing.T, maxElapsed time.Duration) (int, []RetryEvent) {
+		t.Helper()
+
+		var events []RetryEvent
+		c := newMockedCustomer(t,
+			WithRetry(DefaultRetryCount, testRetryWait),
+			WithRetryMaxElapsed(maxElapsed),
+			WithRetryHook(func(e RetryEvent) { events = append(events, e) }))
+		httpmock.RegisterResponder("GET", tokenURL(c), tokenResponder(http.StatusServiceUnavailable))
+
+		_, err := c.GetDataAccessToken()
+		assert.Error(t, err)
+		return httpmock.GetTotalCallCount(), events
+	}
+
+	t.Run("no retry beyond the budget", func(t *testing.T) {
+		calls, events := run(t, time.Nanosecond)
+		assert.Equal(t, 1, calls)
+		if assert.Len(t, events, 1) {
+			assert.False(t, events[0].Retry)
+			assert.Equal(t, "max elapsed time reached", events[0].Reason)
+		}
+	})
+
+	t.Run("a generous budget leaves the retry count in charge", func(t *testing.T) {
+		calls, _ := run(t, time.Hour)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("zero means no bound", func(t *testing.T) {
+		calls, _ := run(t, 0)
+		assert.Equal(t, 3, calls)
+	})
+}
+
+func TestWithRetryHook(t *testing.T) {
+	var events []RetryEvent
+	c := newMockedCustomer(t,
+		WithRetry(1, testRetryWait),
+		WithRetryHook(func(e RetryEvent) { events = append(events, e) }))
+	httpmock.RegisterResponder("GET", tokenURL(c), tokenResponder(http.StatusTooManyRequests))
+
+	_, err := c.GetDataAccessToken()
+	assert.Equal(t, ErrorTooManyRequests, err)
+
+	if assert.Len(t, events, 2, "one event per failed attempt") {
+		assert.Equal(t, RetryEvent{
+			Method: http.MethodGet, URL: tokenURL(c), Attempt: 1, StatusCode: http.StatusTooManyRequests,
+			Elapsed: events[0].Elapsed, Retry: true, Reason: "rate limited",
+		}, events[0])
+		assert.Equal(t, 2, events[1].Attempt)
+		assert.False(t, events[1].Retry)
+		assert.Equal(t, "retries exhausted", events[1].Reason)
+	}
+
+	t.Run("a success is not reported", func(t *testing.T) {
+		events = nil
+		c := newMockedCustomer(t, WithRetryHook(func(e RetryEvent) { events = append(events, e) }))
+		httpmock.RegisterResponder("GET", tokenURL(c), tokenResponder(http.StatusOK))
+
+		_, err := c.GetDataAccessToken()
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestWithPreprod(t *testing.T) {
+	customer := NewCustomer("test-refresh-token", WithPreprod()).(*client)
+	assert.Equal(t, "https://"+testModeHost+"/customerapi/api", customer.resty.BaseURL)
+
+	thirdParty := NewThirdParty("test-refresh-token", WithPreprod()).(*client)
+	assert.Equal(t, "https://"+testModeHost+"/thirdpartyapi/api", thirdParty.resty.BaseURL)
+}
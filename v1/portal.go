@@ -0,0 +1,505 @@
+package eloverblik
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrPortalColumns is returned when a portal download lacks the columns of the kind it
+// is read as, such as a masterdata file read as time series.
+var ErrPortalColumns = errors.New("missing columns")
+
+// portalHeaderRows is how far into a file the header row is looked for. The xlsx
+// downloads may carry a title above it.
+const portalHeaderRows = 10
+
+// portalTimeSeriesColumns are the names the columns of a time series download go by,
+// normalised with portalColumn. Måleenhed and Kvalitet are optional.
+var portalTimeSeriesColumns = map[string][]string{
+	"id":       {"målepunktsid", "målepunktid", "målepunkt", "meteringpointid"},
+	"from":     {"fradato", "fra", "from"},
+	"to":       {"tildato", "til", "to"},
+	"quantity": {"mængde", "forbrug", "measurement", "quantity"},
+	"unit":     {"måleenhed", "enhed", "unit"},
+	"quality":  {"kvalitet", "quality"},
+}
+
+// portalQualities maps the quality the portal spells out to the code the API sends.
+var portalQualities = map[string]string{
+	"målt":            "A04",
+	"estimeret":       "A03",
+	"justeret":        "A01",
+	"ikketilgængelig": "A02",
+	"ufuldstændig":    "A05",
+}
+
+// portalDetailColumns maps the Danish column names of a masterdata download to the json
+// name of the MeteringPointDetail field they fill. A column named after the json name
+// itself fills the field as well.
+var portalDetailColumns = map[string]string{
+	"målepunktsid":          "meteringPointId",
+	"målepunktid":           "meteringPointId",
+	"målepunkt":             "meteringPointId",
+	"overordnetmålepunkt":   "parentMeteringPointId",
+	"type":                  "typeOfMP",
+	"målepunktstype":        "typeOfMP",
+	"måleenhed":             "energyTimeSeriesMeasureUnit",
+	"enhed":                 "energyTimeSeriesMeasureUnit",
+	"forventetårsforbrug":   "estimatedAnnualVolume",
+	"afregningsform":        "settlementMethod",
+	"målernummer":           "meterNumber",
+	"netselskab":            "gridOperatorName",
+	"netvirksomhed":         "gridOperatorName",
+	"netområde":             "meteringGridAreaIdentification",
+	"nettoafregningsgruppe": "netSettlementGroup",
+	"fysiskstatus":          "physicalStatusOfMP",
+	"aflæsningsfrekvens":    "meterReadingOccurrence",
+	"elleverandør":          "balanceSupplierName",
+	"leverandør":            "balanceSupplierName",
+	"vejkode":               "streetCode",
+	"vejnavn":               "streetName",
+	"husnummer":             "buildingNumber",
+	"husnr":                 "buildingNumber",
+	"etage":                 "floorId",
+	"dør":                   "roomId",
+	"side":                  "roomId",
+	"postnummer":            "postcode",
+	"postnr":                "postcode",
+	"by":                    "cityName",
+	"bynavn":                "cityName",
+	"bydel":                 "citySubDivisionName",
+	"kommunekode":           "municipalityCode",
+	"alias":                 "meteringPointAlias",
+	"kundenavn":             "firstConsumerPartyName",
+	"cvr":                   "consumerCVR",
+	"cvrnummer":             "consumerCVR",
+}
+
+// portalTypes maps the type of metering point the portal spells out to its code.
+var portalTypes = map[string]string{
+	"forbrug":    "E17",
+	"produktion": "E18",
+	"udveksling": "E20",
+}
+
+// ReadPortalTimeSeries reads a time series downloaded from the eloverblik.dk portal,
+// either the semicolon separated CSV or the xlsx variant, which it tells apart by
+// content. It returns one TimeSeries per metering point in the order they first appear,
+// shaped like the API's, so Flatten and the writers treat the two alike.
+//
+// Columns are found by name, ignoring case, spaces and underscores: MålepunktsID,
+// Fra_dato, Til_dato and Mængde are required; Måleenhed and Kvalitet are optional, with
+// the unit defaulting to KWH. Times are Danish local time, as dd-mm-yyyy hh:mm:ss or the
+// date cells of a spreadsheet; the hour repeated when daylight saving time ends is told
+// apart by the order of the rows. Quantities of a semicolon separated CSV are Danish, with
+// a decimal comma and periods between the thousands; those of a CSV separated by commas
+// and of a spreadsheet take a decimal point. Qualities spelled out (Målt, Estimeret, ...)
+// are mapped to the codes the API sends.
+//
+// The resolution of a point is the length of its interval, which must be a quarter, an
+// hour, a day, a month or a year. A file lacking a required column gives an error
+// wrapping ErrPortalColumns.
+func ReadPortalTimeSeries(r io.Reader) ([]TimeSeries, error) {
+	rows, danish, err := readPortalRows(r)
+	if err != nil {
+		return nil, err
+	}
+	header, columns, err := portalHeader(rows, portalTimeSeriesColumns, "id", "from", "to", "quantity")
+	if err != nil {
+		return nil, fmt.Errorf("not a time series download: %w", err)
+	}
+
+	var order []string
+	series := map[string]*TimeSeries{}
+	last := map[string]time.Time{}
+
+	for i, row := range rows[header+1:] {
+		line := header + i + 2
+		cell := func(column string) string {
+			if c, ok := columns[column]; ok && c < len(row) {
+				return strings.TrimSpace(row[c])
+			}
+			return ""
+		}
+		id := cell("id")
+		if id == "" {
+			continue
+		}
+
+		from, to, resolution, err := portalInterval(cell("from"), cell("to"), last[id])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		quantity, err := parsePortalQuantity(cell("quantity"), danish)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		last[id] = to
+
+		ts, ok := series[id]
+		if !ok {
+			ts = &TimeSeries{StatusResponse: StatusResponse{Success: true, ID: id}}
+			series[id] = ts
+			order = append(order, id)
+		}
+		appendPortalPoint(ts, id, portalUnit(cell("unit")), resolution, from, to, quantity, portalQuality(cell("quality")))
+	}
+
+	result := make([]TimeSeries, 0, len(order))
+	for _, id := range order {
+		result = append(result, *series[id])
+	}
+	return result, nil
+}
+
+// ReadPortalMasterdata reads the masterdata of metering points downloaded from the
+// eloverblik.dk portal, either as CSV or xlsx, into one MeteringPointDetail per row.
+//
+// Columns are found by name, ignoring case, spaces and underscores: a column named
+// after the json name of a field, e.g. meteringPointId, fills that field, and so do the
+// Danish names of the portal, e.g. MålepunktsID, Vejnavn or Netselskab. The metering
+// point ID is required; columns that match no field are ignored. A type of metering
+// point spelled out (Forbrug, Produktion) is mapped to its code. A file lacking the ID
+// column gives an error wrapping ErrPortalColumns.
+func ReadPortalMasterdata(r io.Reader) ([]MeteringPointDetail, error) {
+	rows, _, err := readPortalRows(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the fields by their normalised json name, then add the Danish names.
+	detail := reflect.TypeOf(MeteringPointDetail{})
+	fields := map[string][]string{}
+	byJSON := map[string]int{}
+	for i := range detail.NumField() {
+		name, _, _ := strings.Cut(detail.Field(i).Tag.Get("json"), ",")
+		byJSON[name] = i
+		fields[name] = append(fields[name], portalColumn(name))
+	}
+	for column, name := range portalDetailColumns {
+		fields[name] = append(fields[name], column)
+	}
+
+	header, columns, err := portalHeader(rows, fields, "meteringPointId")
+	if err != nil {
+		return nil, fmt.Errorf("not a masterdata download: %w", err)
+	}
+
+	var details []MeteringPointDetail
+	for i, row := range rows[header+1:] {
+		var d MeteringPointDetail
+		value := reflect.ValueOf(&d).Elem()
+		for name, c := range columns {
+			if c >= len(row) {
+				continue
+			}
+			cell := strings.TrimSpace(row[c])
+			if cell == "" {
+				continue
+			}
+			field := value.Field(byJSON[name])
+			switch field.Interface().(type) {
+			case string:
+				field.SetString(cell)
+			case FlexibleTime:
+				t, err := parsePortalTime(cell)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", header+i+2, name, err)
+				}
+				field.Set(reflect.ValueOf(FlexibleTime{Time: portalInstant(t, time.Time{})}))
+			}
+		}
+		if d.MeteringPointID == "" {
+			continue
+		}
+		if code, ok := portalTypes[strings.ToLower(d.TypeOfMP)]; ok {
+			d.TypeOfMP = code
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// readPortalRows reads the rows of a CSV or xlsx file, and reports whether its numbers
+// are Danish, as in a CSV separated by semicolons. An xlsx file is a zip archive and is
+// told apart by its signature; a CSV is separated by semicolons unless its first line has
+// only commas, and may start with a byte order mark.
+func readPortalRows(r io.Reader) ([][]string, bool, error) {
+	br := bufio.NewReader(r)
+	if signature, _ := br.Peek(4); bytes.Equal(signature, []byte("PK\x03\x04")) {
+		rows, err := readPortalXLSX(br)
+		return rows, false, err
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, false, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ';'
+	if first, _, _ := bytes.Cut(data, []byte("\n")); !bytes.Contains(first, []byte(";")) && bytes.Contains(first, []byte(",")) {
+		reader.Comma = ','
+	}
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return rows, reader.Comma == ';', nil
+}
+
+// readPortalXLSX reads the rows of the first sheet of an xlsx file. Cells are read raw,
+// so a date is the serial number Excel stores rather than however it is formatted.
+func readPortalXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("failed to read xlsx: no sheets")
+	}
+	rows, err := f.GetRows(sheets[0], excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx: %w", err)
+	}
+	return rows, nil
+}
+
+// portalColumn normalises a column name for matching: lower case, without spaces,
+// underscores, hyphens or dots.
+func portalColumn(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-', '.', '\uFEFF':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// portalHeader finds the header row among the first rows of a file: the first holding
+// every required column. It returns its index and the cell index of each known column.
+func portalHeader(rows [][]string, names map[string][]string, required ...string) (int, map[string]int, error) {
+	known := map[string]string{}
+	for column, aliases := range names {
+		for _, alias := range aliases {
+			known[alias] = column
+		}
+	}
+
+	// Report what the row closest to a header lacks; the first row when none is.
+	var closest []string
+	for _, column := range required {
+		closest = append(closest, names[column][0])
+	}
+	for i, row := range rows {
+		if i == portalHeaderRows {
+			break
+		}
+		columns := map[string]int{}
+		for c, cell := range row {
+			if column, ok := known[portalColumn(cell)]; ok {
+				if _, seen := columns[column]; !seen {
+					columns[column] = c
+				}
+			}
+		}
+		var missing []string
+		for _, column := range required {
+			if _, ok := columns[column]; !ok {
+				missing = append(missing, names[column][0])
+			}
+		}
+		if len(missing) == 0 {
+			return i, columns, nil
+		}
+		if len(missing) < len(closest) {
+			closest = missing
+		}
+	}
+	return 0, nil, fmt.Errorf("%w %s", ErrPortalColumns, strings.Join(closest, ", "))
+}
+
+// portalInterval resolves the interval of a point from the wall clock times of its
+// start and end, and infers its resolution from the length. after is the end of the
+// previous point of the metering point, which tells apart the two occurrences of the
+// hour repeated when daylight saving time ends.
+func portalInterval(fromCell, toCell string, after time.Time) (time.Time, time.Time, Resolution, error) {
+	fromWall, err := parsePortalTime(fromCell)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	toWall, err := parsePortalTime(toCell)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+
+	var resolution Resolution
+	switch length := toWall.Sub(fromWall); {
+	case length == 15*time.Minute:
+		resolution = PT15M
+	case length == time.Hour:
+		resolution = PT1H
+	case toWall.Equal(fromWall.AddDate(0, 0, 1)):
+		resolution = PT1D
+	case toWall.Equal(fromWall.AddDate(0, 1, 0)):
+		resolution = P1M
+	case toWall.Equal(fromWall.AddDate(1, 0, 0)):
+		resolution = PT1Y
+	default:
+		return time.Time{}, time.Time{}, "", fmt.Errorf("%s to %s is not a quarter, an hour, a day, a month or a year", fromCell, toCell)
+	}
+
+	from := portalInstant(fromWall, after)
+	// The end of a quarter or an hour is its start plus its length; its wall clock
+	// time is the same for both hours repeated in the autumn.
+	if resolution == PT15M || resolution == PT1H {
+		return from, from.Add(toWall.Sub(fromWall)), resolution, nil
+	}
+	return from, portalInstant(toWall, from.Add(time.Nanosecond)), resolution, nil
+}
+
+// portalInstant resolves a Danish wall clock time, given as its fields in UTC, to the
+// earliest instant with that wall clock time that is not before after. A wall clock time
+// skipped in the spring resolves as time.Date does.
+func portalInstant(wall, after time.Time) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, cph)
+	var first time.Time
+	for _, candidate := range []time.Time{t.Add(-time.Hour), t, t.Add(time.Hour)} {
+		local := candidate.In(cph)
+		if local.Hour() != wall.Hour() || local.Minute() != wall.Minute() || local.Day() != wall.Day() {
+			continue
+		}
+		if first.IsZero() {
+			first = candidate
+		}
+		if !candidate.Before(after) {
+			return candidate
+		}
+	}
+	if first.IsZero() {
+		return t
+	}
+	return first
+}
+
+// portalTimeLayouts are the layouts a time is accepted in, in Danish local time.
+var portalTimeLayouts = []string{
+	"02-01-2006 15:04:05",
+	"02-01-2006 15:04",
+	"02-01-2006",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	time.DateTime,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+// parsePortalTime parses a wall clock time, returning its fields in UTC. A number is the
+// serial date of a spreadsheet cell.
+func parsePortalTime(cell string) (time.Time, error) {
+	for _, layout := range portalTimeLayouts {
+		if t, err := time.Parse(layout, cell); err == nil {
+			return t, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(cell, 64); err == nil && serial > 0 {
+		t, err := excelize.ExcelDateToTime(serial, false)
+		if err == nil {
+			return t.Round(time.Second), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s'", cell)
+}
+
+// parsePortalQuantity parses a quantity. A Danish quantity has a decimal comma and
+// periods between the thousands, so 1.234 is 1234. Any other has a decimal point, as
+// spreadsheet cells and downloads separated by commas write it, unless it has a comma.
+func parsePortalQuantity(cell string, danish bool) (float64, error) {
+	number := cell
+	if danish || strings.Contains(number, ",") {
+		number = strings.ReplaceAll(number, ".", "")
+		number = strings.ReplaceAll(number, ",", ".")
+	}
+	quantity, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return 0, fmt.Errorf("invalid quantity '%s'", cell)
+	}
+	return quantity, nil
+}
+
+// portalUnit returns the unit as the API spells it, KWH when none is given.
+func portalUnit(cell string) string {
+	if cell == "" {
+		return "KWH"
+	}
+	return strings.ToUpper(cell)
+}
+
+// portalQuality returns the quality code of a cell; an unknown one is kept as it is.
+func portalQuality(cell string) string {
+	if code, ok := portalQualities[portalColumn(cell)]; ok {
+		return code
+	}
+	return cell
+}
+
+// appendPortalPoint appends a point to the time series of a metering point, continuing
+// the last period when the point follows it at the same resolution and starting a new
+// one otherwise. A new unit starts a new series in the document.
+func appendPortalPoint(ts *TimeSeries, id, unit string, resolution Resolution, from, to time.Time, quantity float64, quality string) {
+	doc := &ts.MyEnergyDataMarketDocument
+	if doc.PeriodTimeInterval.Start.IsZero() || from.Before(doc.PeriodTimeInterval.Start) {
+		doc.PeriodTimeInterval.Start = from.UTC()
+	}
+	if to.After(doc.PeriodTimeInterval.End) {
+		doc.PeriodTimeInterval.End = to.UTC()
+	}
+
+	if n := len(doc.TimeSeries); n == 0 || doc.TimeSeries[n-1].MeasurementUnitName != unit {
+		doc.TimeSeries = append(doc.TimeSeries, TimeSeriesTimeSeriesResponse{
+			MRID:                  id,
+			MeasurementUnitName:   unit,
+			MarketEvaluationPoint: MarketEvaluationPointResponse{MRID: MRIDResponse{CodingScheme: "A10", Name: id}},
+		})
+	}
+	series := &doc.TimeSeries[len(doc.TimeSeries)-1]
+
+	point := PointResponse{OutQuantityQuantity: quantity, OutQuantityQuality: quality}
+	if n := len(series.Periods); n > 0 {
+		period := &series.Periods[n-1]
+		if Resolution(period.Resolution) == resolution && period.TimeInterval.End.Equal(from) {
+			point.Position = len(period.Points) + 1
+			period.Points = append(period.Points, point)
+			period.TimeInterval.End = to.UTC()
+			return
+		}
+	}
+	point.Position = 1
+	series.Periods = append(series.Periods, PeriodResponse{
+		Resolution:   string(resolution),
+		TimeInterval: TimeInterval{Start: from.UTC(), End: to.UTC()},
+		Points:       []PointResponse{point},
+	})
+}
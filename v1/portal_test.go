@@ -0,0 +1,192 @@
+package eloverblik
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadPortalTimeSeries(t *testing.T) {
+	const id = "571313155411053087"
+
+	t.Run("a csv download", func(t *testing.T) {
+		data := "\uFEFFMålepunktsID;Fra_dato;Til_dato;Mængde;Måleenhed;Kvalitet\n" +
+			id + ";01-02-2026 00:00:00;01-02-2026 01:00:00;0,198;kWh;Målt\n" +
+			id + ";01-02-2026 01:00:00;01-02-2026 02:00:00;1.234,5;kWh;Estimeret\n" +
+			id + ";01-02-2026 03:00:00;01-02-2026 04:00:00;0,2;kWh;A01\n"
+
+		tss, err := ReadPortalTimeSeries(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, tss, 1)
+
+		ts := tss[0]
+		assert.True(t, ts.Success)
+		assert.Equal(t, id, ts.MeteringPointID())
+		assert.NoError(t, ts.Err())
+		assert.Len(t, ts.MyEnergyDataMarketDocument.TimeSeries[0].Periods, 2, "a gap starts a new period")
+
+		points := ts.Flatten()
+		require.Len(t, points, 3)
+		start := time.Date(2026, 2, 1, 0, 0, 0, 0, cph)
+		assert.True(t, points[0].From.Equal(start))
+		assert.True(t, points[0].To.Equal(start.Add(time.Hour)))
+		assert.Equal(t, 0.198, points[0].Measurement)
+		assert.Equal(t, "A04", points[0].Quality)
+		assert.Equal(t, "KWH", points[0].Unit)
+		assert.Equal(t, PT1H, points[0].Resolution)
+		assert.Equal(t, 1234.5, points[1].Measurement)
+		assert.Equal(t, "A03", points[1].Quality)
+		assert.True(t, points[2].From.Equal(start.Add(3*time.Hour)))
+		assert.Equal(t, "A01", points[2].Quality)
+	})
+
+	t.Run("the hour repeated in the autumn", func(t *testing.T) {
+		data := "MålepunktsID;Fra_dato;Til_dato;Mængde\n" +
+			id + ";26-10-2025 01:00:00;26-10-2025 02:00:00;1\n" +
+			id + ";26-10-2025 02:00:00;26-10-2025 03:00:00;2\n" +
+			id + ";26-10-2025 02:00:00;26-10-2025 03:00:00;3\n" +
+			id + ";26-10-2025 03:00:00;26-10-2025 04:00:00;4\n"
+
+		tss, err := ReadPortalTimeSeries(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, tss, 1)
+		assert.Len(t, tss[0].MyEnergyDataMarketDocument.TimeSeries[0].Periods, 1)
+
+		points := tss[0].Flatten()
+		require.Len(t, points, 4)
+		start := time.Date(2025, 10, 25, 23, 0, 0, 0, time.UTC)
+		for i, point := range points {
+			assert.True(t, point.From.Equal(start.Add(time.Duration(i)*time.Hour)), "point %d starts at %s", i, point.From)
+			assert.Equal(t, float64(i+1), point.Measurement)
+		}
+	})
+
+	t.Run("days, months and several metering points", func(t *testing.T) {
+		data := "Målepunkt ID,Fra dato,Til dato,Mængde\n" +
+			id + ",2025-03-30,2025-03-31,12.5\n" +
+			"571313155411053088,01-03-2025 00:00,01-04-2025 00:00,300\n" +
+			id + ",2025-03-31,2025-04-01,13\n"
+
+		tss, err := ReadPortalTimeSeries(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, tss, 2)
+		assert.Equal(t, id, tss[0].MeteringPointID())
+		assert.Equal(t, "571313155411053088", tss[1].MeteringPointID())
+
+		days := tss[0].Flatten()
+		require.Len(t, days, 2)
+		assert.Equal(t, PT1D, days[1].Resolution)
+		assert.True(t, days[1].From.Equal(time.Date(2025, 3, 31, 0, 0, 0, 0, cph)))
+		assert.True(t, days[1].To.Equal(time.Date(2025, 4, 1, 0, 0, 0, 0, cph)))
+
+		month := tss[1].Flatten()
+		require.Len(t, month, 1)
+		assert.Equal(t, P1M, month[0].Resolution)
+		assert.Equal(t, 300.0, month[0].Measurement)
+	})
+
+	t.Run("an xlsx download", func(t *testing.T) {
+		f := excelize.NewFile()
+		sheet := f.GetSheetName(0)
+		require.NoError(t, f.SetSheetRow(sheet, "A1", &[]any{"Måledata"}))
+		require.NoError(t, f.SetSheetRow(sheet, "A3", &[]any{"MålepunktsID", "Fra dato", "Til dato", "Mængde", "Kvalitet"}))
+		start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+		for i := range 3 {
+			from := start.Add(time.Duration(i) * 15 * time.Minute)
+			require.NoError(t, f.SetSheetRow(sheet, "A"+string(rune('4'+i)), &[]any{id, from, from.Add(15 * time.Minute), 0.25 * float64(i+1), "Målt"}))
+		}
+		var buf bytes.Buffer
+		require.NoError(t, f.Write(&buf))
+
+		tss, err := ReadPortalTimeSeries(&buf)
+		require.NoError(t, err)
+		require.Len(t, tss, 1)
+
+		points := tss[0].Flatten()
+		require.Len(t, points, 3)
+		for i, point := range points {
+			from := time.Date(2026, 2, 1, 0, 15*i, 0, 0, cph)
+			assert.True(t, point.From.Equal(from), "point %d starts at %s", i, point.From)
+			assert.Equal(t, PT15M, point.Resolution)
+			assert.Equal(t, 0.25*float64(i+1), point.Measurement)
+			assert.Equal(t, "A04", point.Quality)
+		}
+	})
+
+	t.Run("quantities", func(t *testing.T) {
+		for cell, expected := range map[string]float64{
+			"0,123":     0.123,
+			"1.234,567": 1234.567,
+			"1234,5":    1234.5,
+			"1.234":     1234,
+			"1.234.567": 1234567,
+			"12":        12,
+		} {
+			quantity, err := parsePortalQuantity(cell, true)
+			assert.NoError(t, err, cell)
+			assert.Equal(t, expected, quantity, "a Danish quantity: %s", cell)
+		}
+		for cell, expected := range map[string]float64{
+			"1.234": 1.234,
+			"0,5":   0.5,
+			"12":    12,
+		} {
+			quantity, err := parsePortalQuantity(cell, false)
+			assert.NoError(t, err, cell)
+			assert.Equal(t, expected, quantity, "a quantity with a decimal point: %s", cell)
+		}
+
+		data := "MålepunktsID;Fra_dato;Til_dato;Mængde\n" + id + ";01-02-2026 00:00:00;01-02-2026 01:00:00;1.234\n"
+		tss, err := ReadPortalTimeSeries(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, tss, 1)
+		assert.Equal(t, 1234.0, tss[0].Flatten()[0].Measurement, "a period in a semicolon file separates thousands")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ReadPortalTimeSeries(strings.NewReader("MålepunktsID;Vejnavn\n" + id + ";Vestergade\n"))
+		assert.ErrorIs(t, err, ErrPortalColumns)
+		assert.ErrorContains(t, err, "not a time series download: missing columns fradato, tildato, mængde")
+
+		_, err = ReadPortalTimeSeries(strings.NewReader(""))
+		assert.ErrorIs(t, err, ErrPortalColumns)
+
+		_, err = ReadPortalTimeSeries(strings.NewReader("MålepunktsID;Fra_dato;Til_dato;Mængde\n" + id + ";01-02-2026 00:00:00;01-02-2026 02:00:00;1\n"))
+		assert.EqualError(t, err, "line 2: 01-02-2026 00:00:00 to 01-02-2026 02:00:00 is not a quarter, an hour, a day, a month or a year")
+
+		_, err = ReadPortalTimeSeries(strings.NewReader("MålepunktsID;Fra_dato;Til_dato;Mængde\n" + id + ";01-02-2026 00:00:00;01-02-2026 01:00:00;n/a\n"))
+		assert.EqualError(t, err, "line 2: invalid quantity 'n/a'")
+
+		_, err = ReadPortalTimeSeries(strings.NewReader("MålepunktsID;Fra_dato;Til_dato;Mængde\n" + id + ";1 feb;01-02-2026 01:00:00;1\n"))
+		assert.EqualError(t, err, "line 2: invalid time '1 feb'")
+	})
+}
+
+func TestReadPortalMasterdata(t *testing.T) {
+	data := "MålepunktsID;Type;Vejnavn;Husnummer;Postnummer;By;Netselskab;balanceSupplierName;consumerStartDate;Ukendt\n" +
+		"571313174002485069;Forbrug;Vestergade;12;8000;Aarhus C;Konstant Net A/S;Andel Energi;01-01-2020;x\n" +
+		"571313174002485070;E18;Søndergade;1;8000;Aarhus C;;;;\n" +
+		";;;;;;;;;\n"
+
+	details, err := ReadPortalMasterdata(strings.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, details, 2)
+
+	assert.Equal(t, "571313174002485069", details[0].MeteringPointID)
+	assert.Equal(t, "E17", details[0].TypeOfMP)
+	assert.Equal(t, "Vestergade 12, 8000 Aarhus C", details[0].Address())
+	assert.Equal(t, "Konstant Net A/S", details[0].GridOperatorName)
+	assert.Equal(t, "Andel Energi", details[0].BalanceSupplierName)
+	assert.Equal(t, 2020, details[0].ConsumerStartDate.Year())
+	assert.Equal(t, "E18", details[1].TypeOfMP)
+	assert.Empty(t, details[1].GridOperatorName)
+
+	_, err = ReadPortalMasterdata(strings.NewReader("Vejnavn;By\nVestergade;Aarhus C\n"))
+	assert.ErrorIs(t, err, ErrPortalColumns)
+	assert.ErrorContains(t, err, "not a masterdata download")
+}
@@ -0,0 +1,292 @@
+package eloverblik
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxMeteringPointsPerRequest is the number of metering points the API recommends asking
+// for per request. Larger requests are refused with error 10002 or 10004.
+const MaxMeteringPointsPerRequest = 10
+
+// DefaultPortfolioConcurrency is the number of requests a Portfolio runs at a time when
+// LoadPortfolio is given zero or less. It stays well inside the 120 calls per minute the
+// API allows.
+const DefaultPortfolioConcurrency = 4
+
+// Portfolio is everything a third party has access to: its authorizations and the
+// metering points each of them covers. It fetches data for the whole portfolio at once,
+// in batches of MaxMeteringPointsPerRequest, with a bounded number of requests in
+// flight. A metering point covered by several authorizations is fetched once.
+//
+// Example:
+//
+//	portfolio, err := eloverblik.LoadPortfolio(thirdPartyClient, 4)
+//	if err != nil {
+//		return err
+//	}
+//	series, err := portfolio.TimeSeries(from, to, eloverblik.Hour)
+//	for authorizationID, points := range series {
+//		for meteringPointID, ts := range points {
+//			process(authorizationID, meteringPointID, ts.Flatten())
+//		}
+//	}
+type Portfolio struct {
+	// Authorizations are the third party's authorizations, in the order the API returned
+	// them.
+	Authorizations []Authorization
+	// MeteringPoints are the IDs of the metering points each authorization covers, keyed
+	// by authorization ID.
+	MeteringPoints map[string][]string
+
+	client      ThirdParty
+	concurrency int
+}
+
+// PortfolioResult holds the items a Portfolio fetched, keyed by authorization ID and then
+// by metering point ID. A metering point covered by several authorizations appears under
+// each of them, with the same item.
+type PortfolioResult[T any] map[string]map[string]T
+
+// LoadPortfolio enumerates the authorizations of a third party and resolves the metering
+// points of each, running at most concurrency requests at a time.
+//
+// An authorization whose metering points cannot be resolved is left out of
+// MeteringPoints, and its error is returned joined with any others, next to the
+// portfolio of the rest: both are non-nil then. Only a failure to list the
+// authorizations returns a nil portfolio.
+func LoadPortfolio(client ThirdParty, concurrency int) (*Portfolio, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultPortfolioConcurrency
+	}
+
+	authorizations, err := client.GetAuthorizations()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Portfolio{
+		Authorizations: authorizations,
+		MeteringPoints: make(map[string][]string, len(authorizations)),
+		client:         client,
+		concurrency:    concurrency,
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	limit(concurrency, len(authorizations), func(i int) {
+		id := authorizations[i].ID
+		ids, err := client.GetMeteringPointIDsForScope(AuthScopeID, id)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("authorization %s: %w", id, err))
+			return
+		}
+		p.MeteringPoints[id] = ids
+	})
+
+	return p, errors.Join(errs...)
+}
+
+// IDs returns the metering points of the whole portfolio, each once, sorted.
+func (p *Portfolio) IDs() []string {
+	var ids []string
+	for _, authorizationIDs := range p.MeteringPoints {
+		ids = append(ids, authorizationIDs...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// Details fetches the details of every metering point in the portfolio.
+//
+// Like LoadPortfolio, it returns what it could fetch together with the joined errors of
+// the batches that failed. A batch failing with a *PartialResultError, see
+// WithPartialResultErrors, still contributes its successful items.
+func (p *Portfolio) Details() (PortfolioResult[MeteringPointDetailsResponse], error) {
+	return fetchPortfolio(p, p.client.GetMeteringPointDetails, func(d MeteringPointDetailsResponse) string {
+		return cmp.Or(d.Result.MeteringPointID, d.ID)
+	})
+}
+
+// TimeSeries fetches the time series of every metering point in the portfolio. Errors
+// are handled as by Details.
+func (p *Portfolio) TimeSeries(from, to time.Time, aggregation Aggregation) (PortfolioResult[TimeSeries], error) {
+	fetch := func(ids []string) ([]TimeSeries, error) {
+		return p.client.GetTimeSeries(ids, from, to, aggregation)
+	}
+	return fetchPortfolio(p, fetch, func(ts TimeSeries) string {
+		return ts.MeteringPointID()
+	})
+}
+
+// Charges fetches the charges of every metering point in the portfolio. Errors are
+// handled as by Details.
+func (p *Portfolio) Charges() (PortfolioResult[ThirdPartyChargeResponse], error) {
+	return fetchPortfolio(p, p.client.GetThirdPartyCharges, func(c ThirdPartyChargeResponse) string {
+		return cmp.Or(c.Result.MeteringPointID, c.ID)
+	})
+}
+
+// All yields the authorizations of the portfolio, in the order the API returned them,
+// each with the metering points it covers.
+func (p *Portfolio) All() iter.Seq2[Authorization, []string] {
+	return func(yield func(Authorization, []string) bool) {
+		for _, authorization := range p.Authorizations {
+			if !yield(authorization, p.MeteringPoints[authorization.ID]) {
+				return
+			}
+		}
+	}
+}
+
+// MeteringPointIDs yields the metering points of the whole portfolio, each once, in the
+// order of the authorizations covering them. Unlike IDs, it neither sorts nor builds a
+// slice.
+func (p *Portfolio) MeteringPointIDs() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		seen := make(map[string]bool)
+		for _, authorization := range p.Authorizations {
+			for _, id := range p.MeteringPoints[authorization.ID] {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if !yield(id) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pages yields the metering points of the portfolio in pages of
+// MaxMeteringPointsPerRequest, the batches a request can ask for.
+func (p *Portfolio) Pages() iter.Seq[[]string] {
+	return func(yield func([]string) bool) {
+		page := make([]string, 0, MaxMeteringPointsPerRequest)
+		for id := range p.MeteringPointIDs() {
+			page = append(page, id)
+			if len(page) == MaxMeteringPointsPerRequest {
+				if !yield(page) {
+					return
+				}
+				page = make([]string, 0, MaxMeteringPointsPerRequest)
+			}
+		}
+		if len(page) > 0 {
+			yield(page)
+		}
+	}
+}
+
+// AllDetails yields the details of every metering point in the portfolio, fetching a
+// page at a time as the loop asks for more, so breaking out of it saves the requests for
+// the rest. A page that fails yields its error, with no item, after whatever items it
+// returned; the loop decides whether to go on.
+//
+// Example:
+//
+//	for detail, err := range portfolio.AllDetails() {
+//		if err != nil {
+//			log.Print(err)
+//			continue
+//		}
+//		process(detail.Result)
+//	}
+func (p *Portfolio) AllDetails() iter.Seq2[MeteringPointDetailsResponse, error] {
+	return pagePortfolio(p, p.client.GetMeteringPointDetails)
+}
+
+// AllTimeSeries yields the time series of every metering point in the portfolio a page
+// at a time, as AllDetails does.
+func (p *Portfolio) AllTimeSeries(from, to time.Time, aggregation Aggregation) iter.Seq2[TimeSeries, error] {
+	return pagePortfolio(p, func(ids []string) ([]TimeSeries, error) {
+		return p.client.GetTimeSeries(ids, from, to, aggregation)
+	})
+}
+
+// AllCharges yields the charges of every metering point in the portfolio a page at a
+// time, as AllDetails does.
+func (p *Portfolio) AllCharges() iter.Seq2[ThirdPartyChargeResponse, error] {
+	return pagePortfolio(p, p.client.GetThirdPartyCharges)
+}
+
+// pagePortfolio fetches the items of the portfolio's metering points a page at a time,
+// yielding each item, and the error of a page that failed.
+func pagePortfolio[T any](p *Portfolio, fetch func([]string) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page := range p.Pages() {
+			items, err := fetch(page)
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if err != nil {
+				var zero T
+				if !yield(zero, fmt.Errorf("metering points %s: %w", strings.Join(page, ","), err)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// fetchPortfolio fetches the items of the portfolio's metering points in batches, and
+// files each item under every authorization covering its metering point.
+func fetchPortfolio[T any](p *Portfolio, fetch func([]string) ([]T, error), meteringPointID func(T) string) (PortfolioResult[T], error) {
+	batches := slices.Collect(slices.Chunk(p.IDs(), MaxMeteringPointsPerRequest))
+
+	var mu sync.Mutex
+	var errs []error
+	items := make(map[string]T)
+	limit(p.concurrency, len(batches), func(i int) {
+		batch, err := fetch(batches[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("metering points %s: %w", strings.Join(batches[i], ","), err))
+		}
+		for _, item := range batch {
+			items[meteringPointID(item)] = item
+		}
+	})
+
+	result := make(PortfolioResult[T], len(p.MeteringPoints))
+	for authorizationID, ids := range p.MeteringPoints {
+		points := make(map[string]T, len(ids))
+		for _, id := range ids {
+			if item, ok := items[id]; ok {
+				points[id] = item
+			}
+		}
+		result[authorizationID] = points
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// limit calls fn for 0 to n-1, running at most concurrency calls at a time, and returns
+// once all of them have.
+func limit(concurrency, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i := range n {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			fn(i)
+		})
+	}
+	wg.Wait()
+}
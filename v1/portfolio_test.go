@@ -0,0 +1,216 @@
+package eloverblik
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeThirdParty serves a portfolio from memory, and records the batches it was asked for
+// and the most requests it had in flight at once.
+type fakeThirdParty struct {
+	ThirdParty
+
+	authorizations []Authorization
+	meteringPoints map[string][]string
+	failing        map[string]error
+
+	mu       sync.Mutex
+	batches  [][]string
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (f *fakeThirdParty) GetAuthorizations() ([]Authorization, error) {
+	return f.authorizations, nil
+}
+
+func (f *fakeThirdParty) GetMeteringPointIDsForScope(scope AuthorizationScope, identifier string) ([]string, error) {
+	if scope != AuthScopeID {
+		return nil, fmt.Errorf("unexpected scope %s", scope)
+	}
+	if err := f.failing[identifier]; err != nil {
+		return nil, err
+	}
+	return f.meteringPoints[identifier], nil
+}
+
+func (f *fakeThirdParty) GetMeteringPointDetails(ids []string) ([]MeteringPointDetailsResponse, error) {
+	f.enter(ids)
+	defer f.inFlight.Add(-1)
+
+	var details []MeteringPointDetailsResponse
+	for _, id := range ids {
+		if err := f.failing[id]; err != nil {
+			return nil, err
+		}
+		details = append(details, MeteringPointDetailsResponse{
+			Result:         MeteringPointDetail{MeteringPointID: id},
+			StatusResponse: StatusResponse{Success: true, ID: id},
+		})
+	}
+	return details, nil
+}
+
+func (f *fakeThirdParty) enter(ids []string) {
+	f.mu.Lock()
+	f.batches = append(f.batches, ids)
+	f.mu.Unlock()
+
+	if n := f.inFlight.Add(1); n > f.peak.Load() {
+		f.peak.Store(n)
+	}
+	time.Sleep(5 * time.Millisecond)
+}
+
+// fakeMeteringPointIDs returns n made-up metering point IDs starting at first.
+func fakeMeteringPointIDs(first, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("5713131801%08d", first+i)
+	}
+	return ids
+}
+
+func TestLoadPortfolio(t *testing.T) {
+	client := &fakeThirdParty{
+		authorizations: []Authorization{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+		meteringPoints: map[string][]string{
+			"a": fakeMeteringPointIDs(0, 15),
+			"b": fakeMeteringPointIDs(10, 15), // shares 5 with a
+		},
+		failing: map[string]error{"c": ErrorThirdPartyNotFound},
+	}
+
+	p, err := LoadPortfolio(client, 2)
+	assert.ErrorIs(t, err, ErrorThirdPartyNotFound)
+	assert.ErrorContains(t, err, "authorization c")
+	if !assert.NotNil(t, p, "the authorizations that resolved are kept") {
+		return
+	}
+
+	assert.Len(t, p.Authorizations, 3)
+	assert.Equal(t, []string{"a", "b"}, slices.Sorted(maps.Keys(p.MeteringPoints)))
+	assert.Equal(t, fakeMeteringPointIDs(0, 25), p.IDs(), "deduplicated and sorted")
+
+	t.Run("details are fetched once per metering point, in batches", func(t *testing.T) {
+		details, err := p.Details()
+		assert.NoError(t, err)
+
+		assert.Len(t, client.batches, 3, "25 metering points make 3 batches")
+		for _, batch := range client.batches {
+			assert.LessOrEqual(t, len(batch), MaxMeteringPointsPerRequest)
+		}
+		assert.LessOrEqual(t, client.peak.Load(), int32(2), "never more requests in flight than asked for")
+
+		assert.Len(t, details, 2)
+		assert.Len(t, details["a"], 15)
+		assert.Len(t, details["b"], 15)
+
+		shared := fakeMeteringPointIDs(10, 1)[0]
+		assert.Equal(t, shared, details["a"][shared].Result.MeteringPointID)
+		assert.Equal(t, details["a"][shared], details["b"][shared])
+	})
+
+	t.Run("a failed batch leaves the rest", func(t *testing.T) {
+		failed := fakeMeteringPointIDs(24, 1)[0]
+		client.failing[failed] = ErrorMeteringPointBlocked
+
+		details, err := p.Details()
+		assert.ErrorIs(t, err, ErrorMeteringPointBlocked)
+		assert.ErrorContains(t, err, failed)
+
+		// The last batch holds the failing metering point
+		assert.Len(t, details["a"], 15)
+		assert.Len(t, details["b"], 10)
+		assert.NotContains(t, details["b"], failed)
+	})
+}
+
+func TestLoadPortfolioFailsWithoutAuthorizations(t *testing.T) {
+	client := &failingAuthorizations{err: ErrorTokenNotValid}
+
+	p, err := LoadPortfolio(client, 0)
+	assert.Nil(t, p)
+	assert.ErrorIs(t, err, ErrorTokenNotValid)
+}
+
+type failingAuthorizations struct {
+	ThirdParty
+	err error
+}
+
+func (f *failingAuthorizations) GetAuthorizations() ([]Authorization, error) {
+	return nil, f.err
+}
+
+func TestPortfolioIterators(t *testing.T) {
+	client := &fakeThirdParty{
+		authorizations: []Authorization{{ID: "a"}, {ID: "b"}},
+		meteringPoints: map[string][]string{
+			"a": fakeMeteringPointIDs(0, 15),
+			"b": fakeMeteringPointIDs(10, 15), // shares 5 with a
+		},
+		failing: map[string]error{},
+	}
+	p, err := LoadPortfolio(client, 2)
+	assert.NoError(t, err)
+
+	t.Run("authorizations with their metering points", func(t *testing.T) {
+		var ids []string
+		for authorization, points := range p.All() {
+			ids = append(ids, authorization.ID)
+			assert.Equal(t, client.meteringPoints[authorization.ID], points)
+		}
+		assert.Equal(t, []string{"a", "b"}, ids)
+	})
+
+	t.Run("metering points once each, in authorization order", func(t *testing.T) {
+		assert.Equal(t, fakeMeteringPointIDs(0, 25), slices.Collect(p.MeteringPointIDs()))
+
+		pages := slices.Collect(p.Pages())
+		assert.Len(t, pages, 3)
+		assert.Equal(t, fakeMeteringPointIDs(20, 5), pages[2])
+	})
+
+	t.Run("a page is fetched only when the loop gets to it", func(t *testing.T) {
+		client.batches = nil
+		var ids []string
+		for detail, err := range p.AllDetails() {
+			assert.NoError(t, err)
+			ids = append(ids, detail.Result.MeteringPointID)
+			if len(ids) == 12 {
+				break
+			}
+		}
+		assert.Equal(t, fakeMeteringPointIDs(0, 12), ids)
+		assert.Len(t, client.batches, 2)
+	})
+
+	t.Run("a failed page yields its error and the loop goes on", func(t *testing.T) {
+		failed := fakeMeteringPointIDs(5, 1)[0]
+		client.failing[failed] = ErrorMeteringPointBlocked
+		defer delete(client.failing, failed)
+
+		var errs []error
+		details := 0
+		for _, err := range p.AllDetails() {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			details++
+		}
+		assert.Equal(t, 15, details, "the two pages that did not fail")
+		if assert.Len(t, errs, 1) {
+			assert.ErrorIs(t, errs[0], ErrorMeteringPointBlocked)
+			assert.ErrorContains(t, errs[0], failed)
+		}
+	})
+}
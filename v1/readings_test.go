@@ -0,0 +1,139 @@
+package eloverblik
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMeterReadings(t *testing.T) {
+	// Setup mock client
+	mockResty := resty.New()
+	httpmock.ActivateNonDefault(mockResty.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	c := &client{
+		accessToken: "test-access-token", // Pre-set token to skip auth mock
+		resty:       mockResty,
+	}
+
+	meteringPointIDs := []string{"571313180100000001", "571313180100000002"}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	to := time.Date(2025, 1, 1, 0, 0, 0, 0, cph)
+
+	t.Run("successfully gets meter readings", func(t *testing.T) {
+		httpmock.Reset()
+		mockResponse := `{
+			"result": [
+				{
+					"result": {
+						"meteringPointId": "571313180100000001",
+						"readings": [
+							{
+								"readingDate": "2024-06-30T22:00:00Z",
+								"registrationDate": "2024-07-02T10:15:00",
+								"meterNumber": "12345678",
+								"meterReading": "10234,5",
+								"measurementUnit": "KWH"
+							}
+						]
+					},
+					"success": true,
+					"errorCode": 10000,
+					"errorText": "NoError",
+					"id": "571313180100000001"
+				},
+				{
+					"result": {"meteringPointId": null, "readings": null},
+					"success": false,
+					"errorCode": 20008,
+					"errorText": "MeteringPointNotFound",
+					"id": "571313180100000002"
+				}
+			]
+		}`
+
+		httpmock.RegisterResponder("POST", "/meterdata/getmeterreadings/2024-01-01/2025-01-01",
+			func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				assert.JSONEq(t, `{"meteringPoints":{"meteringPoint":["571313180100000001","571313180100000002"]}}`, string(body))
+
+				resp := httpmock.NewStringResponse(200, mockResponse)
+				resp.Header.Set("Content-Type", "application/json")
+				return resp, nil
+			},
+		)
+
+		readings, err := c.GetMeterReadings(meteringPointIDs, from, to)
+
+		assert.NoError(t, err)
+		assert.Len(t, readings, 2)
+
+		reading := readings[0].Result.Readings[0]
+		assert.Equal(t, "571313180100000001", readings[0].Result.MeteringPointID)
+		assert.True(t, reading.ReadingDate.Equal(time.Date(2024, 7, 1, 0, 0, 0, 0, cph)))
+		assert.True(t, reading.RegistrationDate.Equal(time.Date(2024, 7, 2, 10, 15, 0, 0, cph)), "a date without offset is Copenhagen time")
+		assert.Equal(t, 10234.5, reading.MeterReading)
+		assert.Equal(t, "KWH", reading.MeasurementUnit)
+
+		assert.False(t, readings[1].Success)
+		assert.Equal(t, 20008, readings[1].ErrorCode)
+		assert.Empty(t, readings[1].Result.Readings)
+	})
+
+	t.Run("returns an API error", func(t *testing.T) {
+		httpmock.Reset()
+		url := fmt.Sprintf("/meterdata/getmeterreadings/%s/%s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+		httpmock.RegisterResponder("POST", url, httpmock.NewJsonResponderOrPanic(400, "[30001] Period not allowed, ToDate is before FromDate"))
+
+		_, err := c.GetMeterReadings(meteringPointIDs, from, to)
+
+		assert.ErrorIs(t, err, ErrorFromDateIsGreaterThanToDate)
+	})
+}
+
+func TestMeterReadingUnmarshal(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input string
+		want  float64
+	}{
+		"decimal point":                {input: `"1234.5"`, want: 1234.5},
+		"decimal comma":                {input: `"1234,5"`, want: 1234.5},
+		"thousands dots and a comma":   {input: `"12.345,6"`, want: 12345.6},
+		"a number":                     {input: `987`, want: 987},
+		"an empty value":               {input: `""`, want: 0},
+		"an integer register as text":  {input: `"000123"`, want: 123},
+		"surrounding whitespace, text": {input: `" 42 "`, want: 42},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var reading MeterReading
+			err := json.Unmarshal([]byte(`{"readingDate":"2024-01-01","meterReading":`+tc.input+`}`), &reading)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, reading.MeterReading)
+			assert.True(t, reading.ReadingDate.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, cph)))
+		})
+	}
+
+	t.Run("marshals with typed values", func(t *testing.T) {
+		reading := MeterReading{
+			ReadingDate:  FlexibleTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			MeterNumber:  "12345678",
+			MeterReading: 1234.5,
+		}
+		data, err := json.Marshal(reading)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"readingDate":"2024-01-01T00:00:00Z","registrationDate":null,"meterNumber":"12345678","meterReading":1234.5,"measurementUnit":""}`, string(data))
+	})
+
+	t.Run("rejects a reading that is not a number", func(t *testing.T) {
+		var reading MeterReading
+		assert.Error(t, json.Unmarshal([]byte(`{"meterReading":"n/a"}`), &reading))
+	})
+}
@@ -0,0 +1,178 @@
+package eloverblik
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MeterRegister describes the counter of a meter: the number of digits it shows before it
+// rolls over to zero, the factor its readings are multiplied by to get the energy, and the
+// unit of that energy.
+type MeterRegister struct {
+	Digits         int     `json:"digits"`
+	MultiplyFactor float64 `json:"multiplyFactor"`
+	Unit           string  `json:"unit"`
+}
+
+// MeterRegister returns the counter of the metering point's meter, from the string fields
+// the API sends it in ("7.0", "1.0", "KWH"). A missing number of digits is zero, which
+// disables rollover handling, and a missing multiply factor is 1.
+func (d MeteringPointDetail) MeterRegister() (MeterRegister, error) {
+	register := MeterRegister{MultiplyFactor: 1, Unit: d.MeterCounterUnit}
+
+	if digits := strings.TrimSpace(d.MeterCounterDigits); digits != "" {
+		value, err := strconv.ParseFloat(digits, 64)
+		if err != nil || value < 0 || value != math.Trunc(value) {
+			return MeterRegister{}, fmt.Errorf("invalid meterCounterDigits '%s'", d.MeterCounterDigits)
+		}
+		register.Digits = int(value)
+	}
+
+	if factor := strings.TrimSpace(d.MeterCounterMultiplyFactor); factor != "" {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(factor, ",", "."), 64)
+		if err != nil || value <= 0 {
+			return MeterRegister{}, fmt.Errorf("invalid meterCounterMultiplyFactor '%s'", d.MeterCounterMultiplyFactor)
+		}
+		register.MultiplyFactor = value
+	}
+
+	return register, nil
+}
+
+// RegisterReconciliation compares the consumption between two consecutive register
+// readings with the consumption the time series measured in the same interval.
+//
+// RegisterConsumption is the difference of the readings, corrected for a rollover of the
+// counter and multiplied by the register's multiply factor. MeasuredConsumption is the
+// sum of the time series points that lie within [From, To), and Complete reports whether
+// those points cover the whole interval: a difference in an incomplete interval may just
+// be missing data. Difference is RegisterConsumption minus MeasuredConsumption.
+//
+// When the meter number changes between the two readings, the meter was replaced and the
+// readings are on different counters: MeterChanged is set and RegisterConsumption and
+// Difference are left at zero.
+type RegisterReconciliation struct {
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	MeterNumber         string    `json:"meterNumber"`
+	StartReading        float64   `json:"startReading"`
+	EndReading          float64   `json:"endReading"`
+	Rollover            bool      `json:"rollover"`
+	MeterChanged        bool      `json:"meterChanged"`
+	RegisterConsumption float64   `json:"registerConsumption"`
+	MeasuredConsumption float64   `json:"measuredConsumption"`
+	Difference          float64   `json:"difference"`
+	Complete            bool      `json:"complete"`
+}
+
+// ReconcileRegister checks the register readings of a metering point against its time
+// series, one interval per pair of consecutive readings. The readings may come in any
+// order; two readings on the same date make no interval.
+//
+// points are the flattened time series of the same metering point, e.g. from
+// TimeSeries.Flatten(), covering the period of the readings. Their resolution only needs
+// to be fine enough for the reading dates: a daily series suffices for readings taken at
+// midnight.
+//
+// It fails when the units of the register, the readings and the time series disagree, as
+// the difference would be meaningless. Units are compared case-insensitively and an empty
+// unit matches any.
+//
+// Example:
+//
+//	register, _ := details[0].Result.MeterRegister()
+//	rec, err := eloverblik.ReconcileRegister(register, readings[0].Result.Readings, series[0].Flatten())
+func ReconcileRegister(register MeterRegister, readings []MeterReading, points []FlatTimeSeriesPoint) ([]RegisterReconciliation, error) {
+
+	unit := register.Unit
+	for _, reading := range readings {
+		if reading.ReadingDate.IsZero() {
+			return nil, fmt.Errorf("meter reading %v has no reading date", reading.MeterReading)
+		}
+		if err := sameUnit(&unit, reading.MeasurementUnit); err != nil {
+			return nil, err
+		}
+	}
+	for _, point := range points {
+		if err := sameUnit(&unit, point.Unit); err != nil {
+			return nil, err
+		}
+	}
+
+	factor := register.MultiplyFactor
+	if factor == 0 {
+		factor = 1
+	}
+
+	sorted := slices.Clone(readings)
+	slices.SortStableFunc(sorted, func(a, b MeterReading) int {
+		return a.ReadingDate.Compare(b.ReadingDate.Time)
+	})
+
+	reconciliations := make([]RegisterReconciliation, 0, len(sorted))
+	for i := 1; i < len(sorted); i++ {
+		start, end := sorted[i-1], sorted[i]
+		if !end.ReadingDate.After(start.ReadingDate.Time) {
+			continue
+		}
+
+		rec := RegisterReconciliation{
+			From:         start.ReadingDate.In(cph),
+			To:           end.ReadingDate.In(cph),
+			MeterNumber:  end.MeterNumber,
+			StartReading: start.MeterReading,
+			EndReading:   end.MeterReading,
+			MeterChanged: start.MeterNumber != end.MeterNumber,
+		}
+
+		var covered time.Duration
+		for _, point := range points {
+			if !point.From.Before(rec.From) && !point.To.After(rec.To) {
+				rec.MeasuredConsumption += point.Measurement
+				covered += point.To.Sub(point.From)
+			}
+		}
+		rec.MeasuredConsumption = roundQuantity(rec.MeasuredConsumption)
+		rec.Complete = covered == rec.To.Sub(rec.From)
+
+		if !rec.MeterChanged {
+			delta := end.MeterReading - start.MeterReading
+
+			// A counter showing fewer digits than it has counted starts over at zero
+			if delta < 0 && register.Digits > 0 {
+				delta += math.Pow10(register.Digits)
+				rec.Rollover = true
+			}
+
+			rec.RegisterConsumption = roundQuantity(delta * factor)
+			rec.Difference = roundQuantity(rec.RegisterConsumption - rec.MeasuredConsumption)
+		}
+
+		reconciliations = append(reconciliations, rec)
+	}
+
+	return reconciliations, nil
+}
+
+// roundQuantity rounds to the three decimals the time series quantities are sent with, so
+// a sum of hours does not leave a difference of floating point noise.
+func roundQuantity(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// sameUnit fails when other is a different unit than the one seen so far, and remembers
+// other when no unit was seen yet.
+func sameUnit(unit *string, other string) error {
+	switch {
+	case other == "":
+	case *unit == "":
+		*unit = other
+	case !strings.EqualFold(*unit, other):
+		return fmt.Errorf("cannot reconcile %s against %s", *unit, other)
+	}
+	return nil
+}
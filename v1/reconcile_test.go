@@ -0,0 +1,134 @@
+package eloverblik
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dailyPoints returns a point per day from the first date up to, not including, the last.
+func dailyPoints(from, to time.Time, measurement float64) []FlatTimeSeriesPoint {
+	var points []FlatTimeSeriesPoint
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		points = append(points, FlatTimeSeriesPoint{From: day, To: day.AddDate(0, 0, 1), Measurement: measurement, Unit: "KWH", Resolution: PT1D})
+	}
+	return points
+}
+
+func reading(date time.Time, meterNumber string, value float64) MeterReading {
+	return MeterReading{ReadingDate: FlexibleTime{Time: date}, MeterNumber: meterNumber, MeterReading: value, MeasurementUnit: "KWH"}
+}
+
+func TestMeterRegister(t *testing.T) {
+	register, err := MeteringPointDetail{MeterCounterDigits: "7.0", MeterCounterMultiplyFactor: "1.0", MeterCounterUnit: "KWH"}.MeterRegister()
+	assert.NoError(t, err)
+	assert.Equal(t, MeterRegister{Digits: 7, MultiplyFactor: 1, Unit: "KWH"}, register)
+
+	register, err = MeteringPointDetail{}.MeterRegister()
+	assert.NoError(t, err)
+	assert.Equal(t, MeterRegister{MultiplyFactor: 1}, register, "missing fields disable rollover and do not scale")
+
+	_, err = MeteringPointDetail{MeterCounterDigits: "6.5"}.MeterRegister()
+	assert.Error(t, err)
+	_, err = MeteringPointDetail{MeterCounterMultiplyFactor: "0"}.MeterRegister()
+	assert.Error(t, err)
+}
+
+func TestReconcileRegister(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, cph)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, cph)
+	points := dailyPoints(jan, mar, 10)
+
+	t.Run("matching register and series", func(t *testing.T) {
+		// Out of order on purpose
+		readings := []MeterReading{reading(feb, "1", 1310), reading(jan, "1", 1000), reading(mar, "1", 1600)}
+
+		rec, err := ReconcileRegister(MeterRegister{Digits: 7, MultiplyFactor: 1, Unit: "KWH"}, readings, points)
+		assert.NoError(t, err)
+		assert.Len(t, rec, 2)
+
+		assert.True(t, rec[0].From.Equal(jan))
+		assert.True(t, rec[0].To.Equal(feb))
+		assert.Equal(t, 310.0, rec[0].RegisterConsumption)
+		assert.Equal(t, 310.0, rec[0].MeasuredConsumption)
+		assert.Zero(t, rec[0].Difference)
+		assert.True(t, rec[0].Complete)
+
+		assert.Equal(t, 290.0, rec[1].RegisterConsumption, "29 days in February 2024")
+		assert.Zero(t, rec[1].Difference)
+	})
+
+	t.Run("multiply factor and a difference", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 100), reading(feb, "1", 132)}
+
+		rec, err := ReconcileRegister(MeterRegister{MultiplyFactor: 10}, readings, points)
+		assert.NoError(t, err)
+		assert.Equal(t, 320.0, rec[0].RegisterConsumption)
+		assert.Equal(t, 10.0, rec[0].Difference)
+	})
+
+	t.Run("counter rollover", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 99900), reading(feb, "1", 210)}
+
+		rec, err := ReconcileRegister(MeterRegister{Digits: 5, MultiplyFactor: 1}, readings, points)
+		assert.NoError(t, err)
+		assert.True(t, rec[0].Rollover)
+		assert.Equal(t, 310.0, rec[0].RegisterConsumption)
+		assert.Zero(t, rec[0].Difference)
+	})
+
+	t.Run("missing time series data", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 1000), reading(feb, "1", 1310)}
+
+		rec, err := ReconcileRegister(MeterRegister{MultiplyFactor: 1}, readings, points[1:])
+		assert.NoError(t, err)
+		assert.False(t, rec[0].Complete)
+		assert.Equal(t, 10.0, rec[0].Difference)
+	})
+
+	t.Run("replaced meter", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 52000), reading(feb, "2", 310)}
+
+		rec, err := ReconcileRegister(MeterRegister{MultiplyFactor: 1}, readings, points)
+		assert.NoError(t, err)
+		assert.True(t, rec[0].MeterChanged)
+		assert.Zero(t, rec[0].RegisterConsumption)
+		assert.Zero(t, rec[0].Difference)
+		assert.Equal(t, 310.0, rec[0].MeasuredConsumption)
+	})
+
+	t.Run("readings on the same date make no interval", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 1000), reading(jan, "1", 1000)}
+
+		rec, err := ReconcileRegister(MeterRegister{MultiplyFactor: 1}, readings, points)
+		assert.NoError(t, err)
+		assert.Empty(t, rec)
+	})
+
+	t.Run("units must agree", func(t *testing.T) {
+		readings := []MeterReading{reading(jan, "1", 1000), reading(feb, "1", 1310)}
+
+		_, err := ReconcileRegister(MeterRegister{Unit: "MWH"}, readings, points)
+		assert.ErrorContains(t, err, "cannot reconcile MWH against KWH")
+
+		_, err = ReconcileRegister(MeterRegister{Unit: "kWh"}, readings, points)
+		assert.NoError(t, err, "units compare case-insensitively")
+	})
+}
+
+func TestReconcileRegisterRounding(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	to := from.AddDate(0, 0, 1)
+
+	var points []FlatTimeSeriesPoint
+	for hour := from; hour.Before(to); hour = hour.Add(time.Hour) {
+		points = append(points, FlatTimeSeriesPoint{From: hour, To: hour.Add(time.Hour), Measurement: 0.1})
+	}
+
+	rec, err := ReconcileRegister(MeterRegister{MultiplyFactor: 1}, []MeterReading{reading(from, "1", 10), reading(to, "1", 12.4)}, points)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.4, rec[0].MeasuredConsumption)
+	assert.Equal(t, 0.0, rec[0].Difference, "no floating point noise")
+}
@@ -0,0 +1,94 @@
+package eloverblik
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Resample sums the points of a flattened time series into intervals of the given
+// aggregation: Quarter, Hour, Day, Month or Year. Days, months and years are Danish
+// calendar ones, so a day holds 23 or 25 hours across a daylight saving transition.
+//
+// It does what asking the API for the coarser aggregation does, without another call:
+// fetch Quarter or Hour once, and derive the daily and monthly totals from it. The points
+// must belong to a single time series, see TimeSeries.Flatten; their unit, curve type and
+// business type are taken from the first point of each interval. The quality of an
+// interval is that of its points when they agree, and empty when they do not.
+//
+// A point longer than the interval it starts in cannot be split, and is an error, e.g.
+// resampling daily points to hours.
+func Resample(points []FlatTimeSeriesPoint, aggregation Aggregation) ([]FlatTimeSeriesPoint, error) {
+	resolution, ok := aggregationResolutions[aggregation]
+	if !ok {
+		return nil, fmt.Errorf("cannot resample to aggregation '%s'", aggregation)
+	}
+
+	var resampled []FlatTimeSeriesPoint
+	index := make(map[time.Time]int)
+	for _, point := range points {
+		from, to := resampleInterval(point.From, aggregation)
+		if point.To.After(to) {
+			return nil, fmt.Errorf("cannot resample the point %s to %s into %s: it is longer than an interval",
+				point.From.Format(time.RFC3339), point.To.Format(time.RFC3339), aggregation)
+		}
+
+		i, ok := index[from]
+		if !ok {
+			index[from] = len(resampled)
+			resampled = append(resampled, FlatTimeSeriesPoint{
+				From:         from,
+				To:           to,
+				Quality:      point.Quality,
+				Unit:         point.Unit,
+				CurveType:    point.CurveType,
+				BusinessType: point.BusinessType,
+				Resolution:   resolution,
+			})
+			i = len(resampled) - 1
+		}
+
+		resampled[i].Measurement += point.Measurement
+		if resampled[i].Quality != point.Quality {
+			resampled[i].Quality = ""
+		}
+	}
+
+	slices.SortFunc(resampled, func(a, b FlatTimeSeriesPoint) int {
+		return a.From.Compare(b.From)
+	})
+	return resampled, nil
+}
+
+// aggregationResolutions are the resolutions of the aggregations Resample supports.
+var aggregationResolutions = map[Aggregation]Resolution{
+	Quarter: PT15M,
+	Hour:    PT1H,
+	Day:     PT1D,
+	Month:   P1M,
+	Year:    PT1Y,
+}
+
+// resampleInterval returns the interval of the aggregation that t falls in, in
+// Copenhagen local time. Danish offsets are whole hours, so quarters and hours are the
+// same in UTC, which keeps the repeated hour of the autumn transition apart.
+func resampleInterval(t time.Time, aggregation Aggregation) (from, to time.Time) {
+	local := t.In(cph)
+	switch aggregation {
+	case Quarter:
+		from = t.Truncate(15 * time.Minute).In(cph)
+		return from, from.Add(15 * time.Minute)
+	case Hour:
+		from = t.Truncate(time.Hour).In(cph)
+		return from, from.Add(time.Hour)
+	case Day:
+		from = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, cph)
+		return from, from.AddDate(0, 0, 1)
+	case Month:
+		from = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, cph)
+		return from, from.AddDate(0, 1, 0)
+	default:
+		from = time.Date(local.Year(), 1, 1, 0, 0, 0, 0, cph)
+		return from, from.AddDate(1, 0, 0)
+	}
+}
@@ -0,0 +1,75 @@
+package eloverblik
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hourlyPoints returns a point of quantity 1 for every hour from start, in UTC steps.
+func hourlyPoints(start time.Time, hours int) []FlatTimeSeriesPoint {
+	points := make([]FlatTimeSeriesPoint, hours)
+	for i := range points {
+		from := start.Add(time.Duration(i) * time.Hour).In(cph)
+		points[i] = FlatTimeSeriesPoint{From: from, To: from.Add(time.Hour), Measurement: 1, Quality: "A04", Unit: "KWH", Resolution: PT1H}
+	}
+	return points
+}
+
+func TestResample(t *testing.T) {
+	t.Run("days across the autumn transition", func(t *testing.T) {
+		// 26 October 2025 has 25 hours in Denmark
+		start := time.Date(2025, 10, 25, 0, 0, 0, 0, cph)
+		points := hourlyPoints(start, 24+25+24)
+		points[30].Quality = "A03"
+
+		days, err := Resample(points, Day)
+		assert.NoError(t, err)
+		if assert.Len(t, days, 3) {
+			assert.Equal(t, []float64{24, 25, 24}, []float64{days[0].Measurement, days[1].Measurement, days[2].Measurement})
+			assert.Equal(t, time.Date(2025, 10, 26, 0, 0, 0, 0, cph), days[1].From)
+			assert.Equal(t, time.Date(2025, 10, 27, 0, 0, 0, 0, cph), days[1].To)
+			assert.Equal(t, PT1D, days[1].Resolution)
+			assert.Equal(t, "A04", days[0].Quality)
+			assert.Equal(t, "", days[1].Quality, "an estimated hour mixes the qualities")
+			assert.Equal(t, "KWH", days[2].Unit)
+		}
+	})
+
+	t.Run("the repeated hour stays two hours", func(t *testing.T) {
+		start := time.Date(2025, 10, 26, 0, 0, 0, 0, cph)
+		hours, err := Resample(hourlyPoints(start, 25), Hour)
+		assert.NoError(t, err)
+		assert.Len(t, hours, 25)
+	})
+
+	t.Run("months from unordered quarters", func(t *testing.T) {
+		jan := time.Date(2025, 1, 31, 23, 0, 0, 0, cph)
+		points := []FlatTimeSeriesPoint{
+			{From: jan.Add(time.Hour), To: jan.Add(time.Hour + 15*time.Minute), Measurement: 0.5},
+			{From: jan, To: jan.Add(15 * time.Minute), Measurement: 0.25},
+			{From: jan.Add(45 * time.Minute), To: jan.Add(time.Hour), Measurement: 0.25},
+		}
+
+		months, err := Resample(points, Month)
+		assert.NoError(t, err)
+		if assert.Len(t, months, 2) {
+			assert.Equal(t, time.January, months[0].From.Month())
+			assert.Equal(t, 0.5, months[0].Measurement)
+			assert.Equal(t, time.February, months[1].From.Month())
+			assert.Equal(t, 0.5, months[1].Measurement)
+		}
+	})
+
+	t.Run("points cannot be split", func(t *testing.T) {
+		day := time.Date(2025, 1, 1, 0, 0, 0, 0, cph)
+		_, err := Resample([]FlatTimeSeriesPoint{{From: day, To: day.AddDate(0, 0, 1)}}, Hour)
+		assert.ErrorContains(t, err, "longer than an interval")
+	})
+
+	t.Run("unsupported aggregation", func(t *testing.T) {
+		_, err := Resample(nil, Actual)
+		assert.ErrorContains(t, err, "cannot resample to aggregation 'Actual'")
+	})
+}
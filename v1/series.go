@@ -0,0 +1,358 @@
+package eloverblik
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"math/bits"
+	"time"
+	"unique"
+)
+
+// Series is the points of a single metering point at a single resolution, held column by
+// column for analysis of long ranges. A FlatTimeSeriesPoint takes 136 bytes; a point of
+// a Series takes the 8 of its value and a bit of its quality.
+//
+// The points lie on a grid: point i covers the i-th interval of the resolution from
+// Start, stepping by calendar unit as Flatten does. A point missing from the grid, such as
+// a gap in the data, has the value NaN and no quality. The unit, curve type, business type
+// and qualities are interned, so every Series in a process shares a single copy of each.
+//
+// Example:
+//
+//	series, err := eloverblik.NewSeries(ts.MeteringPointID(), ts.Points())
+//	if err != nil {
+//		return err
+//	}
+//	january := series.Slice(
+//		time.Date(2025, 1, 1, 0, 0, 0, 0, eloverblik.Copenhagen),
+//		time.Date(2025, 2, 1, 0, 0, 0, 0, eloverblik.Copenhagen),
+//	)
+//	fmt.Println(january.Sum(), january.Unit())
+type Series struct {
+	// MeteringPointID is the metering point the points belong to.
+	MeteringPointID string
+	// Start is the start of the first point, in Copenhagen local time.
+	Start time.Time
+	// Resolution is the length of every point: PT15M, PT1H, PT1D, P1M or PT1Y, or the
+	// P1D and P1Y spellings of the latter.
+	Resolution Resolution
+	// Values are the measurements of the points, NaN where a point is missing.
+	Values []float64
+
+	unit         unique.Handle[string]
+	curveType    unique.Handle[string]
+	businessType unique.Handle[string]
+	// qualities hold a bitmap per quality code, setting the bit of every point of that
+	// quality. A missing point has no bit set.
+	qualities []qualityBitmap
+}
+
+// qualityBitmap marks the points of a series that have a quality.
+type qualityBitmap struct {
+	code unique.Handle[string]
+	bits []uint64
+}
+
+// NewSeries builds a Series from the points of a metering point, such as the Points of a
+// TimeSeries. The points must be in time order and share a resolution, unit, curve type
+// and business type; gaps between them become missing points. Convert mixed data with
+// Resample, or build a Series per resolution.
+//
+// Every point must cover one whole step of the resolution. The API answers a range that
+// starts or ends within a day, month or year with a partial first or last period, which a
+// Series cannot hold: ask for whole months and years to build one.
+func NewSeries(meteringPointID string, points iter.Seq[FlatTimeSeriesPoint]) (*Series, error) {
+	var s *Series
+	for point := range points {
+		if s == nil {
+			s = &Series{
+				MeteringPointID: meteringPointID,
+				Start:           point.From.In(cph),
+				Resolution:      point.Resolution,
+				unit:            unique.Make(point.Unit),
+				curveType:       unique.Make(point.CurveType),
+				businessType:    unique.Make(point.BusinessType),
+			}
+			if _, ok := seriesStep(s.Resolution); !ok {
+				return nil, fmt.Errorf("resolution %s has no fixed step", s.Resolution)
+			}
+		}
+
+		switch {
+		case point.Resolution != s.Resolution:
+			return nil, fmt.Errorf("point at %s: resolution %s, expected %s", point.From.Format(time.RFC3339), point.Resolution, s.Resolution)
+		case point.Unit != s.Unit():
+			return nil, fmt.Errorf("point at %s: unit %s, expected %s", point.From.Format(time.RFC3339), point.Unit, s.Unit())
+		case point.CurveType != s.CurveType() || point.BusinessType != s.BusinessType():
+			return nil, fmt.Errorf("point at %s: curve or business type differs from the first point", point.From.Format(time.RFC3339))
+		}
+
+		if step, _ := seriesStep(s.Resolution); !step.add(point.From, 1).Equal(point.To) {
+			return nil, fmt.Errorf("point at %s: ends at %s, not after a whole %s", point.From.Format(time.RFC3339), point.To.Format(time.RFC3339), s.Resolution)
+		}
+		i := s.index(point.From)
+		from, to := s.Interval(i)
+		if !from.Equal(point.From) || !to.Equal(point.To) {
+			return nil, fmt.Errorf("point at %s: not on the %s grid from %s", point.From.Format(time.RFC3339), s.Resolution, s.Start.Format(time.RFC3339))
+		}
+		if i < len(s.Values) {
+			return nil, fmt.Errorf("point at %s: not after the point before it", point.From.Format(time.RFC3339))
+		}
+
+		for len(s.Values) < i {
+			s.Values = append(s.Values, math.NaN())
+		}
+		s.Values = append(s.Values, point.Measurement)
+		s.setQuality(i, point.Quality)
+	}
+
+	if s == nil {
+		return nil, fmt.Errorf("no points")
+	}
+	return s, nil
+}
+
+// Len returns the number of points, missing ones included.
+func (s *Series) Len() int { return len(s.Values) }
+
+// Unit returns the unit of the values, e.g. KWH.
+func (s *Series) Unit() string { return s.unit.Value() }
+
+// CurveType returns the curve type of the points.
+func (s *Series) CurveType() string { return s.curveType.Value() }
+
+// BusinessType returns the business type of the points.
+func (s *Series) BusinessType() string { return s.businessType.Value() }
+
+// End returns the end of the last point.
+func (s *Series) End() time.Time {
+	return s.at(len(s.Values))
+}
+
+// Interval returns the half-open [from, to) interval of point i.
+func (s *Series) Interval(i int) (time.Time, time.Time) {
+	return s.at(i), s.at(i + 1)
+}
+
+// Quality returns the quality of point i, and false when the point is missing.
+func (s *Series) Quality(i int) (string, bool) {
+	for _, q := range s.qualities {
+		if i/64 < len(q.bits) && q.bits[i/64]&(1<<(i%64)) != 0 {
+			return q.code.Value(), true
+		}
+	}
+	return "", false
+}
+
+// Points yields the points of the series as FlatTimeSeriesPoint, leaving out the missing
+// ones.
+func (s *Series) Points() iter.Seq[FlatTimeSeriesPoint] {
+	return func(yield func(FlatTimeSeriesPoint) bool) {
+		for i, value := range s.Values {
+			quality, ok := s.Quality(i)
+			if !ok {
+				continue
+			}
+			from, to := s.Interval(i)
+			point := FlatTimeSeriesPoint{
+				From:         from,
+				To:           to,
+				Measurement:  value,
+				Quality:      quality,
+				Unit:         s.Unit(),
+				CurveType:    s.CurveType(),
+				BusinessType: s.BusinessType(),
+				Resolution:   s.Resolution,
+			}
+			if !yield(point) {
+				return
+			}
+		}
+	}
+}
+
+// Flatten returns the points of the series as FlatTimeSeriesPoint, leaving out the
+// missing ones.
+func (s *Series) Flatten() []FlatTimeSeriesPoint {
+	fts := make([]FlatTimeSeriesPoint, 0, s.present())
+	for point := range s.Points() {
+		fts = append(fts, point)
+	}
+	return fts
+}
+
+// Sum returns the sum of the values, leaving out the missing points.
+func (s *Series) Sum() float64 {
+	var sum float64
+	for _, value := range s.Values {
+		if !math.IsNaN(value) {
+			sum += value
+		}
+	}
+	return sum
+}
+
+// Slice returns the points starting within [from, to). Its Values share the backing
+// array of s, so writing to one writes to the other; the qualities are copied.
+func (s *Series) Slice(from, to time.Time) *Series {
+	i := min(max(s.ceil(from), 0), len(s.Values))
+	j := min(max(s.ceil(to), i), len(s.Values))
+
+	sliced := *s
+	sliced.Start = s.at(i)
+	sliced.Values = s.Values[i:j:j]
+	sliced.qualities = make([]qualityBitmap, 0, len(s.qualities))
+	for _, q := range s.qualities {
+		shifted := qualityBitmap{code: q.code, bits: make([]uint64, (j-i+63)/64)}
+		for k := i; k < j; k++ {
+			if k/64 < len(q.bits) && q.bits[k/64]&(1<<(k%64)) != 0 {
+				shifted.bits[(k-i)/64] |= 1 << ((k - i) % 64)
+			}
+		}
+		sliced.qualities = append(sliced.qualities, shifted)
+	}
+	return &sliced
+}
+
+// Align returns a and b sliced to the range both cover, so that point i of one is point
+// i of the other. Both must have the same resolution on the same grid; a day resolution
+// spelled PT1D and one spelled P1D are the same. Two series that do not overlap align to
+// two empty ones.
+func Align(a, b *Series) (*Series, *Series, error) {
+	stepA, _ := seriesStep(a.Resolution)
+	stepB, _ := seriesStep(b.Resolution)
+	if stepA != stepB {
+		return nil, nil, fmt.Errorf("cannot align %s with %s", a.Resolution, b.Resolution)
+	}
+	if !a.at(a.index(b.Start)).Equal(b.Start) {
+		return nil, nil, fmt.Errorf("cannot align: %s is not on the %s grid from %s", b.Start.Format(time.RFC3339), a.Resolution, a.Start.Format(time.RFC3339))
+	}
+
+	from := a.Start
+	if b.Start.After(from) {
+		from = b.Start
+	}
+	to := a.End()
+	if b.End().Before(to) {
+		to = b.End()
+	}
+	if to.Before(from) {
+		to = from
+	}
+	return a.Slice(from, to), b.Slice(from, to), nil
+}
+
+// gridStep is the step of a resolution on a grid: a fixed duration, or a number of
+// calendar days, months or years.
+type gridStep struct {
+	duration            time.Duration
+	days, months, years int
+}
+
+// seriesStep returns the step of a resolution, and false for one without a fixed step,
+// such as PXD.
+func seriesStep(resolution Resolution) (gridStep, bool) {
+	switch resolution {
+	case PT15M:
+		return gridStep{duration: 15 * time.Minute}, true
+	case PT1H:
+		return gridStep{duration: time.Hour}, true
+	case PT1D, P1D:
+		return gridStep{days: 1}, true
+	case P1M:
+		return gridStep{months: 1}, true
+	case PT1Y, P1Y:
+		return gridStep{years: 1}, true
+	}
+	return gridStep{}, false
+}
+
+// add returns t moved n steps, stepping by calendar unit in Copenhagen time.
+func (step gridStep) add(t time.Time, n int) time.Time {
+	if step.duration > 0 {
+		return t.Add(time.Duration(n) * step.duration)
+	}
+	return t.In(cph).AddDate(n*step.years, n*step.months, n*step.days)
+}
+
+// at returns the start of point i, which may lie past the last point.
+func (s *Series) at(i int) time.Time {
+	step, _ := seriesStep(s.Resolution)
+	return step.add(s.Start, i)
+}
+
+// index returns the index of the point covering t, which may lie outside the series.
+func (s *Series) index(t time.Time) int {
+	step, _ := seriesStep(s.Resolution)
+	if step.duration > 0 {
+		d := t.Sub(s.Start)
+		i := int(d / step.duration)
+		if d < 0 && d%step.duration != 0 {
+			i--
+		}
+		return i
+	}
+
+	// Count calendar units between the local dates, then correct for the time of day.
+	start, local := s.Start, t.In(cph)
+	var i int
+	switch {
+	case step.days > 0:
+		i = int(civilDate(local).Sub(civilDate(start)).Hours() / 24)
+	case step.months > 0:
+		i = (local.Year()-start.Year())*12 + int(local.Month()-start.Month())
+	default:
+		i = local.Year() - start.Year()
+	}
+	if s.at(i).After(t) {
+		i--
+	}
+	return i
+}
+
+// ceil returns the index of the first point starting at or after t.
+func (s *Series) ceil(t time.Time) int {
+	i := s.index(t)
+	if s.at(i).Before(t) {
+		i++
+	}
+	return i
+}
+
+// civilDate returns the date of t as midnight UTC, for counting days without daylight
+// saving time in the way.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// setQuality sets the quality of point i.
+func (s *Series) setQuality(i int, quality string) {
+	q := -1
+	for k := range s.qualities {
+		if s.qualities[k].code.Value() == quality {
+			q = k
+			break
+		}
+	}
+	if q < 0 {
+		s.qualities = append(s.qualities, qualityBitmap{code: unique.Make(quality)})
+		q = len(s.qualities) - 1
+	}
+	bitmap := &s.qualities[q]
+	for len(bitmap.bits) <= i/64 {
+		bitmap.bits = append(bitmap.bits, 0)
+	}
+	bitmap.bits[i/64] |= 1 << (i % 64)
+}
+
+// present returns the number of points that are not missing.
+func (s *Series) present() int {
+	n := 0
+	for _, q := range s.qualities {
+		for _, word := range q.bits {
+			n += bits.OnesCount64(word)
+		}
+	}
+	return n
+}
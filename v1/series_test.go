@@ -0,0 +1,331 @@
+package eloverblik
+
+import (
+	"encoding/json"
+	"math"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gridPoints returns n points of a resolution from start, stepping as Flatten does, with
+// the measurement of point i being i.
+func gridPoints(start time.Time, resolution Resolution, n int) []FlatTimeSeriesPoint {
+	s := &Series{Start: start.In(cph), Resolution: resolution}
+	points := make([]FlatTimeSeriesPoint, n)
+	for i := range points {
+		from, to := s.Interval(i)
+		points[i] = FlatTimeSeriesPoint{
+			From: from, To: to, Measurement: float64(i), Quality: "A04",
+			Unit: "KWH", CurveType: "A01", BusinessType: "A04", Resolution: resolution,
+		}
+	}
+	return points
+}
+
+func TestNewSeries(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+
+	t.Run("round trips points", func(t *testing.T) {
+		points := gridPoints(start, PT15M, 200)
+		points[7].Quality = "A03"
+		points[150].Quality = "A02"
+
+		s, err := NewSeries("571313180100000001", slices.Values(points))
+		require.NoError(t, err)
+		assert.Equal(t, 200, s.Len())
+		assert.Equal(t, "KWH", s.Unit())
+		assert.Equal(t, "A01", s.CurveType())
+		assert.Equal(t, "A04", s.BusinessType())
+		assert.True(t, s.End().Equal(start.Add(50*time.Hour)))
+
+		quality, ok := s.Quality(7)
+		assert.True(t, ok)
+		assert.Equal(t, "A03", quality)
+
+		flat := s.Flatten()
+		require.Len(t, flat, len(points))
+		for i := range points {
+			assert.True(t, points[i].From.Equal(flat[i].From), "point %d", i)
+			assert.True(t, points[i].To.Equal(flat[i].To), "point %d", i)
+			points[i].From, points[i].To = flat[i].From, flat[i].To
+		}
+		assert.Equal(t, points, flat)
+	})
+
+	t.Run("gaps are missing points", func(t *testing.T) {
+		points := gridPoints(start, PT1H, 10)
+		points = append(points[:3], points[6:]...)
+
+		s, err := NewSeries("", slices.Values(points))
+		require.NoError(t, err)
+		assert.Equal(t, 10, s.Len())
+		assert.True(t, math.IsNaN(s.Values[4]))
+		_, ok := s.Quality(4)
+		assert.False(t, ok)
+		assert.Equal(t, float64(0+1+2+6+7+8+9), s.Sum())
+		assert.Len(t, s.Flatten(), 7)
+	})
+
+	t.Run("steps days across daylight saving time", func(t *testing.T) {
+		points := gridPoints(time.Date(2024, 10, 25, 0, 0, 0, 0, cph), PT1D, 5)
+		assert.Equal(t, 25*time.Hour, points[2].To.Sub(points[2].From))
+
+		s, err := NewSeries("", slices.Values(points))
+		require.NoError(t, err)
+		assert.Equal(t, 5, s.Len())
+		assert.Equal(t, 3, s.index(time.Date(2024, 10, 28, 12, 0, 0, 0, cph)))
+	})
+
+	t.Run("steps months", func(t *testing.T) {
+		points := gridPoints(start, P1M, 14)
+		s, err := NewSeries("", slices.Values(points))
+		require.NoError(t, err)
+		assert.True(t, s.End().Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, cph)))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		points := gridPoints(start, PT1H, 3)
+
+		_, err := NewSeries("", slices.Values([]FlatTimeSeriesPoint(nil)))
+		assert.EqualError(t, err, "no points")
+
+		mixed := slices.Clone(points)
+		mixed[1].Unit = "KVARH"
+		_, err = NewSeries("", slices.Values(mixed))
+		assert.ErrorContains(t, err, "unit KVARH, expected KWH")
+
+		reversed := slices.Clone(points)
+		slices.Reverse(reversed)
+		_, err = NewSeries("", slices.Values(reversed))
+		assert.ErrorContains(t, err, "not after the point before it")
+
+		offGrid := slices.Clone(points)
+		offGrid[2].From = offGrid[2].From.Add(time.Minute)
+		offGrid[2].To = offGrid[2].To.Add(time.Minute)
+		_, err = NewSeries("", slices.Values(offGrid))
+		assert.ErrorContains(t, err, "not on the PT1H grid")
+
+		partial := slices.Clone(points)
+		partial[2].To = partial[2].To.Add(-30 * time.Minute)
+		_, err = NewSeries("", slices.Values(partial))
+		assert.ErrorContains(t, err, "not after a whole PT1H")
+
+		variable := gridPoints(start, PXD, 1)
+		_, err = NewSeries("", slices.Values(variable))
+		assert.EqualError(t, err, "resolution PXD has no fixed step")
+	})
+}
+
+// periodResponse returns a gettimeseries response body of a metering point with a period
+// of the resolution for each pair of consecutive bounds, of a single point each, as the
+// API answers Day, Month and Year.
+func periodResponse(t *testing.T, resolution string, bounds ...time.Time) []byte {
+	t.Helper()
+
+	const id = "571313180100000001"
+	var ts TimeSeries
+	ts.Success = true
+	ts.ID = id
+	series := TimeSeriesTimeSeriesResponse{
+		MRID:                  id,
+		BusinessType:          "A04",
+		CurveType:             "A01",
+		MeasurementUnitName:   "KWH",
+		MarketEvaluationPoint: MarketEvaluationPointResponse{MRID: MRIDResponse{CodingScheme: "A10", Name: id}},
+	}
+	for i := range len(bounds) - 1 {
+		series.Periods = append(series.Periods, PeriodResponse{
+			Resolution:   resolution,
+			TimeInterval: TimeInterval{Start: bounds[i].UTC(), End: bounds[i+1].UTC()},
+			Points:       []PointResponse{{Position: 1, OutQuantityQuantity: float64(i + 1), OutQuantityQuality: "A04"}},
+		})
+	}
+	ts.MyEnergyDataMarketDocument.TimeSeries = []TimeSeriesTimeSeriesResponse{series}
+
+	body, err := json.Marshal(map[string]any{"result": []TimeSeries{ts}})
+	require.NoError(t, err)
+	return body
+}
+
+func TestSeriesFromResponse(t *testing.T) {
+	// roundTrip builds a Series from each time series of a decoded response, and checks
+	// that it holds every point without gaps and flattens back to what Flatten makes of
+	// the response.
+	roundTrip := func(t *testing.T, body []byte) {
+		t.Helper()
+
+		tss, _, err := flattenResponse(body)
+		require.NoError(t, err)
+		require.NotEmpty(t, tss)
+		for i := range tss {
+			want := tss[i].Flatten()
+			s, err := NewSeries(tss[i].MeteringPointID(), tss[i].Points())
+			require.NoError(t, err)
+			assert.Equal(t, len(want), s.Len(), "no missing points")
+			assert.Equal(t, tss[i].MeteringPointID(), s.MeteringPointID)
+
+			got := s.Flatten()
+			require.Len(t, got, len(want))
+			for j := range want {
+				assert.True(t, want[j].From.Equal(got[j].From), "point %d from: got %s, want %s", j, got[j].From, want[j].From)
+				assert.True(t, want[j].To.Equal(got[j].To), "point %d to: got %s, want %s", j, got[j].To, want[j].To)
+				want[j].From, want[j].To = got[j].From, got[j].To
+			}
+			assert.Equal(t, want, got)
+		}
+	}
+
+	t.Run("quarter hours across daylight saving time", func(t *testing.T) {
+		// 100 days from 1 January pass the last Sunday of March
+		roundTrip(t, quarterHourResponse(t, 2, 100))
+	})
+
+	t.Run("days across daylight saving time", func(t *testing.T) {
+		var bounds []time.Time
+		for day := 24; day <= 31; day++ {
+			bounds = append(bounds, time.Date(2024, 10, day, 0, 0, 0, 0, cph))
+		}
+		roundTrip(t, periodResponse(t, "PT1D", bounds...))
+	})
+
+	t.Run("months across both transitions", func(t *testing.T) {
+		var bounds []time.Time
+		for month := time.January; month <= time.December+1; month++ {
+			bounds = append(bounds, time.Date(2024, month, 1, 0, 0, 0, 0, cph))
+		}
+		roundTrip(t, periodResponse(t, "P1M", bounds...))
+	})
+
+	t.Run("a partial month is refused", func(t *testing.T) {
+		tss, _, err := flattenResponse(periodResponse(t, "P1M",
+			time.Date(2024, 1, 15, 0, 0, 0, 0, cph),
+			time.Date(2024, 2, 1, 0, 0, 0, 0, cph),
+			time.Date(2024, 3, 1, 0, 0, 0, 0, cph),
+		))
+		require.NoError(t, err)
+		_, err = NewSeries(tss[0].MeteringPointID(), tss[0].Points())
+		assert.ErrorContains(t, err, "not after a whole P1M")
+	})
+}
+
+func TestSeriesSlice(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	points := gridPoints(start, PT15M, 96*3)
+	points[100].Quality = "A03"
+	s, err := NewSeries("", slices.Values(points))
+	require.NoError(t, err)
+
+	day := s.Slice(start.AddDate(0, 0, 1), start.AddDate(0, 0, 2))
+	assert.Equal(t, 96, day.Len())
+	assert.True(t, day.Start.Equal(start.AddDate(0, 0, 1)))
+	assert.Equal(t, float64(96), day.Values[0])
+	quality, ok := day.Quality(4)
+	assert.True(t, ok)
+	assert.Equal(t, "A03", quality)
+
+	// A bound within a point starts at the next one, and bounds outside the series clip.
+	partial := s.Slice(start.Add(7*time.Minute), start.AddDate(1, 0, 0))
+	assert.Equal(t, 96*3-1, partial.Len())
+	assert.True(t, partial.Start.Equal(start.Add(15*time.Minute)))
+
+	empty := s.Slice(start.AddDate(1, 0, 0), start.AddDate(2, 0, 0))
+	assert.Equal(t, 0, empty.Len())
+	assert.Equal(t, float64(0), empty.Sum())
+}
+
+func TestAlign(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+
+	a, err := NewSeries("a", slices.Values(gridPoints(start, PT1H, 48)))
+	require.NoError(t, err)
+	b, err := NewSeries("b", slices.Values(gridPoints(start.Add(10*time.Hour), PT1H, 48)))
+	require.NoError(t, err)
+
+	alignedA, alignedB, err := Align(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, 38, alignedA.Len())
+	assert.Equal(t, 38, alignedB.Len())
+	assert.True(t, alignedA.Start.Equal(alignedB.Start))
+	assert.Equal(t, float64(10), alignedA.Values[0])
+	assert.Equal(t, float64(0), alignedB.Values[0])
+
+	t.Run("no overlap", func(t *testing.T) {
+		later, err := NewSeries("", slices.Values(gridPoints(start.AddDate(0, 1, 0), PT1H, 5)))
+		require.NoError(t, err)
+		alignedA, alignedB, err := Align(a, later)
+		require.NoError(t, err)
+		assert.Equal(t, 0, alignedA.Len())
+		assert.Equal(t, 0, alignedB.Len())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		quarters, err := NewSeries("", slices.Values(gridPoints(start, PT15M, 5)))
+		require.NoError(t, err)
+		_, _, err = Align(a, quarters)
+		assert.EqualError(t, err, "cannot align PT1H with PT15M")
+
+		offGrid, err := NewSeries("", slices.Values(gridPoints(start.Add(30*time.Minute), PT1H, 5)))
+		require.NoError(t, err)
+		_, _, err = Align(a, offGrid)
+		assert.ErrorContains(t, err, "is not on the PT1H grid")
+	})
+}
+
+// BenchmarkSeries compares holding three years of quarter hour points of a metering
+// point, about 105,000 points, as []FlatTimeSeriesPoint with holding them as a Series,
+// and summing a month of each. live-B is the heap each takes.
+func BenchmarkSeries(b *testing.B) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, cph)
+	end := start.AddDate(3, 0, 0)
+	n := int(end.Sub(start) / (15 * time.Minute))
+	from, to := start.AddDate(1, 5, 0), start.AddDate(1, 6, 0)
+
+	b.Run("flat", func(b *testing.B) {
+		var points []FlatTimeSeriesPoint
+		live := liveHeap(func(sample func()) {
+			points = gridPoints(start, PT15M, n)
+			sample()
+		})
+		for b.Loop() {
+			var sum float64
+			for _, point := range points {
+				if !point.From.Before(from) && point.From.Before(to) {
+					sum += point.Measurement
+				}
+			}
+		}
+		b.ReportMetric(live, "live-B")
+	})
+
+	b.Run("series", func(b *testing.B) {
+		points := gridPoints(start, PT15M, n)
+		var s *Series
+		live := liveHeap(func(sample func()) {
+			var err error
+			s, err = NewSeries("", slices.Values(points))
+			if err != nil {
+				b.Fatal(err)
+			}
+			sample()
+		})
+		runtime.KeepAlive(points)
+		for b.Loop() {
+			s.Slice(from, to).Sum()
+		}
+		b.ReportMetric(live, "live-B")
+	})
+
+	b.Run("new series", func(b *testing.B) {
+		points := gridPoints(start, PT15M, n)
+		for b.Loop() {
+			if _, err := NewSeries("", slices.Values(points)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,361 @@
+package eloverblik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+// TimeSeriesStream decodes the points of a gettimeseries response as the body is read,
+// rather than decoding the whole response into []TimeSeries and flattening a copy of it.
+// Only the period being read is held in memory - a day of points for the Quarter and
+// Hour aggregations - however large the response is.
+//
+// It is used like a bufio.Scanner:
+//
+//	stream, err := client.StreamTimeSeries(ids, from, to, eloverblik.Quarter)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		total += stream.Point().Measurement
+//	}
+//	if err := stream.Err(); err != nil {
+//		return err
+//	}
+//
+// The points are the ones Flatten returns, in the same order, each with the ID of its
+// metering point. A stream has no items to inspect afterwards, so the metering points
+// the API failed for are always reported: by Err, as a *PartialResultError.
+type TimeSeriesStream struct {
+	body     io.Reader
+	next     func() (streamedPoint, bool)
+	stop     func()
+	current  streamedPoint
+	err      error
+	done     bool
+	failures []ItemError
+}
+
+// streamedPoint is a point and the metering point it belongs to.
+type streamedPoint struct {
+	meteringPointID string
+	point           FlatTimeSeriesPoint
+}
+
+// errStreamStopped ends the decoding when the stream is closed before its end.
+var errStreamStopped = errors.New("stream stopped")
+
+// NewTimeSeriesStream returns a stream decoding the points of a gettimeseries response
+// body, {"result": [...]}, from r. Close closes r when it is an io.Closer.
+func NewTimeSeriesStream(r io.Reader) *TimeSeriesStream {
+	s := &TimeSeriesStream{body: r}
+	s.next, s.stop = iter.Pull(func(yield func(streamedPoint) bool) {
+		err := s.decode(json.NewDecoder(r), yield)
+		switch {
+		case err == nil:
+			s.done = true
+		case !errors.Is(err, errStreamStopped):
+			s.err = fmt.Errorf("failed to decode time series: %w", err)
+		}
+	})
+	return s
+}
+
+// Next advances the stream to the next point, which Point then returns. It returns false
+// at the end of the response or on an error, which Err then returns.
+func (s *TimeSeriesStream) Next() bool {
+	if s.next == nil {
+		return false
+	}
+	current, ok := s.next()
+	if !ok {
+		s.stop()
+		s.next = nil
+		return false
+	}
+	s.current = current
+	return true
+}
+
+// All yields the remaining points of the stream with the ID of their metering point, for
+// use with range. Err reports why it ended, as after Next:
+//
+//	for id, point := range stream.All() {
+//		totals[id] += point.Measurement
+//	}
+//	if err := stream.Err(); err != nil {
+//		return err
+//	}
+func (s *TimeSeriesStream) All() iter.Seq2[string, FlatTimeSeriesPoint] {
+	return func(yield func(string, FlatTimeSeriesPoint) bool) {
+		for s.Next() {
+			if !yield(s.MeteringPointID(), s.Point()) {
+				return
+			}
+		}
+	}
+}
+
+// Point returns the point Next advanced to.
+func (s *TimeSeriesStream) Point() FlatTimeSeriesPoint {
+	return s.current.point
+}
+
+// MeteringPointID returns the ID of the metering point the current point belongs to.
+func (s *TimeSeriesStream) MeteringPointID() string {
+	return s.current.meteringPointID
+}
+
+// Err returns the error that ended the stream, nil at its end. When the response ended
+// and the API failed for some metering points, it returns a *PartialResultError listing
+// them.
+func (s *TimeSeriesStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.done && len(s.failures) > 0 {
+		return &PartialResultError{Failures: s.failures}
+	}
+	return nil
+}
+
+// Close stops the stream and closes the body it reads. It is safe to call at any point,
+// and more than once.
+func (s *TimeSeriesStream) Close() error {
+	if s.next != nil {
+		s.stop()
+		s.next = nil
+	}
+	if closer, ok := s.body.(io.Closer); ok {
+		s.body = nil
+		return closer.Close()
+	}
+	return nil
+}
+
+// decode walks the response and yields every point of it.
+func (s *TimeSeriesStream) decode(dec *json.Decoder, yield func(streamedPoint) bool) error {
+	return decodeObject(dec, func(key string) error {
+		if key != "result" {
+			return skipValue(dec)
+		}
+		return decodeArray(dec, func() error {
+			return s.decodeItem(dec, yield)
+		})
+	})
+}
+
+// decodeItem decodes an item of the result: the document of a metering point and its
+// status.
+func (s *TimeSeriesStream) decodeItem(dec *json.Decoder, yield func(streamedPoint) bool) error {
+	var status StatusResponse
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "MyEnergyData_MarketDocument":
+			return decodeObject(dec, func(key string) error {
+				if key != "TimeSeries" {
+					return skipValue(dec)
+				}
+				return decodeArray(dec, func() error {
+					return decodeSeries(dec, yield)
+				})
+			})
+		case "success":
+			return dec.Decode(&status.Success)
+		case "errorCode":
+			return dec.Decode(&status.ErrorCode)
+		case "errorText":
+			return dec.Decode(&status.ErrorText)
+		case "id":
+			return dec.Decode(&status.ID)
+		}
+		return skipValue(dec)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := status.Err(); err != nil {
+		s.failures = append(s.failures, ItemError{
+			MeteringPointID: status.ID,
+			Code:            status.ErrorCode,
+			Text:            status.ErrorText,
+			Err:             err,
+		})
+	}
+	return nil
+}
+
+// decodeSeries decodes a TimeSeries of a document one period at a time, yielding the
+// points of each as it is read, with the series' own fields read before it. The API sends
+// those fields first. A period read before the mRID is held back until the end of the
+// series, so its points carry the metering point and every field; a series without an
+// mRID is so read whole before any of its points is yielded.
+func decodeSeries(dec *json.Decoder, yield func(streamedPoint) bool) error {
+	var series TimeSeriesTimeSeriesResponse
+	var held []PeriodResponse
+
+	emit := func(period *PeriodResponse) error {
+		for _, point := range period.Points {
+			if !yield(streamedPoint{meteringPointID: series.MRID, point: flattenPoint(&series, period, point)}) {
+				return errStreamStopped
+			}
+		}
+		return nil
+	}
+
+	err := decodeObject(dec, func(key string) error {
+		var err error
+		switch key {
+		case "mRID":
+			err = dec.Decode(&series.MRID)
+		case "businessType":
+			err = dec.Decode(&series.BusinessType)
+		case "curveType":
+			err = dec.Decode(&series.CurveType)
+		case "measurement_Unit.name":
+			err = dec.Decode(&series.MeasurementUnitName)
+		case "Period":
+			return decodeArray(dec, func() error {
+				var period PeriodResponse
+				if err := dec.Decode(&period); err != nil {
+					return err
+				}
+				if series.MRID == "" {
+					held = append(held, period)
+					return nil
+				}
+				return emit(&period)
+			})
+		default:
+			return skipValue(dec)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range held {
+		if err := emit(&held[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeObject reads a JSON object, calling fn with each key for it to read the value.
+// null reads as an empty object.
+func decodeObject(dec *json.Decoder, fn func(key string) error) error {
+	if ok, err := openValue(dec, '{'); !ok || err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// decodeArray reads a JSON array, calling fn for it to read each element. null reads as
+// an empty array.
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	if ok, err := openValue(dec, '['); !ok || err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// openValue reads the opening delimiter of an object or array. It returns false for
+// null, and an error for anything else.
+func openValue(dec *json.Decoder, delim json.Delim) (bool, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if token == nil {
+		return false, nil
+	}
+	if token != delim {
+		return false, fmt.Errorf("expected %s, got %v", delim, token)
+	}
+	return true, nil
+}
+
+// skipValue reads past a value, without decoding it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// StreamTimeSeries fetches time series like GetTimeSeries, but returns a stream decoding
+// their points as the response is read. The caller must Close the stream.
+//
+// Failed requests are reported as GetTimeSeries reports them, before any point is read.
+// As with the export endpoints, the response skips resty's response middleware, so an
+// open circuit breaker fails the call but its responses are not counted by the breaker.
+func (c *client) StreamTimeSeries(meteringPointIDs []string, from, to time.Time, aggregation Aggregation) (*TimeSeriesStream, error) {
+
+	// Ensure access token is fresh
+	accessToken, err := c.GetDataAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("/meterdata/gettimeseries/%s/%s/%s", from.In(cph).Format(time.DateOnly), to.In(cph).Format(time.DateOnly), aggregation)
+
+	res, err := c.resty.R().
+		SetContext(readOnly).
+		SetHeader("Accept", "application/json").
+		SetAuthToken(accessToken).
+		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
+		SetDoNotParseResponse(true). // The body is decoded as it is read
+		Post(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body := res.RawBody()
+	if !res.IsSuccess() {
+		defer func() { _ = body.Close() }()
+		var apiErrBody apiErrorBody
+		if data, readErr := io.ReadAll(body); readErr == nil && len(data) > 0 {
+			_ = json.Unmarshal(data, &apiErrBody)
+		}
+		return nil, apiErrorFromBody(apiErrBody, res.StatusCode())
+	}
+
+	return NewTimeSeriesStream(body), nil
+}
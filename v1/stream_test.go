@@ -0,0 +1,331 @@
+package eloverblik
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quarterHourResponse returns a gettimeseries response body with a period of 96 quarter
+// hour points per day from 2024-01-01, for each of the given number of metering points.
+func quarterHourResponse(t testing.TB, meteringPoints, days int) []byte {
+	t.Helper()
+
+	start := time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC)
+	result := make([]TimeSeries, meteringPoints)
+	for m := range result {
+		id := fmt.Sprintf("5713131801%08d", m)
+		periods := make([]PeriodResponse, days)
+		for d := range periods {
+			points := make([]PointResponse, 96)
+			for p := range points {
+				points[p] = PointResponse{Position: p + 1, OutQuantityQuantity: float64(d*96+p) / 1000, OutQuantityQuality: "A04"}
+			}
+			from := start.AddDate(0, 0, d)
+			periods[d] = PeriodResponse{Resolution: "PT15M", TimeInterval: TimeInterval{Start: from, End: from.AddDate(0, 0, 1)}, Points: points}
+		}
+		result[m].Success = true
+		result[m].ID = id
+		result[m].MyEnergyDataMarketDocument.TimeSeries = []TimeSeriesTimeSeriesResponse{{
+			MRID:                  id,
+			BusinessType:          "A04",
+			CurveType:             "A01",
+			MeasurementUnitName:   "KWH",
+			MarketEvaluationPoint: MarketEvaluationPointResponse{MRID: MRIDResponse{CodingScheme: "A10", Name: id}},
+			Periods:               periods,
+		}}
+	}
+
+	body, err := json.Marshal(map[string]any{"result": result})
+	require.NoError(t, err)
+	return body
+}
+
+// flattenResponse decodes a response body the way GetTimeSeries does, and flattens it.
+func flattenResponse(body []byte) ([]TimeSeries, []FlatTimeSeriesPoint, error) {
+	var result struct {
+		Result []TimeSeries `json:"result"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, nil, err
+	}
+	var points []FlatTimeSeriesPoint
+	for i := range result.Result {
+		points = append(points, result.Result[i].Flatten()...)
+	}
+	return result.Result, points, nil
+}
+
+// closeRecorder is a body that records that it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+// readCounter is a body that counts the bytes read from it.
+type readCounter struct {
+	io.Reader
+	read int
+}
+
+func (r *readCounter) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestTimeSeriesStream(t *testing.T) {
+	t.Run("the points Flatten returns", func(t *testing.T) {
+		body := quarterHourResponse(t, 2, 3)
+		_, want, err := flattenResponse(body)
+		require.NoError(t, err)
+
+		stream := NewTimeSeriesStream(bytes.NewReader(body))
+		defer func() { _ = stream.Close() }()
+		var got []FlatTimeSeriesPoint
+		var ids []string
+		for stream.Next() {
+			got = append(got, stream.Point())
+			if len(ids) == 0 || ids[len(ids)-1] != stream.MeteringPointID() {
+				ids = append(ids, stream.MeteringPointID())
+			}
+		}
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, want, got)
+		assert.Equal(t, []string{"571313180100000000", "571313180100000001"}, ids)
+	})
+
+	t.Run("fields after the periods", func(t *testing.T) {
+		body := `{"result": [{"MyEnergyData_MarketDocument": {"TimeSeries": [{
+			"Period": [{"resolution": "PT1H", "timeInterval": {"start": "2023-12-31T23:00:00Z", "end": "2024-01-01T01:00:00Z"},
+				"Point": [{"position": "1", "out_Quantity.quantity": "0.5", "out_Quantity.quality": "A04"},
+				          {"position": "2", "out_Quantity.quantity": "0.25", "out_Quantity.quality": "A03"}]}],
+			"mRID": "571313180100000001", "businessType": "A04", "curveType": "A01", "measurement_Unit.name": "KWH"
+		}]}, "success": true, "id": "571313180100000001"}]}`
+		_, want, err := flattenResponse([]byte(body))
+		require.NoError(t, err)
+
+		stream := NewTimeSeriesStream(strings.NewReader(body))
+		var got []FlatTimeSeriesPoint
+		for stream.Next() {
+			assert.Equal(t, "571313180100000001", stream.MeteringPointID())
+			got = append(got, stream.Point())
+		}
+		assert.NoError(t, stream.Err())
+		require.Len(t, got, 2)
+		assert.Equal(t, want, got)
+		assert.Equal(t, "KWH", got[1].Unit)
+	})
+
+	t.Run("a missing field", func(t *testing.T) {
+		body := bytes.Replace(quarterHourResponse(t, 1, 30), []byte(`"curveType":"A01",`), nil, 1)
+		require.NotContains(t, string(body), "curveType")
+		_, want, err := flattenResponse(body)
+		require.NoError(t, err)
+
+		reader := &readCounter{Reader: bytes.NewReader(body)}
+		stream := NewTimeSeriesStream(reader)
+		require.True(t, stream.Next())
+		assert.Less(t, reader.read, len(body)/2, "the first point is yielded before the series is read")
+
+		got := []FlatTimeSeriesPoint{stream.Point()}
+		for stream.Next() {
+			got = append(got, stream.Point())
+		}
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("failed metering points", func(t *testing.T) {
+		body := `{"result": [
+			{"MyEnergyData_MarketDocument": null, "success": false, "errorCode": 20008, "errorText": "MeteringPointNotFound", "id": "571313180100000009"},
+			{"MyEnergyData_MarketDocument": {"TimeSeries": [{"mRID": "571313180100000001", "businessType": "A04", "curveType": "A01", "measurement_Unit.name": "KWH",
+				"Period": [{"resolution": "PT1D", "timeInterval": {"start": "2023-12-31T23:00:00Z", "end": "2024-01-01T23:00:00Z"},
+					"Point": [{"position": "1", "out_Quantity.quantity": "12.5", "out_Quantity.quality": "A04"}]}]}]},
+			 "success": true, "id": "571313180100000001"}
+		]}`
+
+		stream := NewTimeSeriesStream(strings.NewReader(body))
+		points := 0
+		for stream.Next() {
+			points++
+			assert.Equal(t, 12.5, stream.Point().Measurement)
+		}
+		assert.Equal(t, 1, points)
+
+		var partial *PartialResultError
+		require.ErrorAs(t, stream.Err(), &partial)
+		require.Len(t, partial.Failures, 1)
+		assert.Equal(t, "571313180100000009", partial.Failures[0].MeteringPointID)
+		assert.ErrorIs(t, stream.Err(), ErrorMeteringPointNotFound)
+	})
+
+	t.Run("closed early", func(t *testing.T) {
+		body := &closeRecorder{Reader: bytes.NewReader(quarterHourResponse(t, 1, 2))}
+		stream := NewTimeSeriesStream(body)
+		require.True(t, stream.Next())
+		require.True(t, stream.Next())
+
+		assert.NoError(t, stream.Close())
+		assert.True(t, body.closed)
+		assert.False(t, stream.Next())
+		assert.NoError(t, stream.Err())
+		assert.NoError(t, stream.Close())
+	})
+
+	t.Run("a stream ranged over", func(t *testing.T) {
+		stream := NewTimeSeriesStream(bytes.NewReader(quarterHourResponse(t, 2, 2)))
+		defer func() { _ = stream.Close() }()
+
+		totals := map[string]int{}
+		for id, point := range stream.All() {
+			assert.Equal(t, PT15M, point.Resolution)
+			totals[id]++
+		}
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, map[string]int{"571313180100000000": 192, "571313180100000001": 192}, totals)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		stream := NewTimeSeriesStream(strings.NewReader(`{"result": [{"MyEnergyData_MarketDocument": {"TimeSeries": [{"mRID": "5713`))
+		assert.False(t, stream.Next())
+		assert.ErrorContains(t, stream.Err(), "failed to decode time series")
+
+		stream = NewTimeSeriesStream(strings.NewReader(`{"result": {}}`))
+		assert.False(t, stream.Next())
+		assert.EqualError(t, stream.Err(), "failed to decode time series: expected [, got {")
+	})
+}
+
+func TestStreamTimeSeries(t *testing.T) {
+	mockResty := resty.New()
+	httpmock.ActivateNonDefault(mockResty.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	c := &client{
+		accessToken: "test-access-token",
+		resty:       mockResty,
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	to := time.Date(2024, 1, 3, 0, 0, 0, 0, cph)
+	path := "/meterdata/gettimeseries/2024-01-01/2024-01-03/Quarter"
+
+	t.Run("streams the points of the response", func(t *testing.T) {
+		httpmock.Reset()
+		body := quarterHourResponse(t, 1, 2)
+		httpmock.RegisterResponder("POST", path, func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "Bearer test-access-token", req.Header.Get("Authorization"))
+			return httpmock.NewBytesResponse(http.StatusOK, body), nil
+		})
+
+		stream, err := c.StreamTimeSeries([]string{"571313180100000000"}, from, to, Quarter)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		points := 0
+		for stream.Next() {
+			points++
+		}
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, 2*96, points)
+	})
+
+	t.Run("reports a failed request", func(t *testing.T) {
+		httpmock.Reset()
+		httpmock.RegisterResponder("POST", path, httpmock.NewStringResponder(http.StatusBadRequest, `"[20008] MeteringPointNotFound"`))
+
+		stream, err := c.StreamTimeSeries([]string{"571313180100000000"}, from, to, Quarter)
+		assert.Nil(t, stream)
+		assert.True(t, errors.Is(err, ErrorMeteringPointNotFound), "got %v", err)
+	})
+}
+
+// liveHeap returns the bytes on the heap that are still reachable when sample is called,
+// above what was before run. run calls sample at the point it holds the most.
+func liveHeap(run func(sample func())) float64 {
+	var before, at runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	run(func() {
+		runtime.GC()
+		runtime.ReadMemStats(&at)
+	})
+	return float64(at.HeapAlloc) - float64(before.HeapAlloc)
+}
+
+// BenchmarkTimeSeriesDecode compares decoding a year of quarter hour points for ten
+// metering points, about 350,000 points, into []TimeSeries and flattening it, with
+// streaming the points. live-B is the heap still in use at the peak of each, sampled
+// once; B/op counts every allocation, freed or not.
+func BenchmarkTimeSeriesDecode(b *testing.B) {
+	body := quarterHourResponse(b, MaxMeteringPointsPerRequest, 365)
+	b.Logf("response: %d bytes", len(body))
+
+	b.Run("decode then flatten", func(b *testing.B) {
+		live := liveHeap(func(sample func()) {
+			tss, points, err := flattenResponse(body)
+			if err != nil {
+				b.Fatal(err)
+			}
+			sample()
+			runtime.KeepAlive(tss)
+			runtime.KeepAlive(points)
+		})
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			if _, _, err := flattenResponse(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(live, "live-B")
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		// The caller keeps nothing but a running total, as an aggregate would.
+		live := liveHeap(func(sample func()) {
+			stream := NewTimeSeriesStream(bytes.NewReader(body))
+			for n := 0; stream.Next(); n++ {
+				if n == 175_000 {
+					sample()
+				}
+			}
+			if err := stream.Err(); err != nil {
+				b.Fatal(err)
+			}
+		})
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			var total float64
+			stream := NewTimeSeriesStream(bytes.NewReader(body))
+			for stream.Next() {
+				total += stream.Point().Measurement
+			}
+			if err := stream.Err(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(live, "live-B")
+	})
+}
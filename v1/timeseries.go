@@ -3,6 +3,7 @@ package eloverblik
 import (
 	"fmt"
 	"io"
+	"iter"
 	"time"
 )
 
@@ -11,18 +12,23 @@ type TimeSeries struct {
 	StatusResponse
 }
 
+// MyEnergyDataMarketDocumentResponse is the IEC CIM MyEnergyData_MarketDocument, as the
+// API renders it in JSON. Its xml tags give the ESMP layout of the same document, see
+// ParseMyEnergyDataMarketDocument.
 type MyEnergyDataMarketDocumentResponse struct {
-	MRID                        string                         `json:"mRID"`
-	CreatedDateTime             time.Time                      `json:"createdDateTime"`
-	SenderMarketParticipantName string                         `json:"sender_MarketParticipant.name"`
-	SenderMarketParticipantMRID MRIDResponse                   `json:"sender_MarketParticipant.mRID"`
-	PeriodTimeInterval          TimeInterval                   `json:"period.timeInterval"`
-	TimeSeries                  []TimeSeriesTimeSeriesResponse `json:"TimeSeries"`
+	MRID                        string                         `json:"mRID" xml:"mRID"`
+	CreatedDateTime             time.Time                      `json:"createdDateTime" xml:"createdDateTime"`
+	SenderMarketParticipantName string                         `json:"sender_MarketParticipant.name" xml:"sender_MarketParticipant.name"`
+	SenderMarketParticipantMRID MRIDResponse                   `json:"sender_MarketParticipant.mRID" xml:"sender_MarketParticipant.mRID"`
+	PeriodTimeInterval          TimeInterval                   `json:"period.timeInterval" xml:"period.timeInterval"`
+	TimeSeries                  []TimeSeriesTimeSeriesResponse `json:"TimeSeries" xml:"TimeSeries"`
 }
 
+// MRIDResponse is an identifier and the scheme it is coded in, e.g. A10 for a GS1 number.
+// In XML, the scheme is the codingScheme attribute and the identifier the element's text.
 type MRIDResponse struct {
-	CodingScheme string `json:"codingScheme"`
-	Name         string `json:"name"`
+	CodingScheme string `json:"codingScheme" xml:"codingScheme,attr,omitempty"`
+	Name         string `json:"name" xml:",chardata"`
 }
 
 type TimeInterval struct {
@@ -31,28 +37,28 @@ type TimeInterval struct {
 }
 
 type TimeSeriesTimeSeriesResponse struct {
-	MRID                  string                        `json:"mRID"`
-	BusinessType          string                        `json:"businessType"`
-	CurveType             string                        `json:"curveType"`
-	MeasurementUnitName   string                        `json:"measurement_Unit.name"`
-	MarketEvaluationPoint MarketEvaluationPointResponse `json:"MarketEvaluationPoint"`
-	Periods               []PeriodResponse              `json:"Period"`
+	MRID                  string                        `json:"mRID" xml:"mRID"`
+	BusinessType          string                        `json:"businessType" xml:"businessType"`
+	CurveType             string                        `json:"curveType" xml:"curveType"`
+	MeasurementUnitName   string                        `json:"measurement_Unit.name" xml:"measurement_Unit.name"`
+	MarketEvaluationPoint MarketEvaluationPointResponse `json:"MarketEvaluationPoint" xml:"MarketEvaluationPoint"`
+	Periods               []PeriodResponse              `json:"Period" xml:"Period"`
 }
 
 type MarketEvaluationPointResponse struct {
-	MRID MRIDResponse `json:"mRID"`
+	MRID MRIDResponse `json:"mRID" xml:"mRID"`
 }
 
 type PeriodResponse struct {
-	Resolution   string          `json:"resolution"`
-	TimeInterval TimeInterval    `json:"timeInterval"`
-	Points       []PointResponse `json:"point"`
+	Resolution   string          `json:"resolution" xml:"resolution"`
+	TimeInterval TimeInterval    `json:"timeInterval" xml:"timeInterval"`
+	Points       []PointResponse `json:"point" xml:"Point"`
 }
 
 type PointResponse struct {
-	Position            int     `json:"position,string"`
-	OutQuantityQuantity float64 `json:"out_Quantity.quantity,string"`
-	OutQuantityQuality  string  `json:"out_Quantity.quality"`
+	Position            int     `json:"position,string" xml:"position"`
+	OutQuantityQuantity float64 `json:"out_Quantity.quantity,string" xml:"out_Quantity.quantity"`
+	OutQuantityQuality  string  `json:"out_Quantity.quality" xml:"out_Quantity.quality"`
 }
 
 type FlatTimeSeriesPoint struct {
@@ -83,6 +89,7 @@ func (c *client) GetTimeSeries(meteringPointIDs []string, from, to time.Time, ag
 
 	// Request preflight
 	req := c.resty.R().
+		SetContext(readOnly).
 		SetHeader("Accept", "application/json").
 		SetAuthToken(accessToken).
 		SetResult(&result).
@@ -103,35 +110,74 @@ func (c *client) GetTimeSeries(meteringPointIDs []string, from, to time.Time, ag
 		return nil, err
 	}
 
-	return result.Result, err
+	return partialResult(c, result.Result)
+}
+
+// MeteringPointID returns the ID of the metering point the time series belongs to. The
+// API sends it as the mRID of each series; a result without series, such as one the API
+// answered with an error, still carries it as the ID of its status.
+func (ts *TimeSeries) MeteringPointID() string {
+	if len(ts.MyEnergyDataMarketDocument.TimeSeries) > 0 {
+		return ts.MyEnergyDataMarketDocument.TimeSeries[0].MRID
+	}
+	return ts.ID
 }
 
 // Flatten simplifies the structure received directly from the API
 func (ts *TimeSeries) Flatten() []FlatTimeSeriesPoint {
 
-	fts := make([]FlatTimeSeriesPoint, 0)
-
-	for _, ts := range ts.MyEnergyDataMarketDocument.TimeSeries {
-		for _, period := range ts.Periods {
-			for _, point := range period.Points {
+	n := 0
+	for _, series := range ts.MyEnergyDataMarketDocument.TimeSeries {
+		for _, period := range series.Periods {
+			n += len(period.Points)
+		}
+	}
 
-				from, to := pointInterval(Resolution(period.Resolution), period.TimeInterval, point.Position, len(period.Points))
+	fts := make([]FlatTimeSeriesPoint, 0, n)
+	for point := range ts.Points() {
+		fts = append(fts, point)
+	}
+	return fts
+}
 
-				fts = append(fts, FlatTimeSeriesPoint{
-					From:         from,
-					To:           to,
-					Measurement:  point.OutQuantityQuantity,
-					Quality:      point.OutQuantityQuality,
-					Unit:         ts.MeasurementUnitName,
-					CurveType:    ts.CurveType,
-					BusinessType: ts.BusinessType,
-					Resolution:   Resolution(period.Resolution),
-				})
+// Points yields the points Flatten returns, one at a time, without building the slice,
+// so a caller can filter or stop early:
+//
+//	for point := range ts.Points() {
+//		if point.From.After(cutoff) {
+//			break
+//		}
+//		total += point.Measurement
+//	}
+func (ts *TimeSeries) Points() iter.Seq[FlatTimeSeriesPoint] {
+	return func(yield func(FlatTimeSeriesPoint) bool) {
+		for i := range ts.MyEnergyDataMarketDocument.TimeSeries {
+			series := &ts.MyEnergyDataMarketDocument.TimeSeries[i]
+			for j := range series.Periods {
+				period := &series.Periods[j]
+				for _, point := range period.Points {
+					if !yield(flattenPoint(series, period, point)) {
+						return
+					}
+				}
 			}
 		}
 	}
+}
 
-	return fts
+// flattenPoint returns a point of a period of a series as a FlatTimeSeriesPoint.
+func flattenPoint(ts *TimeSeriesTimeSeriesResponse, period *PeriodResponse, point PointResponse) FlatTimeSeriesPoint {
+	from, to := pointInterval(Resolution(period.Resolution), period.TimeInterval, point.Position, len(period.Points))
+	return FlatTimeSeriesPoint{
+		From:         from,
+		To:           to,
+		Measurement:  point.OutQuantityQuantity,
+		Quality:      point.OutQuantityQuality,
+		Unit:         ts.MeasurementUnitName,
+		CurveType:    ts.CurveType,
+		BusinessType: ts.BusinessType,
+		Resolution:   Resolution(period.Resolution),
+	}
 }
 
 // pointInterval returns the half-open [from, to) interval covered by the point at the
@@ -206,6 +252,7 @@ func (c *client) ExportTimeSeries(meteringPointIDs []string, from, to time.Time,
 	path := fmt.Sprintf("/meterdata/timeseries/export/%s/%s/%s", from.In(cph).Format(time.DateOnly), to.In(cph).Format(time.DateOnly), aggregation)
 
 	res, err := c.resty.R().
+		SetContext(readOnly).
 		SetAuthToken(accessToken).
 		SetBody(meteringPointIDsToRequestStruct(meteringPointIDs)).
 		SetDoNotParseResponse(true). // We want the raw response body
@@ -3,6 +3,7 @@ package eloverblik
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"testing"
 	"time"
 
@@ -272,6 +273,35 @@ func TestFlattenResolutions(t *testing.T) {
 	}
 }
 
+func TestTimeSeriesPoints(t *testing.T) {
+	tss, want, err := flattenResponse(quarterHourResponse(t, 2, 2))
+	assert.NoError(t, err)
+
+	var got []FlatTimeSeriesPoint
+	for i := range tss {
+		got = append(got, slices.Collect(tss[i].Points())...)
+	}
+	assert.Equal(t, want, got)
+
+	t.Run("stops early", func(t *testing.T) {
+		n := 0
+		for range tss[0].Points() {
+			n++
+			if n == 10 {
+				break
+			}
+		}
+		assert.Equal(t, 10, n)
+	})
+
+	t.Run("flatten allocates once", func(t *testing.T) {
+		points := tss[0].Flatten()
+		assert.Equal(t, len(points), cap(points))
+		assert.NotNil(t, (&TimeSeries{}).Flatten(), "empty, not nil")
+	})
+
+}
+
 func TestExportTimeSeries(t *testing.T) {
 	mockResty := resty.New()
 	httpmock.ActivateNonDefault(mockResty.GetClient())
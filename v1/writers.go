@@ -0,0 +1,359 @@
+package eloverblik
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// FlatTimeSeriesWriter writes flattened time series points to a stream, one metering
+// point at a time. A FlatTimeSeriesPoint does not know which metering point it belongs
+// to, so the writer adds it as a column of its own: without it, the points of several
+// metering points written to the same file could not be told apart.
+//
+// Close flushes what the writer buffered. It does not close the underlying io.Writer.
+type FlatTimeSeriesWriter interface {
+	Write(meteringPointID string, points []FlatTimeSeriesPoint) error
+	Close() error
+}
+
+// flatTimeSeriesColumns are the columns of the CSV writer. They carry the JSON names of
+// FlatTimeSeriesPoint, so a CSV, an NDJSON and a JSON file of the same data agree.
+var flatTimeSeriesColumns = []string{
+	"meteringPointId",
+	"from",
+	"to",
+	"measurement",
+	"quality",
+	"unit",
+	"curvetype",
+	"businesstype",
+	"resolution",
+}
+
+// NewCSVWriter returns a FlatTimeSeriesWriter that writes comma separated values with a
+// header row. Times are written in RFC 3339, measurements with as many decimals as they
+// need and a '.' decimal separator.
+//
+// Example:
+//
+//	w := eloverblik.NewCSVWriter(os.Stdout)
+//	for _, ts := range timeSeries {
+//		_ = w.Write(ts.MeteringPointID(), ts.Flatten())
+//	}
+//	_ = w.Close()
+func NewCSVWriter(w io.Writer) FlatTimeSeriesWriter {
+	return &csvWriter{csv: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	csv    *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(meteringPointID string, points []FlatTimeSeriesPoint) error {
+	if !w.header {
+		if err := w.csv.Write(flatTimeSeriesColumns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+
+	for _, point := range points {
+		record := []string{
+			meteringPointID,
+			point.From.Format(time.RFC3339),
+			point.To.Format(time.RFC3339),
+			strconv.FormatFloat(point.Measurement, 'f', -1, 64),
+			point.Quality,
+			point.Unit,
+			point.CurveType,
+			point.BusinessType,
+			string(point.Resolution),
+		}
+		if err := w.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the header of an empty file, so the result is a valid CSV even when no
+// points were written, and flushes the buffered rows.
+func (w *csvWriter) Close() error {
+	if !w.header {
+		if err := w.csv.Write(flatTimeSeriesColumns); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// NewNDJSONWriter returns a FlatTimeSeriesWriter that writes newline delimited JSON: one
+// object per point, carrying the fields of FlatTimeSeriesPoint and the metering point ID.
+// Every line is written as it is encoded, so the output can be streamed into a pipeline.
+func NewNDJSONWriter(w io.Writer) FlatTimeSeriesWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+// ndjsonPoint is a point as the NDJSON writer renders it. The embedded point's fields are
+// promoted, so the line is flat rather than nesting the point under a key.
+type ndjsonPoint struct {
+	MeteringPointID string `json:"meteringPointId"`
+	FlatTimeSeriesPoint
+}
+
+func (w *ndjsonWriter) Write(meteringPointID string, points []FlatTimeSeriesPoint) error {
+	for _, point := range points {
+		if err := w.enc.Encode(ndjsonPoint{MeteringPointID: meteringPointID, FlatTimeSeriesPoint: point}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error { return nil }
+
+// parquetPoint is the row layout of the Parquet writer. Times are stored as UTC
+// timestamps in milliseconds, which every common reader maps to its native timestamp
+// type; the strings repeat heavily and are dictionary encoded.
+type parquetPoint struct {
+	MeteringPointID string    `parquet:"meteringPointId,dict"`
+	From            time.Time `parquet:"from,timestamp(millisecond)"`
+	To              time.Time `parquet:"to,timestamp(millisecond)"`
+	Measurement     float64   `parquet:"measurement"`
+	Quality         string    `parquet:"quality,dict"`
+	Unit            string    `parquet:"unit,dict"`
+	CurveType       string    `parquet:"curvetype,dict"`
+	BusinessType    string    `parquet:"businesstype,dict"`
+	Resolution      string    `parquet:"resolution,dict"`
+}
+
+// NewParquetWriter returns a FlatTimeSeriesWriter that writes an Apache Parquet file.
+// Parquet keeps its footer at the end of the file, so nothing readable is produced until
+// Close has been called.
+func NewParquetWriter(w io.Writer) FlatTimeSeriesWriter {
+	return &parquetWriter{pq: parquet.NewGenericWriter[parquetPoint](w)}
+}
+
+type parquetWriter struct {
+	pq *parquet.GenericWriter[parquetPoint]
+}
+
+func (w *parquetWriter) Write(meteringPointID string, points []FlatTimeSeriesPoint) error {
+	rows := make([]parquetPoint, 0, len(points))
+	for _, point := range points {
+		rows = append(rows, parquetPoint{
+			MeteringPointID: meteringPointID,
+			From:            point.From.UTC(),
+			To:              point.To.UTC(),
+			Measurement:     point.Measurement,
+			Quality:         point.Quality,
+			Unit:            point.Unit,
+			CurveType:       point.CurveType,
+			BusinessType:    point.BusinessType,
+			Resolution:      string(point.Resolution),
+		})
+	}
+	_, err := w.pq.Write(rows)
+	return err
+}
+
+func (w *parquetWriter) Close() error { return w.pq.Close() }
+
+// influxMeasurement is the measurement the Influx writer writes points to.
+const influxMeasurement = "eloverblik"
+
+// NewInfluxWriter returns a FlatTimeSeriesWriter that writes InfluxDB line protocol, one
+// line per point of the measurement "eloverblik", timestamped at the start of the point in
+// nanoseconds:
+//
+//	eloverblik,businesstype=A04,meteringPointId=571313180100000001,resolution=PT1H,unit=KWH measurement=0.198,quality="A04" 1704063600000000000
+//
+// InfluxDB identifies a point by its measurement, tags and timestamp, and overwrites a
+// point it already holds, so writing an overlapping range again replaces the points rather
+// than duplicating them. The tags are therefore what does not change about a point: its
+// metering point, unit, business type and resolution, the last keeping the daily and the
+// hourly points of a metering point apart. The quality does change, when an estimate is
+// replaced by a measurement, and is a field for the estimate to be overwritten.
+func NewInfluxWriter(w io.Writer) FlatTimeSeriesWriter {
+	return &influxWriter{w: bufio.NewWriter(w)}
+}
+
+type influxWriter struct {
+	w *bufio.Writer
+}
+
+func (w *influxWriter) Write(meteringPointID string, points []FlatTimeSeriesPoint) error {
+	for _, point := range points {
+		line := []byte(influxMeasurement)
+		// Tags are sorted by key, as InfluxDB recommends; one without a value is left out,
+		// which line protocol requires.
+		for _, tag := range [][2]string{
+			{"businesstype", point.BusinessType},
+			{"meteringPointId", meteringPointID},
+			{"resolution", string(point.Resolution)},
+			{"unit", point.Unit},
+		} {
+			if tag[1] != "" {
+				line = fmt.Appendf(line, ",%s=%s", tag[0], influxTagEscaper.Replace(tag[1]))
+			}
+		}
+		line = fmt.Appendf(line, " measurement=%s,quality=\"%s\" %d\n",
+			strconv.FormatFloat(point.Measurement, 'f', -1, 64),
+			influxStringEscaper.Replace(point.Quality),
+			point.From.UnixNano())
+
+		if _, err := w.w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *influxWriter) Close() error { return w.w.Flush() }
+
+var (
+	// influxTagEscaper escapes a tag value of line protocol.
+	influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+	// influxStringEscaper escapes a string field value of line protocol.
+	influxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// TimescaleTable is the table the Timescale writer upserts points into. Create it once,
+// and make it a hypertable when the TimescaleDB extension is installed:
+//
+//	CREATE TABLE eloverblik_points (
+//		metering_point_id text NOT NULL,
+//		resolution        text NOT NULL,
+//		period_start      timestamptz NOT NULL,
+//		period_end        timestamptz NOT NULL,
+//		measurement       double precision NOT NULL,
+//		quality           text NOT NULL,
+//		unit              text NOT NULL,
+//		curve_type        text NOT NULL,
+//		business_type     text NOT NULL,
+//		PRIMARY KEY (metering_point_id, resolution, period_start)
+//	);
+//	SELECT create_hypertable('eloverblik_points', 'period_start');
+const TimescaleTable = "eloverblik_points"
+
+// timescaleColumns are the columns of TimescaleTable, in the order the Timescale writer
+// copies them.
+var timescaleColumns = []string{
+	"metering_point_id",
+	"resolution",
+	"period_start",
+	"period_end",
+	"measurement",
+	"quality",
+	"unit",
+	"curve_type",
+	"business_type",
+}
+
+// NewTimescaleWriter returns a FlatTimeSeriesWriter that writes a SQL script for psql,
+// which loads the points into TimescaleTable of PostgreSQL or TimescaleDB:
+//
+//	go-eloverblik customer timeseries ... -o timescale | psql "$DATABASE_URL"
+//
+// COPY cannot update a row that is already there, so the script copies the points into a
+// temporary table in the COPY text format, and upserts them from there on the primary key
+// in a single transaction. Loading an overlapping range again updates the points rather
+// than duplicating them, or failing on the key.
+//
+// The points are written as they come, so a large range streams through. The script is
+// complete once Close has been called.
+func NewTimescaleWriter(w io.Writer) FlatTimeSeriesWriter {
+	return &timescaleWriter{w: bufio.NewWriter(w)}
+}
+
+type timescaleWriter struct {
+	w      *bufio.Writer
+	header bool
+}
+
+func (w *timescaleWriter) Write(meteringPointID string, points []FlatTimeSeriesPoint) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		row := []string{
+			meteringPointID,
+			string(point.Resolution),
+			point.From.Format(time.RFC3339Nano),
+			point.To.Format(time.RFC3339Nano),
+			strconv.FormatFloat(point.Measurement, 'f', -1, 64),
+			point.Quality,
+			point.Unit,
+			point.CurveType,
+			point.BusinessType,
+		}
+		for i, value := range row {
+			row[i] = copyTextEscaper.Replace(value)
+		}
+		if _, err := w.w.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHeader starts the transaction and the COPY, once.
+func (w *timescaleWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+
+	columns := strings.Join(timescaleColumns, ", ")
+	_, err := fmt.Fprintf(w.w, `BEGIN;
+CREATE TEMPORARY TABLE eloverblik_import (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP;
+COPY eloverblik_import (%s) FROM STDIN;
+`, TimescaleTable, columns)
+	return err
+}
+
+// Close ends the COPY and upserts the points. A metering point written twice keeps the
+// points written last.
+func (w *timescaleWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	var updates []string
+	for _, column := range timescaleColumns[3:] {
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	columns := strings.Join(timescaleColumns, ", ")
+	if _, err := fmt.Fprintf(w.w, `\.
+INSERT INTO %s (%s)
+SELECT DISTINCT ON (metering_point_id, resolution, period_start) %s
+FROM eloverblik_import
+ORDER BY metering_point_id, resolution, period_start, ctid DESC
+ON CONFLICT (metering_point_id, resolution, period_start) DO UPDATE SET
+  %s;
+COMMIT;
+`, TimescaleTable, columns, columns, strings.Join(updates, ",\n  ")); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// copyTextEscaper escapes a value of the COPY text format, in which a backslash starts an
+// escape, and a tab and a newline end a value and a row.
+var copyTextEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
@@ -0,0 +1,145 @@
+package eloverblik
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func writerTestPoints() []FlatTimeSeriesPoint {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, cph)
+	return []FlatTimeSeriesPoint{
+		{From: from, To: from.Add(time.Hour), Measurement: 0.198, Quality: "A04", Unit: "KWH", CurveType: "A01", BusinessType: "A04", Resolution: PT1H},
+		{From: from.Add(time.Hour), To: from.Add(2 * time.Hour), Measurement: 1, Quality: "A03", Unit: "KWH", CurveType: "A01", BusinessType: "A04", Resolution: PT1H},
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	t.Run("writes a header and one row per point with the metering point", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewCSVWriter(&buf)
+
+		assert.NoError(t, w.Write("571313180100000001", writerTestPoints()))
+		assert.NoError(t, w.Write("571313180100000002", writerTestPoints()[:1]))
+		assert.NoError(t, w.Close())
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 4, "the header is written once")
+		assert.Equal(t, flatTimeSeriesColumns, records[0])
+		assert.Equal(t, []string{"571313180100000001", "2024-01-01T00:00:00+01:00", "2024-01-01T01:00:00+01:00", "0.198", "A04", "KWH", "A01", "A04", "PT1H"}, records[1])
+		assert.Equal(t, "1", records[2][3], "a whole measurement carries no trailing decimals")
+		assert.Equal(t, "571313180100000002", records[3][0])
+	})
+
+	t.Run("an empty result is still a valid file", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewCSVWriter(&buf)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, strings.Join(flatTimeSeriesColumns, ",")+"\n", buf.String())
+	})
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONWriter(&buf)
+
+	assert.NoError(t, w.Write("571313180100000001", writerTestPoints()))
+	assert.NoError(t, w.Close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "571313180100000001", line["meteringPointId"])
+	assert.Equal(t, 0.198, line["measurement"])
+	assert.Equal(t, "PT1H", line["resolution"], "the point's fields are flat on the line, not nested")
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf)
+
+	assert.NoError(t, w.Write("571313180100000001", writerTestPoints()))
+	assert.NoError(t, w.Close())
+
+	rows, err := parquet.Read[parquetPoint](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "571313180100000001", rows[0].MeteringPointID)
+	assert.True(t, rows[0].From.Equal(writerTestPoints()[0].From))
+	assert.Equal(t, 0.198, rows[0].Measurement)
+	assert.Equal(t, "A03", rows[1].Quality)
+}
+
+func TestTimeSeriesMeteringPointID(t *testing.T) {
+	ts := TimeSeries{MyEnergyDataMarketDocument: MyEnergyDataMarketDocumentResponse{
+		TimeSeries: []TimeSeriesTimeSeriesResponse{{MRID: "571313180100000001"}},
+	}}
+	assert.Equal(t, "571313180100000001", ts.MeteringPointID())
+
+	failed := TimeSeries{StatusResponse: StatusResponse{ID: "571313180100000002"}}
+	assert.Equal(t, "571313180100000002", failed.MeteringPointID(), "a result without series falls back to its status ID")
+}
+
+func TestInfluxWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewInfluxWriter(&buf)
+
+	points := writerTestPoints()
+	points[1].Quality = `estimated "now"`
+	points[1].Unit = "K WH"
+	points[1].BusinessType = ""
+	assert.NoError(t, w.Write("571313180100000001", points))
+	assert.NoError(t, w.Close())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, `eloverblik,businesstype=A04,meteringPointId=571313180100000001,resolution=PT1H,unit=KWH measurement=0.198,quality="A04" 1704063600000000000`, lines[0])
+		assert.Equal(t, `eloverblik,meteringPointId=571313180100000001,resolution=PT1H,unit=K\ WH measurement=1,quality="estimated \"now\"" 1704067200000000000`, lines[1],
+			"an empty tag is left out, and values are escaped")
+	}
+
+	t.Run("writing the same points again writes the same lines", func(t *testing.T) {
+		var again bytes.Buffer
+		w := NewInfluxWriter(&again)
+		assert.NoError(t, w.Write("571313180100000001", points))
+		assert.NoError(t, w.Close())
+		assert.Equal(t, buf.String(), again.String())
+	})
+}
+
+func TestTimescaleWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTimescaleWriter(&buf)
+
+	points := writerTestPoints()
+	points[1].Quality = "a\tb"
+	assert.NoError(t, w.Write("571313180100000001", points))
+	assert.NoError(t, w.Close())
+
+	script := buf.String()
+	assert.True(t, strings.HasPrefix(script, "BEGIN;\n"))
+	assert.Contains(t, script, "COPY eloverblik_import (metering_point_id, resolution, period_start, period_end, measurement, quality, unit, curve_type, business_type) FROM STDIN;\n"+
+		"571313180100000001\tPT1H\t2024-01-01T00:00:00+01:00\t2024-01-01T01:00:00+01:00\t0.198\tA04\tKWH\tA01\tA04\n"+
+		"571313180100000001\tPT1H\t2024-01-01T01:00:00+01:00\t2024-01-01T02:00:00+01:00\t1\ta\\tb\tKWH\tA01\tA04\n"+
+		"\\.\n")
+	assert.Contains(t, script, "ON CONFLICT (metering_point_id, resolution, period_start) DO UPDATE SET")
+	assert.Contains(t, script, "quality = EXCLUDED.quality")
+	assert.NotContains(t, script, "period_start = EXCLUDED", "the key is not updated")
+	assert.True(t, strings.HasSuffix(script, "COMMIT;\n"))
+
+	t.Run("an empty result is still a valid script", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewTimescaleWriter(&buf)
+		assert.NoError(t, w.Close())
+		assert.Contains(t, buf.String(), "FROM STDIN;\n\\.\n")
+	})
+}